@@ -0,0 +1,480 @@
+package imagetk
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"os"
+	"time"
+)
+
+// DisposalMethod tells how the area of a frame is treated before the next frame is rendered
+type DisposalMethod int
+
+const (
+	// DisposeNone leaves the frame on the canvas
+	DisposeNone DisposalMethod = iota
+	// DisposeBackground clears the frame area to transparent
+	DisposeBackground
+	// DisposePrevious restores the frame area to the content before the frame was rendered
+	DisposePrevious
+)
+
+// BlendMode tells how a frame is drawn onto the canvas
+type BlendMode int
+
+const (
+	// BlendOver alpha-composites the frame over the canvas
+	BlendOver BlendMode = iota
+	// BlendSource replaces the frame area of the canvas with the frame
+	BlendSource
+)
+
+// AnimationFrame is a frame of an animation
+type AnimationFrame struct {
+	// Image is the frame image, its bounds give the position on the canvas
+	Image    image.Image
+	Delay    time.Duration
+	Disposal DisposalMethod
+	Blend    BlendMode
+}
+
+// Animation is the frame model shared by animated GIF and APNG
+type Animation struct {
+	Width  int
+	Height int
+	// LoopCount is the number of times the animation is played, 0 means forever
+	LoopCount int
+	// BackgroundColor is the background color stored in the file, nil if not available
+	BackgroundColor color.Color
+	Frames          []AnimationFrame
+}
+
+// Composite renders each frame onto the full canvas, applying the blend and disposal of the frames
+func (a *Animation) Composite() []*image.RGBA {
+	rectT := image.Rect(0, 0, a.Width, a.Height)
+	canvasT := image.NewRGBA(rectT)
+	resultT := make([]*image.RGBA, 0, len(a.Frames))
+
+	for _, v := range a.Frames {
+		frameRectT := v.Image.Bounds().Intersect(rectT)
+
+		var savedT *image.RGBA
+		if v.Disposal == DisposePrevious {
+			savedT = image.NewRGBA(frameRectT)
+			draw.Draw(savedT, frameRectT, canvasT, frameRectT.Min, draw.Src)
+		}
+
+		if v.Blend == BlendSource {
+			draw.Draw(canvasT, frameRectT, v.Image, frameRectT.Min, draw.Src)
+		} else {
+			draw.Draw(canvasT, frameRectT, v.Image, frameRectT.Min, draw.Over)
+		}
+
+		outT := image.NewRGBA(rectT)
+		copy(outT.Pix, canvasT.Pix)
+		resultT = append(resultT, outT)
+
+		switch v.Disposal {
+		case DisposeBackground:
+			draw.Draw(canvasT, frameRectT, image.Transparent, image.Point{}, draw.Src)
+		case DisposePrevious:
+			draw.Draw(canvasT, frameRectT, savedT, frameRectT.Min, draw.Src)
+		}
+	}
+
+	return resultT
+}
+
+// Transform applies the function to each composited frame, the result animation consists of full-canvas frames
+func (a *Animation) Transform(fnA func(image.Image) image.Image) *Animation {
+	resultT := &Animation{LoopCount: a.LoopCount, BackgroundColor: a.BackgroundColor}
+
+	for i, v := range a.Composite() {
+		imgT := fnA(v)
+
+		if i == 0 {
+			resultT.Width, resultT.Height = imgT.Bounds().Dx(), imgT.Bounds().Dy()
+		}
+
+		// move the frame to the canvas origin
+		if imgT.Bounds().Min != (image.Point{}) {
+			imgT = cropImage(imgT, imgT.Bounds())
+		}
+
+		resultT.Frames = append(resultT.Frames, AnimationFrame{Image: imgT, Delay: a.Frames[i].Delay, Disposal: DisposeBackground, Blend: BlendSource})
+	}
+
+	return resultT
+}
+
+// needsSourceBlend tells if any frame relies on BlendSource to replace non-transparent pixels, which GIF cannot express
+func (a *Animation) needsSourceBlend() bool {
+	for i, v := range a.Frames {
+		if i > 0 && v.Blend == BlendSource && !isOpaque(v.Image) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func animationFromGIF(g *gif.GIF) *Animation {
+	a := &Animation{Width: g.Config.Width, Height: g.Config.Height}
+
+	switch {
+	case g.LoopCount == 0:
+		a.LoopCount = 0
+	case g.LoopCount < 0:
+		a.LoopCount = 1
+	default:
+		a.LoopCount = g.LoopCount + 1
+	}
+
+	if paletteT, ok := g.Config.ColorModel.(color.Palette); ok && int(g.BackgroundIndex) < len(paletteT) {
+		a.BackgroundColor = paletteT[g.BackgroundIndex]
+	}
+
+	for i, v := range g.Image {
+		frameT := AnimationFrame{Image: v}
+
+		if i < len(g.Delay) {
+			frameT.Delay = time.Duration(g.Delay[i]) * 10 * time.Millisecond
+		}
+
+		if i < len(g.Disposal) {
+			switch g.Disposal[i] {
+			case gif.DisposalBackground:
+				frameT.Disposal = DisposeBackground
+			case gif.DisposalPrevious:
+				frameT.Disposal = DisposePrevious
+			}
+		}
+
+		if a.Width == 0 && a.Height == 0 {
+			a.Width, a.Height = v.Bounds().Max.X, v.Bounds().Max.Y
+		}
+
+		a.Frames = append(a.Frames, frameT)
+	}
+
+	return a
+}
+
+// stackedImage presents the images stacked vertically, used to quantize a shared palette without copying pixels
+type stackedImage struct {
+	images []image.Image
+	rect   image.Rectangle
+}
+
+func newStackedImage(imagesA []image.Image) *stackedImage {
+	s := &stackedImage{images: imagesA}
+
+	for _, v := range imagesA {
+		if v.Bounds().Dx() > s.rect.Max.X {
+			s.rect.Max.X = v.Bounds().Dx()
+		}
+		s.rect.Max.Y += v.Bounds().Dy()
+	}
+
+	return s
+}
+
+func (s *stackedImage) ColorModel() color.Model {
+	return color.RGBA64Model
+}
+
+func (s *stackedImage) Bounds() image.Rectangle {
+	return s.rect
+}
+
+func (s *stackedImage) At(x, y int) color.Color {
+	for _, v := range s.images {
+		boundsT := v.Bounds()
+
+		if y < boundsT.Dy() {
+			if x >= boundsT.Dx() {
+				return color.Transparent
+			}
+
+			return v.At(boundsT.Min.X+x, boundsT.Min.Y+y)
+		}
+
+		y -= boundsT.Dy()
+	}
+
+	return color.Transparent
+}
+
+func gifDelay(d time.Duration) int {
+	return int((d + 5*time.Millisecond) / (10 * time.Millisecond))
+}
+
+func animationToGIF(a *Animation, optsA *EncodeOptions) (*gif.GIF, error) {
+	if len(a.Frames) < 1 {
+		return nil, fmt.Errorf("no frames in the animation")
+	}
+
+	if a.needsSourceBlend() {
+		a = a.Transform(func(imageA image.Image) image.Image { return imageA })
+	}
+
+	numColorsT := 256
+	var quantizerT draw.Quantizer = MedianCutQuantizer{}
+	var drawerT draw.Drawer = draw.FloydSteinberg
+	sharedT := false
+
+	if optsA != nil {
+		if optsA.NumColors > 0 && optsA.NumColors < 256 {
+			numColorsT = optsA.NumColors
+		}
+
+		if optsA.Quantizer != nil {
+			quantizerT = optsA.Quantizer
+		}
+
+		if optsA.Drawer != nil {
+			drawerT = optsA.Drawer
+		}
+
+		sharedT = optsA.SharedPalette
+	}
+
+	g := &gif.GIF{Config: image.Config{Width: a.Width, Height: a.Height}}
+
+	switch {
+	case a.LoopCount == 0:
+		g.LoopCount = 0
+	case a.LoopCount == 1:
+		g.LoopCount = -1
+	default:
+		g.LoopCount = a.LoopCount - 1
+	}
+
+	// quantizes with one color reserved for transparency if needed
+	quantizeT := func(imageA image.Image, transparentA bool) color.Palette {
+		nT := numColorsT
+		if transparentA && nT > 1 {
+			nT--
+		}
+
+		paletteT := quantizerT.Quantize(make(color.Palette, 0, nT), imageA)
+		if len(paletteT) < 1 {
+			paletteT = append(paletteT, color.RGBA{0, 0, 0, 255})
+		}
+
+		if transparentA {
+			paletteT = append(paletteT, color.RGBA{})
+		}
+
+		return paletteT
+	}
+
+	var sharedPaletteT color.Palette
+
+	if sharedT {
+		imagesT := make([]image.Image, 0, len(a.Frames))
+		transparentT := false
+
+		for _, v := range a.Frames {
+			imagesT = append(imagesT, v.Image)
+			transparentT = transparentT || !isOpaque(v.Image)
+		}
+
+		sharedPaletteT = quantizeT(newStackedImage(imagesT), transparentT)
+		g.Config.ColorModel = sharedPaletteT
+	}
+
+	for _, v := range a.Frames {
+		frameRectT := v.Image.Bounds()
+
+		pT, ok := v.Image.(*image.Paletted)
+		if !ok || sharedT {
+			paletteT := sharedPaletteT
+			if paletteT == nil {
+				paletteT = quantizeT(v.Image, !isOpaque(v.Image))
+			}
+
+			pT = image.NewPaletted(frameRectT, paletteT)
+			drawerT.Draw(pT, frameRectT, v.Image, frameRectT.Min)
+		}
+
+		var disposalT byte = gif.DisposalNone
+
+		switch v.Disposal {
+		case DisposeBackground:
+			disposalT = gif.DisposalBackground
+		case DisposePrevious:
+			disposalT = gif.DisposalPrevious
+		}
+
+		g.Image = append(g.Image, pT)
+		g.Delay = append(g.Delay, gifDelay(v.Delay))
+		g.Disposal = append(g.Disposal, disposalT)
+	}
+
+	return g, nil
+}
+
+// DecodeAnimation decodes all frames of an animated image, still images result in a single frame animation.
+// Returns the animation and the format name detected from the content.
+// optsA could be *DecodeOptions, DecodeOptions, *DecodeLimits or DecodeLimits.
+func (p *ImageTK) DecodeAnimation(readerA io.Reader, optsA ...interface{}) (*Animation, string, error) {
+	var limitsT *DecodeLimits
+	if optsT := getDecodeOptions(optsA); optsT != nil {
+		limitsT = optsT.Limits
+	}
+
+	// the frames of a seekable input are counted without buffering the content
+	countedT := false
+	if s, ok := readerA.(io.ReadSeeker); ok && limitsT != nil {
+		var errT error
+
+		countedT, errT = limitsT.checkSeeker(s)
+		if errT != nil {
+			return nil, "", errT
+		}
+	}
+
+	r, lr := limitsT.wrapReader(readerA)
+	rr := asPeekReader(r)
+
+	formatT, errT := sniffFormat(rr)
+	if errT != nil {
+		return nil, "", lr.err(errT)
+	}
+
+	var srcT io.Reader = rr
+
+	if limitsT != nil {
+		srcT, errT = limitsT.check(rr, formatT, !countedT)
+		if errT != nil {
+			return nil, formatT, lr.err(errT)
+		}
+	}
+
+	var animT *Animation
+
+	switch formatT {
+	case "gif":
+		g, errT := gif.DecodeAll(srcT)
+		if errT != nil {
+			return nil, formatT, lr.err(errT)
+		}
+
+		animT = animationFromGIF(g)
+	case "png":
+		animT, errT = decodeAPNG(srcT)
+		if errT != nil {
+			return nil, formatT, lr.err(errT)
+		}
+	}
+
+	if animT != nil {
+		errT = limitsT.checkFrames(len(animT.Frames))
+		if errT != nil {
+			return nil, formatT, errT
+		}
+
+		return animT, formatT, nil
+	}
+
+	imgT, formatT, errT := decodeImage(srcT, formatT)
+	if errT != nil {
+		return nil, formatT, lr.err(errT)
+	}
+
+	boundsT := imgT.Bounds()
+
+	if boundsT.Min != (image.Point{}) {
+		imgT = cropImage(imgT, boundsT)
+	}
+
+	return &Animation{Width: boundsT.Dx(), Height: boundsT.Dy(), Frames: []AnimationFrame{{Image: imgT}}}, formatT, nil
+}
+
+// LoadAnimation loads all frames of an animated image file, optional arguments are the same as DecodeAnimation
+func (p *ImageTK) LoadAnimation(fileNameA string, optsA ...interface{}) (*Animation, string, error) {
+	fileT, errT := os.Open(fileNameA)
+	if errT != nil {
+		return nil, "", errT
+	}
+	defer fileT.Close()
+
+	return p.DecodeAnimation(fileT, optsA...)
+}
+
+// EncodeAnimation encodes the animation in the format("gif", "png" or "apng") to the writer, optsA could be nil.
+// The NumColors, Quantizer, Drawer and SharedPalette options are used for GIF, PNGCompression for APNG.
+func (p *ImageTK) EncodeAnimation(writerA io.Writer, animA *Animation, formatA string, optsA *EncodeOptions) error {
+	formatT := normalizeFormat(formatA)
+
+	switch formatT {
+	case "gif":
+		g, errT := animationToGIF(animA, optsA)
+		if errT != nil {
+			return errT
+		}
+
+		return gif.EncodeAll(writerA, g)
+	case "png", "apng":
+		return encodeAPNG(writerA, animA, optsA)
+	}
+
+	return fmt.Errorf("%w: animated %v", ErrUnsupportedFormat, formatA)
+}
+
+// SaveAnimation saves the animation to the file, optional arguments are the same as SaveImageAs.
+// If no format is given, the format is chosen from the file extension, and falls back to APNG if the extension
+// is not a known format. Only GIF and APNG are supported.
+func (p *ImageTK) SaveAnimation(animA *Animation, filePathA string, optsA ...interface{}) error {
+	argsT := getSaveArgs(filePathA, optsA)
+
+	switch argsT.format {
+	case "gif", "png", "apng":
+	default:
+		return fmt.Errorf("%w: animated %v", ErrUnsupportedFormat, argsT.format)
+	}
+
+	return writeFileAtomic(filePathA, argsT.saveOpts, func(w io.Writer) error {
+		return p.EncodeAnimation(w, animA, argsT.format, argsT.encodeOpts)
+	})
+}
+
+// ResizeAnimation resizes every frame of the animation, optional arguments are the same as ResizeImage
+func (p *ImageTK) ResizeAnimation(widthA, heightA int, animA *Animation, optsA ...interface{}) *Animation {
+	return animA.Transform(func(imageA image.Image) image.Image {
+		return p.ResizeImage(widthA, heightA, imageA, optsA...)
+	})
+}
+
+// ThumbnailAnimation makes thumbnails of every frame of the animation, optional arguments are the same as ResizeImage
+func (p *ImageTK) ThumbnailAnimation(maxWidth, maxHeight uint, animA *Animation, optsA ...interface{}) *Animation {
+	return animA.Transform(func(imageA image.Image) image.Image {
+		return p.Thumbnail(maxWidth, maxHeight, imageA, optsA...)
+	})
+}
+
+// CropAnimation crops every frame of the animation to the rectangle
+func (p *ImageTK) CropAnimation(animA *Animation, rectA image.Rectangle) *Animation {
+	return animA.Transform(func(imageA image.Image) image.Image {
+		return cropImage(imageA, rectA)
+	})
+}
+
+// CropImage returns a copy of the part of the image in the rectangle, the result's bounds start at (0, 0)
+func (p *ImageTK) CropImage(imageA image.Image, rectA image.Rectangle) image.Image {
+	return cropImage(imageA, rectA)
+}
+
+func cropImage(imageA image.Image, rectA image.Rectangle) image.Image {
+	rectA = rectA.Intersect(imageA.Bounds())
+
+	dstT := newImageOf(imageA, image.Rect(0, 0, rectA.Dx(), rectA.Dy()))
+	draw.Draw(dstT, dstT.Bounds(), imageA, rectA.Min, draw.Src)
+
+	return dstT
+}
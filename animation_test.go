@@ -0,0 +1,196 @@
+package imagetk
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testAnimation returns an animation of the frames with four colors moving over a 20x10 canvas
+func testAnimation(loopA int, pagedA bool) *Animation {
+	paletteT := color.Palette{color.RGBA{}, color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}, color.RGBA{0, 0, 255, 255}}
+
+	a := &Animation{Width: 20, Height: 10, LoopCount: loopA}
+
+	disposalsT := []DisposalMethod{DisposeNone, DisposeBackground, DisposePrevious, DisposeNone}
+	for i, v := range disposalsT {
+		rectT := image.Rect(2*i, i, 2*i+8, i+6)
+		if i == 0 {
+			rectT = image.Rect(0, 0, 20, 10)
+		}
+
+		var imgT draw.Image = image.NewPaletted(rectT, paletteT)
+		if !pagedA {
+			imgT = image.NewNRGBA(rectT)
+		}
+
+		for y := rectT.Min.Y; y < rectT.Max.Y; y++ {
+			for x := rectT.Min.X; x < rectT.Max.X; x++ {
+				imgT.Set(x, y, paletteT[(x+y+i)%4])
+			}
+		}
+
+		a.Frames = append(a.Frames, AnimationFrame{Image: imgT, Delay: time.Duration(i+1) * 30 * time.Millisecond, Disposal: v})
+	}
+
+	return a
+}
+
+func TestGIFAnimationRoundTrip(t *testing.T) {
+	p := NewImageTK()
+
+	tests := []struct {
+		name string
+		anim *Animation
+		opts *EncodeOptions
+	}{
+		{"paletted forever", testAnimation(0, true), nil},
+		{"paletted once", testAnimation(1, true), nil},
+		{"paletted three times", testAnimation(3, true), nil},
+		{"nrgba", testAnimation(0, false), nil},
+		{"shared palette", testAnimation(2, false), &EncodeOptions{SharedPalette: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := p.EncodeAnimation(&b, tt.anim, "gif", tt.opts); err != nil {
+				t.Fatal(err)
+			}
+
+			got, formatT, err := p.DecodeAnimation(&b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if formatT != "gif" {
+				t.Errorf("format %v", formatT)
+			}
+
+			if got.Width != tt.anim.Width || got.Height != tt.anim.Height || got.LoopCount != tt.anim.LoopCount {
+				t.Errorf("got %vx%v loop %v, want %vx%v loop %v", got.Width, got.Height, got.LoopCount, tt.anim.Width, tt.anim.Height, tt.anim.LoopCount)
+			}
+
+			if len(got.Frames) != len(tt.anim.Frames) {
+				t.Fatalf("%v frames, want %v", len(got.Frames), len(tt.anim.Frames))
+			}
+
+			for i, v := range got.Frames {
+				w := tt.anim.Frames[i]
+				if v.Delay != w.Delay || v.Disposal != w.Disposal || v.Image.Bounds() != w.Image.Bounds() {
+					t.Errorf("frame %v: delay %v disposal %v bounds %v, want %v %v %v", i, v.Delay, v.Disposal, v.Image.Bounds(), w.Delay, w.Disposal, w.Image.Bounds())
+				}
+			}
+
+			wantT := tt.anim.Composite()
+			for i, v := range got.Composite() {
+				sameImage(t, v, wantT[i])
+			}
+		})
+	}
+}
+
+func TestAnimationTransform(t *testing.T) {
+	p := NewImageTK()
+	a := testAnimation(0, true)
+
+	tests := []struct {
+		name string
+		anim *Animation
+		w, h int
+	}{
+		{"resize", p.ResizeAnimation(10, 5, a, NearestNeighbor), 10, 5},
+		{"thumbnail", p.ThumbnailAnimation(8, 8, a, NearestNeighbor), 8, 4},
+		{"crop", p.CropAnimation(a, image.Rect(5, 2, 12, 9)), 7, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.anim.Width != tt.w || tt.anim.Height != tt.h || len(tt.anim.Frames) != len(a.Frames) {
+				t.Fatalf("got %vx%v with %v frames", tt.anim.Width, tt.anim.Height, len(tt.anim.Frames))
+			}
+
+			for i, v := range tt.anim.Frames {
+				if v.Image.Bounds() != image.Rect(0, 0, tt.w, tt.h) || v.Delay != a.Frames[i].Delay {
+					t.Errorf("frame %v: bounds %v delay %v", i, v.Image.Bounds(), v.Delay)
+				}
+			}
+		})
+	}
+
+	cropT := p.CropAnimation(a, image.Rect(5, 2, 12, 9))
+	for i, v := range a.Composite() {
+		sameImage(t, cropT.Frames[i].Image, v.SubImage(image.Rect(5, 2, 12, 9)))
+	}
+}
+
+func TestDecodeAnimationStill(t *testing.T) {
+	p := NewImageTK()
+	img := fillPattern(image.NewNRGBA(image.Rect(0, 0, 6, 4)), true)
+
+	dataT, err := p.EncodeToBytes(img, "png", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, formatT, err := p.DecodeAnimation(bytes.NewReader(dataT))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if formatT != "png" || len(a.Frames) != 1 || a.Width != 6 || a.Height != 4 {
+		t.Fatalf("got %v with %v frames of %vx%v", formatT, len(a.Frames), a.Width, a.Height)
+	}
+
+	sameImage(t, a.Frames[0].Image, img)
+}
+
+func TestSaveAnimationFormat(t *testing.T) {
+	p := NewImageTK()
+	a := testAnimation(0, true)
+	dirT := t.TempDir()
+
+	tests := []struct {
+		file string
+		opts []interface{}
+		want string
+	}{
+		{"a.gif", nil, "gif"},
+		{"b.png", []interface{}{"gif"}, "gif"},
+		{"c.apng", nil, "png"},
+		{"d.xyz", nil, "png"},
+		{"e.jpg", nil, ""},
+		{"f.gif", []interface{}{"webp"}, ""},
+	}
+
+	for _, tt := range tests {
+		pathT := filepath.Join(dirT, tt.file)
+
+		err := p.SaveAnimation(a, pathT, tt.opts...)
+		if tt.want == "" {
+			if !errors.Is(err, ErrUnsupportedFormat) {
+				t.Errorf("%v: got %v, want ErrUnsupportedFormat", tt.file, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, formatT, err := p.LoadAnimation(pathT)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if formatT != tt.want || len(got.Frames) != len(a.Frames) {
+			t.Errorf("%v: got %v with %v frames", tt.file, formatT, len(got.Frames))
+		}
+	}
+}
@@ -0,0 +1,567 @@
+package imagetk
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"time"
+)
+
+// APNG(animated PNG) codec, see https://wiki.mozilla.org/APNG_Specification
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+const (
+	apngDisposeNone       = 0
+	apngDisposeBackground = 1
+	apngDisposePrevious   = 2
+
+	apngBlendSource = 0
+	apngBlendOver   = 1
+)
+
+var errPNGFormat = errors.New("png: invalid format")
+
+// PNGChunk is a raw PNG chunk, Data excludes the length, the type and the CRC
+type PNGChunk struct {
+	Type string
+	Data []byte
+}
+
+// readPNGChunk reads a chunk and checks its CRC
+func readPNGChunk(r io.Reader) (*PNGChunk, error) {
+	headerT := make([]byte, 8)
+
+	_, errT := io.ReadFull(r, headerT)
+	if errT != nil {
+		return nil, errT
+	}
+
+	return readPNGChunkBody(r, headerT)
+}
+
+// readPNGChunkBody reads the rest of the chunk after its 8-byte header(length and type) and checks its CRC
+func readPNGChunkBody(r io.Reader, headerA []byte) (*PNGChunk, error) {
+	lengthT := binary.BigEndian.Uint32(headerA[:4])
+	if lengthT > 0x7fffffff {
+		return nil, errPNGFormat
+	}
+
+	c := &PNGChunk{Type: string(headerA[4:8])}
+
+	// read in limited steps so a forged length does not allocate a huge buffer
+	var bufT bytes.Buffer
+	_, errT := io.CopyN(&bufT, r, int64(lengthT))
+	if errT != nil {
+		if errT == io.EOF {
+			errT = io.ErrUnexpectedEOF
+		}
+		return nil, errT
+	}
+
+	c.Data = bufT.Bytes()
+
+	_, errT = io.ReadFull(r, headerA[:4])
+	if errT != nil {
+		if errT == io.EOF {
+			errT = io.ErrUnexpectedEOF
+		}
+		return nil, errT
+	}
+
+	crcT := crc32.NewIEEE()
+	crcT.Write([]byte(c.Type))
+	crcT.Write(c.Data)
+
+	if crcT.Sum32() != binary.BigEndian.Uint32(headerA[:4]) {
+		return nil, fmt.Errorf("png: invalid checksum in chunk %v", c.Type)
+	}
+
+	return c, nil
+}
+
+func writePNGChunk(w io.Writer, typeA string, dataA []byte) error {
+	headerT := make([]byte, 8)
+	binary.BigEndian.PutUint32(headerT[:4], uint32(len(dataA)))
+	copy(headerT[4:], typeA)
+
+	crcT := crc32.NewIEEE()
+	crcT.Write(headerT[4:8])
+	crcT.Write(dataA)
+
+	_, errT := w.Write(headerT)
+	if errT != nil {
+		return errT
+	}
+
+	_, errT = w.Write(dataA)
+	if errT != nil {
+		return errT
+	}
+
+	return binary.Write(w, binary.BigEndian, crcT.Sum32())
+}
+
+type apngFrameControl struct {
+	Width, Height    uint32
+	XOffset, YOffset uint32
+	DelayNum         uint16
+	DelayDen         uint16
+	DisposeOp        byte
+	BlendOp          byte
+}
+
+func parseAPNGFrameControl(dataA []byte) (*apngFrameControl, error) {
+	if len(dataA) != 26 {
+		return nil, errPNGFormat
+	}
+
+	return &apngFrameControl{
+		Width:     binary.BigEndian.Uint32(dataA[4:8]),
+		Height:    binary.BigEndian.Uint32(dataA[8:12]),
+		XOffset:   binary.BigEndian.Uint32(dataA[12:16]),
+		YOffset:   binary.BigEndian.Uint32(dataA[16:20]),
+		DelayNum:  binary.BigEndian.Uint16(dataA[20:22]),
+		DelayDen:  binary.BigEndian.Uint16(dataA[22:24]),
+		DisposeOp: dataA[24],
+		BlendOp:   dataA[25],
+	}, nil
+}
+
+func (fc *apngFrameControl) delay() time.Duration {
+	denT := time.Duration(fc.DelayDen)
+	if denT == 0 {
+		denT = 100
+	}
+
+	return time.Duration(fc.DelayNum) * time.Second / denT
+}
+
+// translateImage moves the image so its bounds start at the point, without copying pixels if possible
+func translateImage(imageA image.Image, ptA image.Point) image.Image {
+	deltaT := ptA.Sub(imageA.Bounds().Min)
+
+	switch m := imageA.(type) {
+	case *image.RGBA:
+		m.Rect = m.Rect.Add(deltaT)
+	case *image.NRGBA:
+		m.Rect = m.Rect.Add(deltaT)
+	case *image.RGBA64:
+		m.Rect = m.Rect.Add(deltaT)
+	case *image.NRGBA64:
+		m.Rect = m.Rect.Add(deltaT)
+	case *image.Gray:
+		m.Rect = m.Rect.Add(deltaT)
+	case *image.Gray16:
+		m.Rect = m.Rect.Add(deltaT)
+	case *image.Paletted:
+		m.Rect = m.Rect.Add(deltaT)
+	default:
+		dstT := newImageOf(imageA, imageA.Bounds().Add(deltaT))
+		draw.Draw(dstT, dstT.Bounds(), imageA, imageA.Bounds().Min, draw.Src)
+		return dstT
+	}
+
+	return imageA
+}
+
+type apngFrameData struct {
+	control *apngFrameControl
+	data    bytes.Buffer
+}
+
+// decodeAPNG decodes all frames of an APNG stream, a PNG without acTL results in a single frame animation
+func decodeAPNG(r io.Reader) (*Animation, error) {
+	br := bufio.NewReader(r)
+
+	sigT := make([]byte, len(pngSignature))
+
+	_, errT := io.ReadFull(br, sigT)
+	if errT != nil || string(sigT) != pngSignature {
+		return nil, errPNGFormat
+	}
+
+	var ihdrT []byte
+	var sharedChunksT []*PNGChunk
+	var framesT []*apngFrameData
+	var staticT bytes.Buffer
+	var currentT *apngFrameData
+	animatedT := false
+	seenIDATT := false
+	loopCountT := 0
+
+	for {
+		c, errT := readPNGChunk(br)
+		if errT != nil {
+			return nil, errT
+		}
+
+		if c.Type == "IEND" {
+			break
+		}
+
+		switch c.Type {
+		case "IHDR":
+			if len(c.Data) != 13 {
+				return nil, errPNGFormat
+			}
+			ihdrT = c.Data
+		case "PLTE", "tRNS":
+			sharedChunksT = append(sharedChunksT, c)
+		case "acTL":
+			if len(c.Data) != 8 {
+				return nil, errPNGFormat
+			}
+			animatedT = true
+			loopCountT = int(binary.BigEndian.Uint32(c.Data[4:8]))
+		case "fcTL":
+			fc, errT := parseAPNGFrameControl(c.Data)
+			if errT != nil {
+				return nil, errT
+			}
+			currentT = &apngFrameData{control: fc}
+			framesT = append(framesT, currentT)
+		case "IDAT":
+			seenIDATT = true
+			staticT.Write(c.Data)
+			// IDAT belongs to the animation only if its fcTL comes first
+			if currentT != nil && len(framesT) == 1 {
+				currentT.data.Write(c.Data)
+			}
+		case "fdAT":
+			if len(c.Data) < 4 || currentT == nil || !seenIDATT {
+				return nil, errPNGFormat
+			}
+			currentT.data.Write(c.Data[4:])
+		}
+	}
+
+	if ihdrT == nil || !seenIDATT {
+		return nil, errPNGFormat
+	}
+
+	widthT := int(binary.BigEndian.Uint32(ihdrT[0:4]))
+	heightT := int(binary.BigEndian.Uint32(ihdrT[4:8]))
+
+	// builds a standalone PNG of a frame and decodes it with the standard decoder
+	decodeFrameT := func(wA, hA uint32, dataA []byte) (image.Image, error) {
+		var bufT bytes.Buffer
+
+		bufT.WriteString(pngSignature)
+
+		headerT := append([]byte(nil), ihdrT...)
+		binary.BigEndian.PutUint32(headerT[0:4], wA)
+		binary.BigEndian.PutUint32(headerT[4:8], hA)
+		writePNGChunk(&bufT, "IHDR", headerT)
+
+		for _, v := range sharedChunksT {
+			writePNGChunk(&bufT, v.Type, v.Data)
+		}
+
+		writePNGChunk(&bufT, "IDAT", dataA)
+		writePNGChunk(&bufT, "IEND", nil)
+
+		return png.Decode(&bufT)
+	}
+
+	a := &Animation{Width: widthT, Height: heightT, LoopCount: loopCountT}
+
+	if !animatedT || len(framesT) < 1 {
+		imgT, errT := decodeFrameT(uint32(widthT), uint32(heightT), staticT.Bytes())
+		if errT != nil {
+			return nil, errT
+		}
+
+		a.LoopCount = 0
+		a.Frames = []AnimationFrame{{Image: imgT}}
+
+		return a, nil
+	}
+
+	for _, v := range framesT {
+		fc := v.control
+
+		if fc.Width < 1 || fc.Height < 1 || uint64(fc.XOffset)+uint64(fc.Width) > uint64(widthT) || uint64(fc.YOffset)+uint64(fc.Height) > uint64(heightT) {
+			return nil, fmt.Errorf("png: APNG frame out of the canvas")
+		}
+
+		imgT, errT := decodeFrameT(fc.Width, fc.Height, v.data.Bytes())
+		if errT != nil {
+			return nil, errT
+		}
+
+		frameT := AnimationFrame{Image: translateImage(imgT, image.Pt(int(fc.XOffset), int(fc.YOffset))), Delay: fc.delay()}
+
+		switch fc.DisposeOp {
+		case apngDisposeBackground:
+			frameT.Disposal = DisposeBackground
+		case apngDisposePrevious:
+			frameT.Disposal = DisposePrevious
+		}
+
+		if fc.BlendOp == apngBlendSource {
+			frameT.Blend = BlendSource
+		}
+
+		a.Frames = append(a.Frames, frameT)
+	}
+
+	return a, nil
+}
+
+func zlibLevel(levelA png.CompressionLevel) int {
+	switch levelA {
+	case png.NoCompression:
+		return zlib.NoCompression
+	case png.BestSpeed:
+		return zlib.BestSpeed
+	case png.BestCompression:
+		return zlib.BestCompression
+	default:
+		return zlib.DefaultCompression
+	}
+}
+
+func pngAbs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func pngPaeth(a, b, c uint8) uint8 {
+	pc := int(c)
+	pa := int(b) - pc
+	pb := int(a) - pc
+	pc = pngAbs(pa + pb)
+	pa = pngAbs(pa)
+	pb = pngAbs(pb)
+
+	if pa <= pb && pa <= pc {
+		return a
+	} else if pb <= pc {
+		return b
+	}
+
+	return c
+}
+
+// filterPNGRow writes the filtered row with the filter type giving the smallest sum of absolute values,
+// bufA has 5 rows of len(curA)+1 bytes, the first byte of each is the filter type
+func filterPNGRow(curA, prevA []uint8, bppA int, bufA [][]uint8) []uint8 {
+	n := len(curA)
+
+	bufA[0][0] = 0
+	copy(bufA[0][1:], curA)
+
+	for f := 1; f < 5; f++ {
+		bufA[f][0] = uint8(f)
+	}
+
+	for i := 0; i < n; i++ {
+		var a, c uint8
+		if i >= bppA {
+			a = curA[i-bppA]
+			c = prevA[i-bppA]
+		}
+		b := prevA[i]
+
+		bufA[1][i+1] = curA[i] - a
+		bufA[2][i+1] = curA[i] - b
+		bufA[3][i+1] = curA[i] - uint8((int(a)+int(b))/2)
+		bufA[4][i+1] = curA[i] - pngPaeth(a, b, c)
+	}
+
+	bestT := 0
+	bestSumT := -1
+
+	for f := 0; f < 5; f++ {
+		sumT := 0
+		for _, v := range bufA[f][1:] {
+			sumT += pngAbs(int(int8(v)))
+		}
+
+		if bestSumT < 0 || sumT < bestSumT {
+			bestT, bestSumT = f, sumT
+		}
+	}
+
+	return bufA[bestT]
+}
+
+// compressPNGImage returns the zlib compressed, filtered 8-bit RGBA rows of the image
+func compressPNGImage(imageA image.Image, levelA png.CompressionLevel) ([]byte, error) {
+	boundsT := imageA.Bounds()
+
+	imgT, ok := imageA.(*image.NRGBA)
+	if !ok {
+		imgT = image.NewNRGBA(boundsT)
+		draw.Draw(imgT, boundsT, imageA, boundsT.Min, draw.Src)
+	}
+
+	var bufT bytes.Buffer
+
+	zw, errT := zlib.NewWriterLevel(&bufT, zlibLevel(levelA))
+	if errT != nil {
+		return nil, errT
+	}
+
+	rowLenT := boundsT.Dx() * 4
+	prevT := make([]uint8, rowLenT)
+	filterBufT := make([][]uint8, 5)
+	for i := range filterBufT {
+		filterBufT[i] = make([]uint8, rowLenT+1)
+	}
+
+	for y := 0; y < boundsT.Dy(); y++ {
+		curT := imgT.Pix[y*imgT.Stride : y*imgT.Stride+rowLenT]
+
+		_, errT = zw.Write(filterPNGRow(curT, prevT, 4, filterBufT))
+		if errT != nil {
+			return nil, errT
+		}
+
+		prevT = curT
+	}
+
+	errT = zw.Close()
+	if errT != nil {
+		return nil, errT
+	}
+
+	return bufT.Bytes(), nil
+}
+
+func apngDelay(d time.Duration) (uint16, uint16) {
+	msT := d.Milliseconds()
+
+	if msT <= 0xffff {
+		return uint16(msT), 1000
+	}
+
+	csT := msT / 10
+	if csT > 0xffff {
+		csT = 0xffff
+	}
+
+	return uint16(csT), 100
+}
+
+// encodeAPNG writes the animation as an APNG, the first frame is also the default image seen by plain PNG readers
+func encodeAPNG(w io.Writer, a *Animation, optsA *EncodeOptions) error {
+	if len(a.Frames) < 1 {
+		return fmt.Errorf("no frames in the animation")
+	}
+
+	var levelT png.CompressionLevel
+	var metaT *PNGMeta
+	var exifT []byte
+
+	if optsA != nil {
+		levelT = optsA.PNGCompression
+		metaT, exifT = optsA.PNGMeta, optsA.EXIF
+	}
+
+	canvasRectT := image.Rect(0, 0, a.Width, a.Height)
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(pngSignature)
+
+	ihdrT := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdrT[0:4], uint32(a.Width))
+	binary.BigEndian.PutUint32(ihdrT[4:8], uint32(a.Height))
+	ihdrT[8] = 8 // bit depth
+	ihdrT[9] = 6 // color type RGBA
+	writePNGChunk(bw, "IHDR", ihdrT)
+
+	errT := writePNGMetaChunks(bw, metaT, exifT, true, parsePNGColorInfo(ihdrT))
+	if errT != nil {
+		return errT
+	}
+
+	actlT := make([]byte, 8)
+	binary.BigEndian.PutUint32(actlT[0:4], uint32(len(a.Frames)))
+	binary.BigEndian.PutUint32(actlT[4:8], uint32(a.LoopCount))
+	writePNGChunk(bw, "acTL", actlT)
+
+	errT = writePNGMetaChunks(bw, metaT, exifT, false, parsePNGColorInfo(ihdrT))
+	if errT != nil {
+		return errT
+	}
+
+	var seqT uint32
+
+	for i, v := range a.Frames {
+		imgT := v.Image
+		frameRectT := imgT.Bounds().Intersect(canvasRectT)
+		blendT := v.Blend
+
+		// the first frame is the default image, which must cover the whole canvas
+		if i == 0 && frameRectT != canvasRectT {
+			fullT := image.NewNRGBA(canvasRectT)
+			draw.Draw(fullT, frameRectT, imgT, frameRectT.Min, draw.Src)
+			imgT, frameRectT, blendT = fullT, canvasRectT, BlendSource
+		} else if imgT.Bounds() != frameRectT {
+			imgT = cropImage(imgT, frameRectT)
+		}
+
+		if frameRectT.Empty() {
+			return fmt.Errorf("png: APNG frame %v out of the canvas", i)
+		}
+
+		fctlT := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctlT[0:4], seqT)
+		binary.BigEndian.PutUint32(fctlT[4:8], uint32(frameRectT.Dx()))
+		binary.BigEndian.PutUint32(fctlT[8:12], uint32(frameRectT.Dy()))
+		binary.BigEndian.PutUint32(fctlT[12:16], uint32(frameRectT.Min.X))
+		binary.BigEndian.PutUint32(fctlT[16:20], uint32(frameRectT.Min.Y))
+		delayNumT, delayDenT := apngDelay(v.Delay)
+		binary.BigEndian.PutUint16(fctlT[20:22], delayNumT)
+		binary.BigEndian.PutUint16(fctlT[22:24], delayDenT)
+
+		switch v.Disposal {
+		case DisposeBackground:
+			fctlT[24] = apngDisposeBackground
+		case DisposePrevious:
+			fctlT[24] = apngDisposePrevious
+		default:
+			fctlT[24] = apngDisposeNone
+		}
+
+		if blendT == BlendSource {
+			fctlT[25] = apngBlendSource
+		} else {
+			fctlT[25] = apngBlendOver
+		}
+
+		writePNGChunk(bw, "fcTL", fctlT)
+		seqT++
+
+		dataT, errT := compressPNGImage(imgT, levelT)
+		if errT != nil {
+			return errT
+		}
+
+		if i == 0 {
+			writePNGChunk(bw, "IDAT", dataT)
+		} else {
+			fdatT := make([]byte, 4+len(dataT))
+			binary.BigEndian.PutUint32(fdatT[0:4], seqT)
+			copy(fdatT[4:], dataT)
+			writePNGChunk(bw, "fdAT", fdatT)
+			seqT++
+		}
+	}
+
+	writePNGChunk(bw, "IEND", nil)
+
+	return bw.Flush()
+}
@@ -0,0 +1,114 @@
+package imagetk
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func TestAPNGRoundTrip(t *testing.T) {
+	p := NewImageTK()
+
+	blended := testAnimation(0, false)
+	blended.Frames[2].Blend = BlendSource
+	blended.Frames[3].Image = fillPattern(image.NewNRGBA(image.Rect(3, 2, 15, 9)), true)
+
+	long := testAnimation(5, true)
+	long.Frames[1].Delay = 70 * time.Second
+
+	small := testAnimation(0, false)
+	small.Frames[0].Image = fillPattern(image.NewNRGBA(image.Rect(4, 2, 10, 8)), true)
+
+	tests := []struct {
+		name  string
+		anim  *Animation
+		first image.Rectangle
+	}{
+		{"paletted", testAnimation(0, true), image.Rect(0, 0, 20, 10)},
+		{"nrgba loop", testAnimation(3, false), image.Rect(0, 0, 20, 10)},
+		{"blend source", blended, image.Rect(0, 0, 20, 10)},
+		{"long delay", long, image.Rect(0, 0, 20, 10)},
+		{"small first frame", small, image.Rect(0, 0, 20, 10)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := p.EncodeAnimation(&b, tt.anim, "apng", &EncodeOptions{PNGCompression: png.BestSpeed}); err != nil {
+				t.Fatal(err)
+			}
+
+			dataT := b.Bytes()
+
+			got, formatT, err := p.DecodeAnimation(bytes.NewReader(dataT))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if formatT != "png" {
+				t.Errorf("format %v", formatT)
+			}
+
+			if got.Width != tt.anim.Width || got.Height != tt.anim.Height || got.LoopCount != tt.anim.LoopCount {
+				t.Errorf("got %vx%v loop %v", got.Width, got.Height, got.LoopCount)
+			}
+
+			if len(got.Frames) != len(tt.anim.Frames) {
+				t.Fatalf("%v frames, want %v", len(got.Frames), len(tt.anim.Frames))
+			}
+
+			for i, v := range got.Frames {
+				w := tt.anim.Frames[i]
+				if v.Delay != w.Delay || v.Disposal != w.Disposal {
+					t.Errorf("frame %v: delay %v disposal %v, want %v %v", i, v.Delay, v.Disposal, w.Delay, w.Disposal)
+				}
+
+				if i > 0 && (v.Blend != w.Blend || v.Image.Bounds() != w.Image.Bounds()) {
+					t.Errorf("frame %v: blend %v bounds %v, want %v %v", i, v.Blend, v.Image.Bounds(), w.Blend, w.Image.Bounds())
+				}
+			}
+
+			if got.Frames[0].Image.Bounds() != tt.first {
+				t.Errorf("first frame bounds %v", got.Frames[0].Image.Bounds())
+			}
+
+			wantT := tt.anim.Composite()
+			for i, v := range got.Composite() {
+				sameImage(t, v, wantT[i])
+			}
+
+			// plain PNG readers see the first frame
+			img, err := png.Decode(bytes.NewReader(dataT))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sameImage(t, img, got.Frames[0].Image)
+		})
+	}
+}
+
+func TestAPNGEncodeErrors(t *testing.T) {
+	p := NewImageTK()
+
+	outside := testAnimation(0, false)
+	outside.Frames[1].Image = image.NewNRGBA(image.Rect(30, 30, 40, 40))
+
+	tests := []struct {
+		name string
+		anim *Animation
+	}{
+		{"no frames", &Animation{Width: 4, Height: 4}},
+		{"frame outside", outside},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := p.EncodeAnimation(&bytes.Buffer{}, tt.anim, "apng", nil); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package imagetk
+
+import "math"
+
+// prereduceGap is how many times larger than the destination the image stays after Prereduce,
+// so the final filter still averages enough pixels to look the same as the direct one
+const prereduceGap = 3
+
+// areaCoverage returns how much of each source pixel is covered by each destination pixel(0 to 1),
+// the start of the covered pixels and their count. The destination pixels are moved by the shift in source pixels.
+func areaCoverage(dy int, scale, shift float64) ([]float64, []int, int) {
+	filterLength := int(math.Ceil(scale)) + 1
+
+	coeffs := make([]float64, dy*filterLength)
+	start := make([]int, dy)
+	for y := 0; y < dy; y++ {
+		x0 := scale*float64(y) + shift
+		x1 := x0 + scale
+		start[y] = int(math.Floor(x0))
+		for i := 0; i < filterLength; i++ {
+			p := float64(start[y] + i)
+			coeffs[y*filterLength+i] = math.Max(0, math.Min(x1, p+1)-math.Max(x0, p))
+		}
+	}
+
+	return coeffs, start, filterLength
+}
+
+// createWeightsArea8 returns the weights averaging the exact area of the destination pixels, range [0,256]
+func createWeightsArea8(dy int, scale, shift float64) ([]int16, []int, int) {
+	coverage, start, filterLength := areaCoverage(dy, scale, shift)
+
+	coeffs := make([]int16, len(coverage))
+	for i, v := range coverage {
+		coeffs[i] = int16(math.Round(v * 256))
+	}
+
+	return coeffs, start, filterLength
+}
+
+// createWeightsArea16 returns the weights averaging the exact area of the destination pixels, range [0,65536]
+func createWeightsArea16(dy int, scale, shift float64) ([]int32, []int, int) {
+	coverage, start, filterLength := areaCoverage(dy, scale, shift)
+
+	coeffs := make([]int32, len(coverage))
+	for i, v := range coverage {
+		coeffs[i] = int32(math.Round(v * 65536))
+	}
+
+	return coeffs, start, filterLength
+}
+
+// prereduceShift returns how far the reducer moves its pixels, the filters place the source pixel i at i instead of
+// its center i+0.5, so the reduced pixels are centered the same way to line up with the direct filter
+func prereduceShift(srcLenA, reducedLenA int) float64 {
+	return -(float64(srcLenA)/float64(reducedLenA) - 1) / 2
+}
+
+// prereduceSize returns the source length halved while it stays prereduceGap times longer than the destination
+func prereduceSize(srcLenA, dstLenA int) int {
+	if dstLenA < 1 {
+		return srcLenA
+	}
+
+	for srcLenA/2 >= prereduceGap*dstLenA {
+		srcLenA /= 2
+	}
+
+	return srcLenA
+}
@@ -0,0 +1,129 @@
+package imagetk
+
+import (
+	"image"
+	"math"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestPrereduceReusesScratch(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2400, 1800))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i * 7)
+	}
+
+	r := NewResizer(2400, 1800, 200, 150, ResizeOptions{Interpolation: Lanczos3, Prereduce: true})
+	dst := image.NewRGBA(image.Rect(0, 0, 200, 150))
+
+	// the reduced image is 600x450, the second call must not allocate it again
+	tests := []struct {
+		name    string
+		scratch interface{}
+	}{
+		{"scratch", &ResizeScratch{}},
+		{"pool", &sync.Pool{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.Resize(dst, src, tt.scratch); err != nil {
+				t.Fatal(err)
+			}
+
+			var before, after runtime.MemStats
+
+			runtime.ReadMemStats(&before)
+			if err := r.Resize(dst, src, tt.scratch); err != nil {
+				t.Fatal(err)
+			}
+			runtime.ReadMemStats(&after)
+
+			if allocT := after.TotalAlloc - before.TotalAlloc; allocT >= 4*600*450 {
+				t.Errorf("allocated %v bytes with a reused scratch", allocT)
+			}
+		})
+	}
+}
+
+func TestPrereduceSize(t *testing.T) {
+	tests := []struct {
+		src, dst, want int
+	}{
+		{1000, 100, 500},
+		{1000, 50, 250},
+		{2400, 200, 600},
+		{599, 200, 599},
+		{600, 200, 600},
+		{1200, 200, 600},
+		{100, 0, 100},
+		{100, 100, 100},
+	}
+
+	for _, tt := range tests {
+		if got := prereduceSize(tt.src, tt.dst); got != tt.want {
+			t.Errorf("prereduceSize(%v, %v) = %v, want %v", tt.src, tt.dst, got, tt.want)
+		}
+	}
+}
+
+// smoothImage returns an image of gradients and waves, the kind of content Prereduce is meant for
+func smoothImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			fx, fy := float64(x)/float64(w), float64(y)/float64(h)
+			i := img.PixOffset(x, y)
+			img.Pix[i+0] = uint8(255 * fx)
+			img.Pix[i+1] = uint8(127.5 + 127.5*math.Sin(6*math.Pi*fx)*math.Cos(4*math.Pi*fy))
+			img.Pix[i+2] = uint8(255 * fy)
+			img.Pix[i+3] = 255
+		}
+	}
+
+	return img
+}
+
+func TestPrereduceMatchesDirect(t *testing.T) {
+	p := NewImageTK()
+	src := smoothImage(1600, 1200)
+
+	tests := []struct {
+		name   string
+		w, h   int
+		interp InterpolationFunction
+		gamma  GammaMode
+	}{
+		{"lanczos3", 100, 75, Lanczos3, GammaNone},
+		{"lanczos3 aspect", 123, 0, Lanczos3, GammaNone},
+		{"bilinear", 50, 40, Bilinear, GammaNone},
+		{"mitchell", 200, 150, MitchellNetravali, GammaNone},
+		{"area", 64, 48, Area, GammaNone},
+		{"srgb", 100, 75, Lanczos3, GammaSRGB},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			direct := p.ResizeImage(tt.w, tt.h, src, ResizeOptions{Interpolation: tt.interp, Gamma: tt.gamma}).(*image.RGBA)
+			reduced := p.ResizeImage(tt.w, tt.h, src, ResizeOptions{Interpolation: tt.interp, Gamma: tt.gamma, Prereduce: true}).(*image.RGBA)
+
+			if reduced.Rect != direct.Rect {
+				t.Fatalf("bounds %v, want %v", reduced.Rect, direct.Rect)
+			}
+
+			var sum, maxT float64
+			for i := range direct.Pix {
+				d := math.Abs(float64(direct.Pix[i]) - float64(reduced.Pix[i]))
+				sum += d
+				maxT = math.Max(maxT, d)
+			}
+
+			// the reduced image is rounded to 8 bits, so the results differ by a few levels at most
+			if meanT := sum / float64(len(direct.Pix)); meanT > 1 || maxT > 4 {
+				t.Errorf("mean difference %.3f, max %v", meanT, maxT)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package imagetk
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sync"
+
+	"golang.org/x/image/tiff"
+)
+
+// EncodeOptions holds the options used when encoding images, nil means default options.
+// There is no progressive option, none of the encoders(JPEG, PNG, GIF and the others) writes progressive
+// or interlaced images.
+type EncodeOptions struct {
+	// Quality is the JPEG quality (1-100), 0 means the default quality
+	Quality int
+
+	// PNGCompression is the PNG compression level, such as png.BestSpeed or png.NoCompression
+	PNGCompression png.CompressionLevel
+
+	// NumColors is the maximum number of colors of the GIF palette (1-256), 0 means 256
+	NumColors int
+	// Quantizer is used to produce the GIF palette, nil means palette.Plan9
+	Quantizer draw.Quantizer
+	// Drawer is used to convert the image into the GIF palette, nil means draw.FloydSteinberg
+	Drawer draw.Drawer
+	// SharedPalette makes animated GIFs use one global palette for all frames instead of a palette per frame
+	SharedPalette bool
+
+	// TIFFCompression is the TIFF compression type, tiff.Uncompressed or tiff.Deflate, the other types could
+	// only be decoded and result in ErrUnsupportedFormat
+	TIFFCompression tiff.CompressionType
+	// TIFFPredictor asks for the TIFF differencing predictor, golang.org/x/image/tiff only applies it with LZW,
+	// which it could not write, so it has no effect on the written files for now
+	TIFFPredictor bool
+
+	// NetpbmPlain writes PBM/PGM/PPM in the plain(ASCII) form instead of the raw(binary) form
+	NetpbmPlain bool
+
+	// QOIChannels is the number of QOI channels, 3 for RGB or 4 for RGBA, 0 means 3 for opaque images otherwise 4
+	QOIChannels int
+	// QOILinear marks the QOI image as all channels linear instead of sRGB
+	QOILinear bool
+
+	// EXIF is the TIFF structured EXIF payload(such as EXIFInfo.Raw) written into JPEG as an APP1 segment
+	// or into PNG as an eXIf chunk, nil means no metadata is written
+	EXIF []byte
+
+	// PNGMeta holds the text, pHYs, gAMA, sRGB, iCCP and other ancillary chunks written into PNG
+	PNGMeta *PNGMeta
+}
+
+var (
+	encodersMutexG sync.RWMutex
+	encodersG      = map[string]func(io.Writer, image.Image, *EncodeOptions) error{}
+)
+
+// RegisterEncoder registers an encoder for the format name, replacing any existing one
+func RegisterEncoder(nameA string, encodeA func(io.Writer, image.Image, *EncodeOptions) error) {
+	encodersMutexG.Lock()
+	defer encodersMutexG.Unlock()
+
+	encodersG[normalizeFormat(nameA)] = encodeA
+}
+
+func (p *ImageTK) RegisterEncoder(nameA string, encodeA func(io.Writer, image.Image, *EncodeOptions) error) {
+	RegisterEncoder(nameA, encodeA)
+}
+
+func lookupEncoder(nameA string) func(io.Writer, image.Image, *EncodeOptions) error {
+	encodersMutexG.RLock()
+	defer encodersMutexG.RUnlock()
+
+	return encodersG[nameA]
+}
+
+func init() {
+	RegisterEncoder("png", encodePNG)
+	RegisterEncoder("jpeg", encodeJPEG)
+	RegisterEncoder("gif", encodeGIF)
+}
+
+func encodePNG(w io.Writer, imageA image.Image, optsA *EncodeOptions) error {
+	if optsA == nil {
+		return png.Encode(w, imageA)
+	}
+
+	encoderT := &png.Encoder{CompressionLevel: optsA.PNGCompression}
+
+	if optsA.PNGMeta == nil && len(optsA.EXIF) < 1 {
+		return encoderT.Encode(w, imageA)
+	}
+
+	var bufT bytes.Buffer
+
+	errT := encoderT.Encode(&bufT, imageA)
+	if errT != nil {
+		return errT
+	}
+
+	return insertPNGMeta(w, &bufT, optsA.PNGMeta, optsA.EXIF)
+}
+
+func encodeJPEG(w io.Writer, imageA image.Image, optsA *EncodeOptions) error {
+	var jpegOptsT *jpeg.Options
+
+	if optsA != nil && optsA.Quality > 0 {
+		jpegOptsT = &jpeg.Options{Quality: optsA.Quality}
+	}
+
+	if optsA == nil || len(optsA.EXIF) < 1 {
+		return jpeg.Encode(w, imageA, jpegOptsT)
+	}
+
+	var bufT bytes.Buffer
+
+	errT := jpeg.Encode(&bufT, imageA, jpegOptsT)
+	if errT != nil {
+		return errT
+	}
+
+	return writeJPEGWithEXIF(w, bufT.Bytes(), optsA.EXIF)
+}
+
+func encodeGIF(w io.Writer, imageA image.Image, optsA *EncodeOptions) error {
+	if optsA == nil {
+		return gif.Encode(w, imageA, nil)
+	}
+
+	return gif.Encode(w, imageA, &gif.Options{NumColors: optsA.NumColors, Quantizer: optsA.Quantizer, Drawer: optsA.Drawer})
+}
+
+func encodeImage(w io.Writer, imageA image.Image, formatA string, optsA *EncodeOptions) error {
+	formatT := normalizeFormat(formatA)
+
+	encodeT := lookupEncoder(formatT)
+	if encodeT == nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedFormat, formatA)
+	}
+
+	return encodeT(w, imageA, optsA)
+}
+
+// DecodeFrom decodes an image from the reader, returns the image and the format name detected from the content,
+// optsA could be *DecodeOptions or DecodeOptions
+func (p *ImageTK) DecodeFrom(readerA io.Reader, optsA ...interface{}) (image.Image, string, error) {
+	return decodeImageWithOptions(readerA, getDecodeOptions(optsA))
+}
+
+// DecodeBytes decodes an image from the bytes, returns the image and the format name detected from the content,
+// optsA could be *DecodeOptions or DecodeOptions
+func (p *ImageTK) DecodeBytes(dataA []byte, optsA ...interface{}) (image.Image, string, error) {
+	return decodeImageWithOptions(bytes.NewReader(dataA), getDecodeOptions(optsA))
+}
+
+// EncodeTo encodes the image in the format(such as "png", ".jpg") to the writer, optsA could be nil
+func (p *ImageTK) EncodeTo(writerA io.Writer, imageA image.Image, formatA string, optsA *EncodeOptions) error {
+	return encodeImage(writerA, imageA, formatA, optsA)
+}
+
+// EncodeToBytes encodes the image in the format(such as "png", ".jpg") and returns the bytes, optsA could be nil
+func (p *ImageTK) EncodeToBytes(imageA image.Image, formatA string, optsA *EncodeOptions) ([]byte, error) {
+	var bufT bytes.Buffer
+
+	errT := encodeImage(&bufT, imageA, formatA, optsA)
+	if errT != nil {
+		return nil, errT
+	}
+
+	return bufT.Bytes(), nil
+}
@@ -0,0 +1,155 @@
+package imagetk
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"testing"
+)
+
+// fillPattern fills the image with a pattern of colors, translucent if alphaA is true
+func fillPattern(img draw.Image, alphaA bool) draw.Image {
+	b := img.Bounds()
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBA64{uint16(x * 0x1357), uint16(y * 0x2468), uint16((x ^ y) * 0x0f0f), 0xffff}
+			if alphaA {
+				c.A = uint16((x + y) * 0x1111)
+			}
+
+			img.Set(x, y, c)
+		}
+	}
+
+	return img
+}
+
+// sameImage reports the first pixel where the images differ, compared as NRGBA64 relative to their bounds
+func sameImage(t *testing.T, got, want image.Image) {
+	t.Helper()
+
+	gb, wb := got.Bounds(), want.Bounds()
+	if gb.Size() != wb.Size() {
+		t.Fatalf("size %v, want %v", gb.Size(), wb.Size())
+	}
+
+	for y := 0; y < wb.Dy(); y++ {
+		for x := 0; x < wb.Dx(); x++ {
+			g := color.NRGBA64Model.Convert(got.At(gb.Min.X+x, gb.Min.Y+y))
+			w := color.NRGBA64Model.Convert(want.At(wb.Min.X+x, wb.Min.Y+y))
+			if g != w {
+				t.Fatalf("pixel (%v,%v) is %v, want %v", x, y, g, w)
+			}
+		}
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	p := NewImageTK()
+
+	paletted := image.NewPaletted(image.Rect(0, 0, 6, 4), color.Palette{color.Black, color.White, color.RGBA{255, 0, 0, 255}})
+	for i := range paletted.Pix {
+		paletted.Pix[i] = uint8(i % 3)
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		img    image.Image
+		want   string
+	}{
+		{"png", "png", fillPattern(image.NewNRGBA(image.Rect(0, 0, 7, 5)), true), "png"},
+		{"png 16-bit", ".PNG", fillPattern(image.NewNRGBA64(image.Rect(0, 0, 7, 5)), true), "png"},
+		{"png offset", "png", fillPattern(image.NewRGBA(image.Rect(3, 2, 9, 6)), false), "png"},
+		{"gif", "gif", paletted, "gif"},
+		{"jpeg gray", "jpg", image.NewGray(image.Rect(0, 0, 8, 8)), "jpeg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataT, err := p.EncodeToBytes(tt.img, tt.format, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var b bytes.Buffer
+			if err := p.EncodeTo(&b, tt.img, tt.format, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b.Bytes(), dataT) {
+				t.Error("EncodeTo and EncodeToBytes differ")
+			}
+
+			got, formatT, err := p.DecodeBytes(dataT)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if formatT != tt.want {
+				t.Errorf("format %v, want %v", formatT, tt.want)
+			}
+
+			sameImage(t, got, tt.img)
+
+			// a plain reader without Peek
+			got, formatT, err = p.DecodeFrom(struct{ io.Reader }{bytes.NewReader(dataT)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if formatT != tt.want {
+				t.Errorf("format %v, want %v", formatT, tt.want)
+			}
+
+			sameImage(t, got, tt.img)
+		})
+	}
+}
+
+func TestEncodeDecodeErrors(t *testing.T) {
+	p := NewImageTK()
+	img := image.NewGray(image.Rect(0, 0, 2, 2))
+
+	if _, err := p.EncodeToBytes(img, "xyz", nil); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("encode: got %v, want ErrUnsupportedFormat", err)
+	}
+
+	if _, _, err := p.DecodeBytes([]byte("not an image")); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("decode: got %v, want ErrUnknownFormat", err)
+	}
+
+	if _, _, err := p.DecodeBytes(nil); err == nil {
+		t.Error("decode of no data: no error")
+	}
+
+	if _, _, err := p.DecodeFrom(bytes.NewReader([]byte("\x89PNG\r\n\x1a\n\x00\x00"))); err == nil {
+		t.Error("decode of a truncated PNG: no error")
+	}
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	p := NewImageTK()
+
+	var gotOpts *EncodeOptions
+	RegisterEncoder(".TestRaw", func(w io.Writer, img image.Image, optsA *EncodeOptions) error {
+		gotOpts = optsA
+		_, err := w.Write([]byte("raw"))
+		return err
+	})
+
+	optsT := &EncodeOptions{}
+
+	dataT, err := p.EncodeToBytes(image.NewGray(image.Rect(0, 0, 1, 1)), "testraw", optsT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(dataT) != "raw" || gotOpts != optsT {
+		t.Errorf("got %q with %v", dataT, gotOpts)
+	}
+}
@@ -0,0 +1,235 @@
+package imagetk
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/url"
+	"strings"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+)
+
+// ErrInvalidDataURI is returned when the string is not a valid data URI
+var ErrInvalidDataURI = errors.New("invalid data URI")
+
+var mimeTypesG = map[string]string{
+	"png":      "image/png",
+	"jpeg":     "image/jpeg",
+	"gif":      "image/gif",
+	"bmp":      "image/bmp",
+	"tiff":     "image/tiff",
+	"webp":     "image/webp",
+	"ico":      "image/x-icon",
+	"cur":      "image/x-icon",
+	"psd":      "image/vnd.adobe.photoshop",
+	"heic":     "image/heic",
+	"avif":     "image/avif",
+	"jxl":      "image/jxl",
+	"jp2":      "image/jp2",
+	"qoi":      "image/qoi",
+	"farbfeld": "image/x-farbfeld",
+	"pbm":      "image/x-portable-bitmap",
+	"pgm":      "image/x-portable-graymap",
+	"ppm":      "image/x-portable-pixmap",
+	"pam":      "image/x-portable-arbitrarymap",
+	"svg":      "image/svg+xml",
+	"pdf":      "application/pdf",
+	"eps":      "application/postscript",
+}
+
+// MIMEType returns the MIME type of the format(such as "png", ".jpg"), "application/octet-stream" if unknown
+func (p *ImageTK) MIMEType(formatA string) string {
+	return mimeTypeOf(formatA)
+}
+
+func mimeTypeOf(formatA string) string {
+	if v, ok := mimeTypesG[normalizeFormat(formatA)]; ok {
+		return v
+	}
+
+	return "application/octet-stream"
+}
+
+// FormatFromMIME returns the format name of the MIME type, "" if unknown
+func (p *ImageTK) FormatFromMIME(mimeA string) string {
+	mimeA = strings.ToLower(strings.TrimSpace(mimeA))
+
+	// aliases not in the table
+	switch mimeA {
+	case "image/jpg", "image/pjpeg":
+		return "jpeg"
+	case "image/vnd.microsoft.icon":
+		return "ico"
+	case "image/x-ms-bmp":
+		return "bmp"
+	}
+
+	for k, v := range mimeTypesG {
+		if v == mimeA && k != "cur" {
+			return k
+		}
+	}
+
+	return ""
+}
+
+// EncodeBase64To encodes the image in the format to the writer as standard base64, optsA could be nil
+func (p *ImageTK) EncodeBase64To(writerA io.Writer, imageA image.Image, formatA string, optsA *EncodeOptions) error {
+	encoderT := base64.NewEncoder(base64.StdEncoding, writerA)
+
+	errT := encodeImage(encoderT, imageA, formatA, optsA)
+	if errT != nil {
+		return errT
+	}
+
+	return encoderT.Close()
+}
+
+// EncodeToBase64 encodes the image in the format and returns the standard base64 string, optsA could be nil
+func (p *ImageTK) EncodeToBase64(imageA image.Image, formatA string, optsA *EncodeOptions) (string, error) {
+	var sb strings.Builder
+
+	errT := p.EncodeBase64To(&sb, imageA, formatA, optsA)
+	if errT != nil {
+		return "", errT
+	}
+
+	return sb.String(), nil
+}
+
+// newBase64Reader decodes standard or URL-safe base64, padded or not, ignoring whitespace
+func newBase64Reader(strA string) io.Reader {
+	strA = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return -1
+		case '-':
+			return '+'
+		case '_':
+			return '/'
+		}
+
+		return r
+	}, strA)
+
+	return base64.NewDecoder(base64.RawStdEncoding, strings.NewReader(strings.TrimRight(strA, "=")))
+}
+
+// DecodeBase64 decodes an image from the base64 string, the format is detected from the content.
+// Optional arguments are the same as DecodeFrom.
+func (p *ImageTK) DecodeBase64(strA string, optsA ...interface{}) (image.Image, string, error) {
+	return decodeImageWithOptions(newBase64Reader(strA), getDecodeOptions(optsA))
+}
+
+// dataURIPayload returns the declared MIME type and a reader of the data of the data URI
+func dataURIPayload(strA string) (string, io.Reader, error) {
+	strA = strings.TrimSpace(strA)
+
+	if len(strA) < 5 || !strings.EqualFold(strA[:5], "data:") {
+		return "", nil, ErrInvalidDataURI
+	}
+
+	headerT, dataT, ok := strings.Cut(strA[5:], ",")
+	if !ok {
+		return "", nil, ErrInvalidDataURI
+	}
+
+	partsT := strings.Split(headerT, ";")
+
+	mimeT := strings.ToLower(strings.TrimSpace(partsT[0]))
+	if mimeT == "" {
+		mimeT = "text/plain"
+	}
+
+	if strings.EqualFold(strings.TrimSpace(partsT[len(partsT)-1]), "base64") && len(partsT) > 1 {
+		return mimeT, newBase64Reader(dataT), nil
+	}
+
+	unescapedT, errT := url.PathUnescape(dataT)
+	if errT != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidDataURI, errT)
+	}
+
+	return mimeT, strings.NewReader(unescapedT), nil
+}
+
+// ParseDataURI returns the declared MIME type and the data of the data URI
+func (p *ImageTK) ParseDataURI(strA string) (string, []byte, error) {
+	mimeT, r, errT := dataURIPayload(strA)
+	if errT != nil {
+		return "", nil, errT
+	}
+
+	dataT, errT := io.ReadAll(r)
+	if errT != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidDataURI, errT)
+	}
+
+	return mimeT, dataT, nil
+}
+
+// FromDataURI decodes the image in the data URI, the format is detected from the content instead of the declared MIME type.
+// Optional arguments are the same as DecodeFrom.
+func (p *ImageTK) FromDataURI(strA string, optsA ...interface{}) (image.Image, string, error) {
+	_, r, errT := dataURIPayload(strA)
+	if errT != nil {
+		return nil, "", errT
+	}
+
+	return decodeImageWithOptions(r, getDecodeOptions(optsA))
+}
+
+// ToDataURI encodes the image in the format(png if empty) as a base64 data URI, optsA could be nil
+func (p *ImageTK) ToDataURI(imageA image.Image, formatA string, optsA *EncodeOptions) (string, error) {
+	if formatA == "" {
+		formatA = "png"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("data:" + mimeTypeOf(formatA) + ";base64,")
+
+	errT := p.EncodeBase64To(&sb, imageA, formatA, optsA)
+	if errT != nil {
+		return "", errT
+	}
+
+	return sb.String(), nil
+}
+
+// BytesToDataURI returns the base64 data URI of the encoded data, the MIME type is detected from the content if mimeA is empty
+func (p *ImageTK) BytesToDataURI(dataA []byte, mimeA string) string {
+	if mimeA == "" {
+		mimeA = mimeTypeOf(detectFormat(dataA))
+	}
+
+	return "data:" + mimeA + ";base64," + base64.StdEncoding.EncodeToString(dataA)
+}
+
+// LoadPlotDataURI renders the plot in the format(such as "png", "jpg", "svg") and returns it as a base64 data URI
+func (p *ImageTK) LoadPlotDataURI(plotA *plot.Plot, w vg.Length, h vg.Length, formatA string) (string, error) {
+	writerT, errT := plotA.WriterTo(w, h, formatA)
+	if errT != nil {
+		return "", errT
+	}
+
+	var sb strings.Builder
+	sb.WriteString("data:" + mimeTypeOf(formatA) + ";base64,")
+
+	encoderT := base64.NewEncoder(base64.StdEncoding, &sb)
+
+	_, errT = writerT.WriteTo(encoderT)
+	if errT != nil {
+		return "", errT
+	}
+
+	errT = encoderT.Close()
+	if errT != nil {
+		return "", errT
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,228 @@
+package imagetk
+
+import (
+	"encoding/base64"
+	"errors"
+	"image"
+	"strings"
+	"testing"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+)
+
+func TestParseDataURI(t *testing.T) {
+	p := NewImageTK()
+
+	tests := []struct {
+		name string
+		uri  string
+		mime string
+		data string
+	}{
+		{"base64", "data:image/png;base64,aGVsbG8=", "image/png", "hello"},
+		{"unpadded", "data:image/png;base64,aGVsbG8", "image/png", "hello"},
+		{"url-safe", "data:application/octet-stream;base64,-_8=", "application/octet-stream", "\xfb\xff"},
+		{"whitespace", " data:image/gif;base64,aGVs\n bG8= ", "image/gif", "hello"},
+		{"parameters", "data:text/plain;charset=utf-8;base64,aGk=", "text/plain", "hi"},
+		{"uppercase scheme", "DATA:Image/PNG;BASE64,aGk=", "image/png", "hi"},
+		{"percent-encoded", "data:,a%20b%2Cc", "text/plain", "a b,c"},
+		{"not base64 parameter", "data:text/plain;charset=base64x,abc", "text/plain", "abc"},
+		{"empty data", "data:image/png;base64,", "image/png", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mimeT, dataT, err := p.ParseDataURI(tt.uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if mimeT != tt.mime || string(dataT) != tt.data {
+				t.Errorf("got %q %q, want %q %q", mimeT, dataT, tt.mime, tt.data)
+			}
+		})
+	}
+}
+
+func TestParseDataURIErrors(t *testing.T) {
+	p := NewImageTK()
+
+	tests := []struct {
+		name string
+		uri  string
+	}{
+		{"no scheme", "image/png;base64,aGk="},
+		{"other scheme", "http://example.com/a.png"},
+		{"short", "data"},
+		{"no comma", "data:image/png;base64"},
+		{"not base64", "data:image/png;base64,a@b!"},
+		{"bad escape", "data:,%zz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := p.ParseDataURI(tt.uri)
+			if !errors.Is(err, ErrInvalidDataURI) {
+				t.Errorf("got %v, want ErrInvalidDataURI", err)
+			}
+		})
+	}
+}
+
+func TestMIMEType(t *testing.T) {
+	p := NewImageTK()
+
+	tests := []struct {
+		format, mime string
+	}{
+		{"png", "image/png"},
+		{".JPG", "image/jpeg"},
+		{"tif", "image/tiff"},
+		{"svg", "image/svg+xml"},
+		{"pgm", "image/x-portable-graymap"},
+		{"xyz", "application/octet-stream"},
+		{"", "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		if got := p.MIMEType(tt.format); got != tt.mime {
+			t.Errorf("MIMEType(%q) = %q, want %q", tt.format, got, tt.mime)
+		}
+	}
+
+	formats := []struct {
+		mime, format string
+	}{
+		{"image/png", "png"},
+		{" Image/JPEG ", "jpeg"},
+		{"image/jpg", "jpeg"},
+		{"image/pjpeg", "jpeg"},
+		{"image/x-icon", "ico"},
+		{"image/vnd.microsoft.icon", "ico"},
+		{"image/x-ms-bmp", "bmp"},
+		{"image/webp", "webp"},
+		{"text/plain", ""},
+	}
+
+	for _, tt := range formats {
+		if got := p.FormatFromMIME(tt.mime); got != tt.format {
+			t.Errorf("FormatFromMIME(%q) = %q, want %q", tt.mime, got, tt.format)
+		}
+	}
+}
+
+func TestDataURIRoundTrip(t *testing.T) {
+	p := NewImageTK()
+	img := fillPattern(image.NewNRGBA(image.Rect(0, 0, 5, 4)), true)
+
+	tests := []struct {
+		format, prefix, want string
+	}{
+		{"", "data:image/png;base64,", "png"},
+		{"png", "data:image/png;base64,", "png"},
+		{"gif", "data:image/gif;base64,", "gif"},
+		{".jpg", "data:image/jpeg;base64,", "jpeg"},
+	}
+
+	for _, tt := range tests {
+		uriT, err := p.ToDataURI(img, tt.format, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(uriT, tt.prefix) {
+			t.Errorf("%q: got %.40q", tt.format, uriT)
+		}
+
+		got, formatT, err := p.FromDataURI(uriT)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if formatT != tt.want || got.Bounds() != img.Bounds() {
+			t.Errorf("%q: got %v of %v", tt.format, formatT, got.Bounds())
+		}
+
+		if tt.want == "png" {
+			sameImage(t, got, img)
+		}
+	}
+
+	// the format comes from the content, not from the declared MIME type
+	dataT, err := p.EncodeToBytes(img, "png", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base64T := base64.StdEncoding.EncodeToString(dataT)
+
+	got, formatT, err := p.FromDataURI("data:image/jpeg;base64," + base64T)
+	if err != nil || formatT != "png" {
+		t.Fatalf("got %v, %v", formatT, err)
+	}
+
+	sameImage(t, got, img)
+
+	if uriT := p.BytesToDataURI(dataT, ""); uriT != "data:image/png;base64,"+base64T {
+		t.Errorf("got %.40q", uriT)
+	}
+
+	if uriT := p.BytesToDataURI(dataT, "image/x-test"); !strings.HasPrefix(uriT, "data:image/x-test;base64,") {
+		t.Errorf("got %.40q", uriT)
+	}
+
+	got, formatT, err = p.DecodeBase64(base64.RawURLEncoding.EncodeToString(dataT))
+	if err != nil || formatT != "png" {
+		t.Fatalf("got %v, %v", formatT, err)
+	}
+
+	sameImage(t, got, img)
+
+	if _, _, err := p.FromDataURI("data:image/png;base64,aGVsbG8="); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("got %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestLoadPlotDataURI(t *testing.T) {
+	p := NewImageTK()
+
+	plotT := plot.New()
+	plotT.Title.Text = "test"
+
+	tests := []struct {
+		format, prefix string
+	}{
+		{"png", "data:image/png;base64,"},
+		{"jpg", "data:image/jpeg;base64,"},
+		{"svg", "data:image/svg+xml;base64,"},
+	}
+
+	for _, tt := range tests {
+		uriT, err := p.LoadPlotDataURI(plotT, 2*vg.Inch, 1*vg.Inch, tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(uriT, tt.prefix) {
+			t.Errorf("%v: got %.40q", tt.format, uriT)
+			continue
+		}
+
+		mimeT, dataT, err := p.ParseDataURI(uriT)
+		if err != nil || mimeT != p.MIMEType(tt.format) || len(dataT) < 1 {
+			t.Errorf("%v: got %q of %v bytes, %v", tt.format, mimeT, len(dataT), err)
+		}
+
+		if tt.format == "png" {
+			img, _, err := p.FromDataURI(uriT)
+			if err != nil || img.Bounds() != image.Rect(0, 0, 192, 96) {
+				t.Errorf("got %v, %v", img, err)
+			}
+		}
+	}
+
+	if _, err := p.LoadPlotDataURI(plotT, vg.Inch, vg.Inch, "xyz"); err == nil {
+		t.Error("no error for an unknown format")
+	}
+}
@@ -0,0 +1,628 @@
+package imagetk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNoEXIF is returned when the image contains no EXIF data
+var ErrNoEXIF = errors.New("no EXIF data")
+
+var errEXIFFormat = errors.New("exif: invalid format")
+
+const exifHeader = "Exif\x00\x00"
+
+// maxEXIFLength is the largest EXIF chunk read, larger ones are skipped instead of allocated
+const maxEXIFLength = 1 << 24
+
+// GPSInfo holds the GPS information of EXIF, latitude and longitude are in degrees, negative for S/W
+type GPSInfo struct {
+	Latitude  float64
+	Longitude float64
+	// Altitude is in meters, negative below the sea level
+	Altitude float64
+	Time     time.Time
+}
+
+// EXIFInfo holds the commonly used EXIF fields
+type EXIFInfo struct {
+	Make      string
+	Model     string
+	Software  string
+	Artist    string
+	Copyright string
+	LensModel string
+
+	DateTime          time.Time
+	DateTimeOriginal  time.Time
+	DateTimeDigitized time.Time
+
+	// Orientation is 1-8 as defined by EXIF, 0 if not present
+	Orientation int
+
+	XResolution float64
+	YResolution float64
+	// ResolutionUnit is 2 for inches and 3 for centimeters
+	ResolutionUnit int
+
+	PixelWidth  int
+	PixelHeight int
+
+	ExposureTime float64
+	FNumber      float64
+	FocalLength  float64
+	ISO          int
+
+	// GPS is nil if no GPS information
+	GPS *GPSInfo
+
+	// Raw is the TIFF structured EXIF payload(starting with "II" or "MM"),
+	// could be set to EncodeOptions.EXIF to keep the metadata when saving JPEG
+	Raw []byte
+}
+
+// DPI returns the horizontal and vertical resolution in dots per inch, 0 if not present
+func (e *EXIFInfo) DPI() (float64, float64) {
+	if e.ResolutionUnit == 3 {
+		return e.XResolution * 2.54, e.YResolution * 2.54
+	}
+
+	return e.XResolution, e.YResolution
+}
+
+type exifEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	// the value bytes, either inline or at the offset
+	data []byte
+	// the position of the value/offset field in the payload
+	pos int
+}
+
+type exifReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+var exifTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+func newEXIFReader(dataA []byte) (*exifReader, error) {
+	if len(dataA) < 8 {
+		return nil, errEXIFFormat
+	}
+
+	r := &exifReader{data: dataA}
+
+	switch string(dataA[:4]) {
+	case "II*\x00":
+		r.order = binary.LittleEndian
+	case "MM\x00*":
+		r.order = binary.BigEndian
+	default:
+		return nil, errEXIFFormat
+	}
+
+	return r, nil
+}
+
+func (r *exifReader) readIFD(offsetA uint32) (map[uint16]*exifEntry, uint32, error) {
+	if uint64(offsetA)+2 > uint64(len(r.data)) {
+		return nil, 0, errEXIFFormat
+	}
+
+	countT := int(r.order.Uint16(r.data[offsetA:]))
+	posT := int(offsetA) + 2
+
+	if posT+countT*12+4 > len(r.data) {
+		// tolerate a missing next IFD offset
+		if posT+countT*12 > len(r.data) {
+			return nil, 0, errEXIFFormat
+		}
+	}
+
+	entriesT := make(map[uint16]*exifEntry, countT)
+
+	for i := 0; i < countT; i++ {
+		p := posT + i*12
+
+		e := &exifEntry{tag: r.order.Uint16(r.data[p:]), typ: r.order.Uint16(r.data[p+2:]), count: r.order.Uint32(r.data[p+4:]), pos: p + 8}
+
+		sizeT, ok := exifTypeSizes[e.typ]
+		if !ok {
+			continue
+		}
+
+		totalT := uint64(sizeT) * uint64(e.count)
+
+		if totalT <= 4 {
+			e.data = r.data[p+8 : p+8+int(totalT)]
+		} else {
+			offsetT := uint64(r.order.Uint32(r.data[p+8:]))
+			if offsetT+totalT > uint64(len(r.data)) {
+				continue
+			}
+			e.data = r.data[offsetT : offsetT+totalT]
+		}
+
+		entriesT[e.tag] = e
+	}
+
+	var nextT uint32
+	if posT+countT*12+4 <= len(r.data) {
+		nextT = r.order.Uint32(r.data[posT+countT*12:])
+	}
+
+	return entriesT, nextT, nil
+}
+
+func (r *exifReader) uintValue(e *exifEntry, indexA int) uint32 {
+	if e == nil || uint32(indexA) >= e.count {
+		return 0
+	}
+
+	switch e.typ {
+	case 1, 6, 7:
+		return uint32(e.data[indexA])
+	case 3, 8:
+		return uint32(r.order.Uint16(e.data[indexA*2:]))
+	case 4, 9:
+		return r.order.Uint32(e.data[indexA*4:])
+	}
+
+	return 0
+}
+
+func (r *exifReader) ratValue(e *exifEntry, indexA int) float64 {
+	if e == nil || uint32(indexA) >= e.count {
+		return 0
+	}
+
+	switch e.typ {
+	case 5:
+		numT, denT := r.order.Uint32(e.data[indexA*8:]), r.order.Uint32(e.data[indexA*8+4:])
+		if denT == 0 {
+			return 0
+		}
+		return float64(numT) / float64(denT)
+	case 10:
+		numT, denT := int32(r.order.Uint32(e.data[indexA*8:])), int32(r.order.Uint32(e.data[indexA*8+4:]))
+		if denT == 0 {
+			return 0
+		}
+		return float64(numT) / float64(denT)
+	}
+
+	return float64(r.uintValue(e, indexA))
+}
+
+func (r *exifReader) strValue(e *exifEntry) string {
+	if e == nil || e.typ != 2 {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimRight(string(e.data), "\x00"))
+}
+
+// parseEXIFTime parses the EXIF time like "2006:01:02 15:04:05" with an optional offset like "+08:00"
+func parseEXIFTime(strA, offsetA string) time.Time {
+	if strA == "" {
+		return time.Time{}
+	}
+
+	if offsetA != "" {
+		t, errT := time.Parse("2006:01:02 15:04:05-07:00", strA+offsetA)
+		if errT == nil {
+			return t
+		}
+	}
+
+	t, errT := time.ParseInLocation("2006:01:02 15:04:05", strA, time.Local)
+	if errT != nil {
+		return time.Time{}
+	}
+
+	return t
+}
+
+// parseEXIF parses the TIFF structured EXIF payload
+func parseEXIF(rawA []byte) (*EXIFInfo, error) {
+	r, errT := newEXIFReader(rawA)
+	if errT != nil {
+		return nil, errT
+	}
+
+	ifd0T, _, errT := r.readIFD(r.order.Uint32(rawA[4:8]))
+	if errT != nil {
+		return nil, errT
+	}
+
+	info := &EXIFInfo{Raw: rawA}
+
+	info.Make = r.strValue(ifd0T[0x010f])
+	info.Model = r.strValue(ifd0T[0x0110])
+	info.Orientation = int(r.uintValue(ifd0T[0x0112], 0))
+	info.XResolution = r.ratValue(ifd0T[0x011a], 0)
+	info.YResolution = r.ratValue(ifd0T[0x011b], 0)
+	info.ResolutionUnit = int(r.uintValue(ifd0T[0x0128], 0))
+	info.Software = r.strValue(ifd0T[0x0131])
+	info.Artist = r.strValue(ifd0T[0x013b])
+	info.Copyright = r.strValue(ifd0T[0x8298])
+
+	if info.ResolutionUnit == 0 && info.XResolution > 0 {
+		info.ResolutionUnit = 2
+	}
+
+	if info.Orientation < 0 || info.Orientation > 8 {
+		info.Orientation = 0
+	}
+
+	var exifIFDT map[uint16]*exifEntry
+
+	if e := ifd0T[0x8769]; e != nil {
+		exifIFDT, _, _ = r.readIFD(r.uintValue(e, 0))
+	}
+
+	info.DateTime = parseEXIFTime(r.strValue(ifd0T[0x0132]), r.strValue(exifIFDT[0x9010]))
+
+	if exifIFDT != nil {
+		info.DateTimeOriginal = parseEXIFTime(r.strValue(exifIFDT[0x9003]), r.strValue(exifIFDT[0x9011]))
+		info.DateTimeDigitized = parseEXIFTime(r.strValue(exifIFDT[0x9004]), r.strValue(exifIFDT[0x9012]))
+		info.ExposureTime = r.ratValue(exifIFDT[0x829a], 0)
+		info.FNumber = r.ratValue(exifIFDT[0x829d], 0)
+		info.ISO = int(r.uintValue(exifIFDT[0x8827], 0))
+		info.FocalLength = r.ratValue(exifIFDT[0x920a], 0)
+		info.PixelWidth = int(r.uintValue(exifIFDT[0xa002], 0))
+		info.PixelHeight = int(r.uintValue(exifIFDT[0xa003], 0))
+		info.LensModel = r.strValue(exifIFDT[0xa434])
+	}
+
+	if e := ifd0T[0x8825]; e != nil {
+		gpsIFDT, _, errT := r.readIFD(r.uintValue(e, 0))
+		if errT == nil && gpsIFDT[0x0002] != nil && gpsIFDT[0x0004] != nil {
+			gps := &GPSInfo{}
+
+			degreesT := func(eA *exifEntry) float64 {
+				return r.ratValue(eA, 0) + r.ratValue(eA, 1)/60 + r.ratValue(eA, 2)/3600
+			}
+
+			gps.Latitude = degreesT(gpsIFDT[0x0002])
+			if strings.EqualFold(r.strValue(gpsIFDT[0x0001]), "S") {
+				gps.Latitude = -gps.Latitude
+			}
+
+			gps.Longitude = degreesT(gpsIFDT[0x0004])
+			if strings.EqualFold(r.strValue(gpsIFDT[0x0003]), "W") {
+				gps.Longitude = -gps.Longitude
+			}
+
+			gps.Altitude = r.ratValue(gpsIFDT[0x0006], 0)
+			if r.uintValue(gpsIFDT[0x0005], 0) == 1 {
+				gps.Altitude = -gps.Altitude
+			}
+
+			if dateT := r.strValue(gpsIFDT[0x001d]); dateT != "" && gpsIFDT[0x0007] != nil {
+				t, errT := time.Parse("2006:01:02", dateT)
+				if errT == nil {
+					secondsT := r.ratValue(gpsIFDT[0x0007], 0)*3600 + r.ratValue(gpsIFDT[0x0007], 1)*60 + r.ratValue(gpsIFDT[0x0007], 2)
+					gps.Time = t.Add(time.Duration(secondsT * float64(time.Second)))
+				}
+			}
+
+			info.GPS = gps
+		}
+	}
+
+	return info, nil
+}
+
+// setEXIFOrientation changes the orientation tag in the payload in place
+func setEXIFOrientation(rawA []byte, orientationA int) error {
+	r, errT := newEXIFReader(rawA)
+	if errT != nil {
+		return errT
+	}
+
+	ifd0T, _, errT := r.readIFD(r.order.Uint32(rawA[4:8]))
+	if errT != nil {
+		return errT
+	}
+
+	e := ifd0T[0x0112]
+	if e == nil || e.typ != 3 {
+		return fmt.Errorf("exif: no orientation tag")
+	}
+
+	r.order.PutUint16(rawA[e.pos:], uint16(orientationA))
+
+	return nil
+}
+
+// readJPEGEXIF scans the JPEG segments before the image data for the EXIF APP1 segment
+func readJPEGEXIF(r *bufio.Reader) ([]byte, error) {
+	bufT := make([]byte, 4)
+
+	_, errT := io.ReadFull(r, bufT[:2])
+	if errT != nil {
+		return nil, errT
+	}
+
+	if bufT[0] != 0xff || bufT[1] != 0xd8 {
+		return nil, errEXIFFormat
+	}
+
+	for {
+		_, errT = io.ReadFull(r, bufT[:2])
+		if errT != nil {
+			return nil, errT
+		}
+
+		if bufT[0] != 0xff {
+			return nil, errEXIFFormat
+		}
+
+		markerT := bufT[1]
+
+		// fill bytes
+		if markerT == 0xff {
+			r.UnreadByte()
+			continue
+		}
+
+		// start of scan or end of image, no EXIF in the headers
+		if markerT == 0xda || markerT == 0xd9 {
+			return nil, ErrNoEXIF
+		}
+
+		if markerT >= 0xd0 && markerT <= 0xd7 || markerT == 0x01 {
+			continue
+		}
+
+		_, errT = io.ReadFull(r, bufT[:2])
+		if errT != nil {
+			return nil, errT
+		}
+
+		lengthT := int(binary.BigEndian.Uint16(bufT[:2])) - 2
+		if lengthT < 0 {
+			return nil, errEXIFFormat
+		}
+
+		if markerT == 0xe1 && lengthT >= len(exifHeader) {
+			dataT := make([]byte, lengthT)
+
+			_, errT = io.ReadFull(r, dataT)
+			if errT != nil {
+				return nil, errT
+			}
+
+			if string(dataT[:len(exifHeader)]) == exifHeader {
+				return dataT[len(exifHeader):], nil
+			}
+
+			continue
+		}
+
+		_, errT = r.Discard(lengthT)
+		if errT != nil {
+			return nil, errT
+		}
+	}
+}
+
+// readPNGEXIF scans the PNG chunks for the eXIf chunk
+func readPNGEXIF(r *bufio.Reader) ([]byte, error) {
+	sigT := make([]byte, len(pngSignature))
+
+	_, errT := io.ReadFull(r, sigT)
+	if errT != nil || string(sigT) != pngSignature {
+		return nil, errPNGFormat
+	}
+
+	headerT := make([]byte, 8)
+
+	for {
+		_, errT = io.ReadFull(r, headerT)
+		if errT != nil {
+			return nil, ErrNoEXIF
+		}
+
+		lengthT := int64(binary.BigEndian.Uint32(headerT[:4]))
+		typeT := string(headerT[4:8])
+
+		if typeT == "IEND" {
+			return nil, ErrNoEXIF
+		}
+
+		if typeT == "eXIf" && lengthT < maxEXIFLength {
+			dataT := make([]byte, lengthT)
+
+			_, errT = io.ReadFull(r, dataT)
+			if errT != nil {
+				return nil, errT
+			}
+
+			return dataT, nil
+		}
+
+		_, errT = io.CopyN(io.Discard, r, lengthT+4)
+		if errT != nil {
+			return nil, ErrNoEXIF
+		}
+	}
+}
+
+// readWebPEXIF scans the RIFF chunks of WebP for the EXIF chunk
+func readWebPEXIF(r *bufio.Reader) ([]byte, error) {
+	headerT := make([]byte, 12)
+
+	_, errT := io.ReadFull(r, headerT)
+	if errT != nil || string(headerT[:4]) != "RIFF" || string(headerT[8:12]) != "WEBP" {
+		return nil, errEXIFFormat
+	}
+
+	for {
+		_, errT = io.ReadFull(r, headerT[:8])
+		if errT != nil {
+			return nil, ErrNoEXIF
+		}
+
+		lengthT := int64(binary.LittleEndian.Uint32(headerT[4:8]))
+
+		if string(headerT[:4]) == "EXIF" && lengthT < maxEXIFLength {
+			dataT := make([]byte, lengthT)
+
+			_, errT = io.ReadFull(r, dataT)
+			if errT != nil {
+				return nil, errT
+			}
+
+			return bytes.TrimPrefix(dataT, []byte(exifHeader)), nil
+		}
+
+		// chunks are padded to even sizes
+		_, errT = io.CopyN(io.Discard, r, lengthT+lengthT&1)
+		if errT != nil {
+			return nil, ErrNoEXIF
+		}
+	}
+}
+
+// readEXIFPayload reads the raw EXIF payload from JPEG, PNG, WebP or TIFF streams
+func readEXIFPayload(r io.Reader) ([]byte, error) {
+	br := bufio.NewReader(r)
+
+	formatT, errT := sniffFormat(br)
+	if errT != nil {
+		return nil, errT
+	}
+
+	switch formatT {
+	case "jpeg":
+		return readJPEGEXIF(br)
+	case "png":
+		return readPNGEXIF(br)
+	case "webp":
+		return readWebPEXIF(br)
+	case "tiff":
+		// the EXIF tags live in the IFDs of the TIFF file itself
+		return io.ReadAll(br)
+	}
+
+	return nil, ErrNoEXIF
+}
+
+// ReadEXIF reads the EXIF information from a JPEG, PNG, WebP or TIFF stream
+func (p *ImageTK) ReadEXIF(readerA io.Reader) (*EXIFInfo, error) {
+	rawT, errT := readEXIFPayload(readerA)
+	if errT != nil {
+		return nil, errT
+	}
+
+	return parseEXIF(rawT)
+}
+
+// LoadEXIF reads the EXIF information from an image file
+func (p *ImageTK) LoadEXIF(fileNameA string) (*EXIFInfo, error) {
+	fileT, errT := os.Open(fileNameA)
+	if errT != nil {
+		return nil, errT
+	}
+	defer fileT.Close()
+
+	return p.ReadEXIF(fileT)
+}
+
+// ParseEXIF parses a TIFF structured EXIF payload, such as the content of a JPEG APP1 segment after "Exif\0\0"
+func (p *ImageTK) ParseEXIF(rawA []byte) (*EXIFInfo, error) {
+	return parseEXIF(rawA)
+}
+
+// SetEXIFOrientation changes the orientation tag in the EXIF payload in place,
+// usually set to 1 after the orientation has been applied to the pixels
+func (p *ImageTK) SetEXIFOrientation(rawA []byte, orientationA int) error {
+	return setEXIFOrientation(rawA, orientationA)
+}
+
+// writeJPEGWithEXIF writes the encoded JPEG with an EXIF APP1 segment inserted after SOI
+func writeJPEGWithEXIF(w io.Writer, jpegA []byte, exifA []byte) error {
+	if len(jpegA) < 2 || jpegA[0] != 0xff || jpegA[1] != 0xd8 {
+		return errEXIFFormat
+	}
+
+	lengthT := 2 + len(exifHeader) + len(exifA)
+	if lengthT > math.MaxUint16 {
+		return fmt.Errorf("exif: payload too large (%v bytes)", len(exifA))
+	}
+
+	segmentT := make([]byte, 4, 4+lengthT)
+	segmentT[0], segmentT[1] = 0xff, 0xe1
+	binary.BigEndian.PutUint16(segmentT[2:4], uint16(lengthT))
+	segmentT = append(segmentT, exifHeader...)
+	segmentT = append(segmentT, exifA...)
+
+	for _, v := range [][]byte{jpegA[:2], segmentT, jpegA[2:]} {
+		_, errT := w.Write(v)
+		if errT != nil {
+			return errT
+		}
+	}
+
+	return nil
+}
+
+// StripJPEGMetadata removes the EXIF/XMP(APP1), IPTC(APP13), other application segments and comments
+// from the JPEG data without re-encoding. JFIF(APP0), ICC profiles(APP2) and Adobe(APP14) segments
+// are kept since they affect how the colors are decoded.
+func (p *ImageTK) StripJPEGMetadata(dataA []byte) ([]byte, error) {
+	if len(dataA) < 2 || dataA[0] != 0xff || dataA[1] != 0xd8 {
+		return nil, errEXIFFormat
+	}
+
+	var bufT bytes.Buffer
+	bufT.Write(dataA[:2])
+
+	posT := 2
+
+	for {
+		if posT+4 > len(dataA) || dataA[posT] != 0xff {
+			return nil, errEXIFFormat
+		}
+
+		markerT := dataA[posT+1]
+
+		if markerT == 0xff {
+			posT++
+			continue
+		}
+
+		// the rest from start of scan is the image data
+		if markerT == 0xda || markerT == 0xd9 {
+			bufT.Write(dataA[posT:])
+			return bufT.Bytes(), nil
+		}
+
+		lengthT := int(binary.BigEndian.Uint16(dataA[posT+2:]))
+		endT := posT + 2 + lengthT
+
+		if lengthT < 2 || endT > len(dataA) {
+			return nil, errEXIFFormat
+		}
+
+		stripT := markerT == 0xfe || (markerT >= 0xe1 && markerT <= 0xef && markerT != 0xe2 && markerT != 0xee)
+
+		if !stripT {
+			bufT.Write(dataA[posT:endT])
+		}
+
+		posT = endT
+	}
+}
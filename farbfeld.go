@@ -0,0 +1,108 @@
+package imagetk
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+)
+
+// farbfeld codec, "farbfeld" + width(uint32 BE) + height(uint32 BE) + 16-bit BE non-premultiplied RGBA pixels,
+// which is exactly the pixel layout of image.NRGBA64
+
+const farbfeldMagic = "farbfeld"
+
+var errFarbfeldFormat = errors.New("farbfeld: invalid format")
+
+func decodeFarbfeldConfig(r io.Reader) (image.Config, error) {
+	headerT := make([]byte, 16)
+
+	_, errT := io.ReadFull(r, headerT)
+	if errT != nil {
+		return image.Config{}, errT
+	}
+
+	if string(headerT[:8]) != farbfeldMagic {
+		return image.Config{}, errFarbfeldFormat
+	}
+
+	widthT := binary.BigEndian.Uint32(headerT[8:12])
+	heightT := binary.BigEndian.Uint32(headerT[12:16])
+
+	if widthT < 1 || heightT < 1 {
+		return image.Config{}, errFarbfeldFormat
+	}
+
+	if uint64(widthT)*uint64(heightT) > 1<<31 {
+		return image.Config{}, fmt.Errorf("farbfeld: image too large (%vx%v)", widthT, heightT)
+	}
+
+	return image.Config{ColorModel: color.NRGBA64Model, Width: int(widthT), Height: int(heightT)}, nil
+}
+
+func decodeFarbfeld(r io.Reader) (image.Image, error) {
+	configT, errT := decodeFarbfeldConfig(r)
+	if errT != nil {
+		return nil, errT
+	}
+
+	imgT := image.NewNRGBA64(image.Rect(0, 0, configT.Width, configT.Height))
+
+	// read row by row so a truncated file fails before the whole buffer is touched
+	for y := 0; y < configT.Height; y++ {
+		_, errT = io.ReadFull(r, imgT.Pix[y*imgT.Stride:(y+1)*imgT.Stride])
+		if errT != nil {
+			if errT == io.EOF {
+				errT = io.ErrUnexpectedEOF
+			}
+			return nil, errT
+		}
+	}
+
+	return imgT, nil
+}
+
+func encodeFarbfeld(w io.Writer, imageA image.Image, optsA *EncodeOptions) error {
+	boundsT := imageA.Bounds()
+
+	imgT, ok := imageA.(*image.NRGBA64)
+	if !ok {
+		imgT = image.NewNRGBA64(image.Rect(0, 0, boundsT.Dx(), boundsT.Dy()))
+		draw.Draw(imgT, imgT.Bounds(), imageA, boundsT.Min, draw.Src)
+	}
+
+	bw := bufio.NewWriter(w)
+
+	headerT := make([]byte, 16)
+	copy(headerT, farbfeldMagic)
+	binary.BigEndian.PutUint32(headerT[8:12], uint32(boundsT.Dx()))
+	binary.BigEndian.PutUint32(headerT[12:16], uint32(boundsT.Dy()))
+
+	_, errT := bw.Write(headerT)
+	if errT != nil {
+		return errT
+	}
+
+	rowLenT := boundsT.Dx() * 8
+
+	for y := 0; y < boundsT.Dy(); y++ {
+		offsetT := y * imgT.Stride
+		_, errT = bw.Write(imgT.Pix[offsetT : offsetT+rowLenT])
+		if errT != nil {
+			return errT
+		}
+	}
+
+	return bw.Flush()
+}
+
+func init() {
+	RegisterFormat("farbfeld", farbfeldMagic, decodeFarbfeld, decodeFarbfeldConfig)
+	image.RegisterFormat("farbfeld", farbfeldMagic, decodeFarbfeld, decodeFarbfeldConfig)
+
+	RegisterEncoder("farbfeld", encodeFarbfeld)
+}
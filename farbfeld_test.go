@@ -0,0 +1,67 @@
+package imagetk
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFarbfeldRoundTrip(t *testing.T) {
+	p := NewImageTK()
+
+	nrgba64 := fillPattern(image.NewNRGBA64(image.Rect(0, 0, 9, 4)), true).(*image.NRGBA64)
+
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"nrgba64", nrgba64},
+		{"nrgba64 sub-image", nrgba64.SubImage(image.Rect(3, 1, 8, 4))},
+		{"nrgba", fillPattern(image.NewNRGBA(image.Rect(0, 0, 9, 4)), true)},
+		{"gray16", fillPattern(image.NewGray16(image.Rect(0, 0, 9, 4)), false)},
+		{"rgba offset", fillPattern(image.NewRGBA(image.Rect(-2, 3, 5, 8)), false)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataT, err := p.EncodeToBytes(tt.img, "farbfeld", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := 16 + 8*tt.img.Bounds().Dx()*tt.img.Bounds().Dy(); len(dataT) != want {
+				t.Errorf("encoded %v bytes, want %v", len(dataT), want)
+			}
+
+			got, formatT, err := p.DecodeBytes(dataT)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if formatT != "farbfeld" {
+				t.Errorf("format %v", formatT)
+			}
+
+			if got.ColorModel() != color.NRGBA64Model {
+				t.Errorf("got %T", got)
+			}
+
+			sameImage(t, got, tt.img)
+		})
+	}
+}
+
+func TestFarbfeldTruncated(t *testing.T) {
+	p := NewImageTK()
+
+	dataT, err := p.EncodeToBytes(image.NewNRGBA64(image.Rect(0, 0, 3, 3)), "farbfeld", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{12, 16, len(dataT) - 1} {
+		if _, _, err := p.DecodeBytes(dataT[:n]); err == nil {
+			t.Errorf("no error for %v bytes", n)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package imagetk
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrUnknownFormat is returned when the content of an image does not match any known format
+	ErrUnknownFormat = errors.New("unknown image format")
+	// ErrUnsupportedFormat is returned when the format is recognized but no decoder/encoder is registered for it
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+)
+
+// ImageFormat describes an image format registered to ImageTK. Magic is the
+// prefix used to sniff the format, '?' matches any single byte. Decode and
+// DecodeConfig may be nil for formats which are only detected.
+type ImageFormat struct {
+	Name         string
+	Magic        string
+	Decode       func(io.Reader) (image.Image, error)
+	DecodeConfig func(io.Reader) (image.Config, error)
+}
+
+var (
+	formatsMutexG sync.RWMutex
+	formatsG      []ImageFormat
+)
+
+// RegisterFormat registers an image format for content sniffing and decoding.
+// Formats registered later take precedence over earlier ones with the same magic or name.
+func RegisterFormat(nameA, magicA string, decodeA func(io.Reader) (image.Image, error), decodeConfigA func(io.Reader) (image.Config, error)) {
+	formatsMutexG.Lock()
+	defer formatsMutexG.Unlock()
+
+	formatsG = append(formatsG, ImageFormat{Name: normalizeFormat(nameA), Magic: magicA, Decode: decodeA, DecodeConfig: decodeConfigA})
+}
+
+func (p *ImageTK) RegisterFormat(nameA, magicA string, decodeA func(io.Reader) (image.Image, error), decodeConfigA func(io.Reader) (image.Config, error)) {
+	RegisterFormat(nameA, magicA, decodeA, decodeConfigA)
+}
+
+// GetFormats returns the names of all formats which could be decoded
+func (p *ImageTK) GetFormats() []string {
+	formatsMutexG.RLock()
+	defer formatsMutexG.RUnlock()
+
+	var listT []string
+	mapT := make(map[string]bool)
+
+	for _, v := range formatsG {
+		if v.Decode == nil || mapT[v.Name] {
+			continue
+		}
+
+		mapT[v.Name] = true
+		listT = append(listT, v.Name)
+	}
+
+	return listT
+}
+
+// DetectFormat returns the format name sniffed from the leading bytes of an image, or "" if unknown
+func (p *ImageTK) DetectFormat(dataA []byte) string {
+	return detectFormat(dataA)
+}
+
+func init() {
+	// formats only recognized, decoders may be registered later
+	RegisterFormat("bmp", "BM????\x00\x00\x00\x00", nil, nil)
+	RegisterFormat("tiff", "II*\x00", nil, nil)
+	RegisterFormat("tiff", "MM\x00*", nil, nil)
+	RegisterFormat("webp", "RIFF????WEBP", nil, nil)
+	RegisterFormat("ico", "\x00\x00\x01\x00", nil, nil)
+	RegisterFormat("cur", "\x00\x00\x02\x00", nil, nil)
+	RegisterFormat("psd", "8BPS", nil, nil)
+	RegisterFormat("heic", "????ftypheic", nil, nil)
+	RegisterFormat("heic", "????ftypheix", nil, nil)
+	RegisterFormat("heic", "????ftypmif1", nil, nil)
+	RegisterFormat("avif", "????ftypavif", nil, nil)
+	RegisterFormat("jxl", "\xff\x0a", nil, nil)
+	RegisterFormat("jxl", "\x00\x00\x00\x0cJXL \x0d\x0a\x87\x0a", nil, nil)
+	RegisterFormat("jp2", "\x00\x00\x00\x0cjP  \x0d\x0a\x87\x0a", nil, nil)
+	RegisterFormat("qoi", "qoif", nil, nil)
+	RegisterFormat("farbfeld", "farbfeld", nil, nil)
+	RegisterFormat("pbm", "P1", nil, nil)
+	RegisterFormat("pgm", "P2", nil, nil)
+	RegisterFormat("ppm", "P3", nil, nil)
+	RegisterFormat("pbm", "P4", nil, nil)
+	RegisterFormat("pgm", "P5", nil, nil)
+	RegisterFormat("ppm", "P6", nil, nil)
+	RegisterFormat("pam", "P7", nil, nil)
+
+	RegisterFormat("jpeg", "\xff\xd8", jpeg.Decode, jpeg.DecodeConfig)
+	RegisterFormat("png", "\x89PNG\r\n\x1a\n", png.Decode, png.DecodeConfig)
+	RegisterFormat("gif", "GIF87a", gif.Decode, gif.DecodeConfig)
+	RegisterFormat("gif", "GIF89a", gif.Decode, gif.DecodeConfig)
+}
+
+// normalizeFormat converts a format name or file extension like ".JPG" to the registered name like "jpeg"
+func normalizeFormat(formatA string) string {
+	formatT := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(formatA)), ".")
+
+	switch formatT {
+	case "jpg", "jpe", "jfif":
+		return "jpeg"
+	case "tif":
+		return "tiff"
+	case "ff":
+		return "farbfeld"
+	case "dib":
+		return "bmp"
+	}
+
+	return formatT
+}
+
+func matchMagic(magicA string, dataA []byte) bool {
+	if len(magicA) > len(dataA) {
+		return false
+	}
+
+	for i := 0; i < len(magicA); i++ {
+		if magicA[i] != '?' && magicA[i] != dataA[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func maxMagicLen() int {
+	formatsMutexG.RLock()
+	defer formatsMutexG.RUnlock()
+
+	maxT := 0
+	for _, v := range formatsG {
+		if len(v.Magic) > maxT {
+			maxT = len(v.Magic)
+		}
+	}
+
+	return maxT
+}
+
+func detectFormat(dataA []byte) string {
+	formatsMutexG.RLock()
+	defer formatsMutexG.RUnlock()
+
+	for i := len(formatsG) - 1; i >= 0; i-- {
+		if matchMagic(formatsG[i].Magic, dataA) {
+			return formatsG[i].Name
+		}
+	}
+
+	return ""
+}
+
+// lookupFormat returns the latest registered format with the name which has a decoder
+func lookupFormat(nameA string) *ImageFormat {
+	formatsMutexG.RLock()
+	defer formatsMutexG.RUnlock()
+
+	for i := len(formatsG) - 1; i >= 0; i-- {
+		if formatsG[i].Name == nameA && formatsG[i].Decode != nil {
+			f := formatsG[i]
+			return &f
+		}
+	}
+
+	return nil
+}
+
+// isKnownFormat reports whether the format is registered for sniffing or encoding, with or without a decoder
+func isKnownFormat(nameA string) bool {
+	if lookupEncoder(nameA) != nil {
+		return true
+	}
+
+	formatsMutexG.RLock()
+	defer formatsMutexG.RUnlock()
+
+	for _, v := range formatsG {
+		if v.Name == nameA {
+			return true
+		}
+	}
+
+	return false
+}
+
+type peekReader interface {
+	io.Reader
+	Peek(int) ([]byte, error)
+}
+
+func asPeekReader(r io.Reader) peekReader {
+	if rr, ok := r.(peekReader); ok {
+		return rr
+	}
+
+	return bufio.NewReader(r)
+}
+
+// sniffFormat peeks the leading bytes of the reader and returns the detected format name
+func sniffFormat(r peekReader) (string, error) {
+	headT, errT := r.Peek(maxMagicLen())
+	if errT != nil && errT != io.EOF && errT != bufio.ErrBufferFull {
+		if len(headT) < 1 {
+			return "", errT
+		}
+	}
+
+	formatT := detectFormat(headT)
+	if formatT == "" {
+		return "", ErrUnknownFormat
+	}
+
+	return formatT, nil
+}
+
+// decodeImage decodes an image from the reader, the format is sniffed from the content if formatA is empty
+func decodeImage(r io.Reader, formatA string) (image.Image, string, error) {
+	rr := asPeekReader(r)
+
+	formatT := normalizeFormat(formatA)
+	if formatT == "" {
+		var errT error
+		formatT, errT = sniffFormat(rr)
+		if errT != nil {
+			return nil, "", errT
+		}
+	}
+
+	f := lookupFormat(formatT)
+	if f == nil {
+		return nil, formatT, fmt.Errorf("%w: %v", ErrUnsupportedFormat, formatT)
+	}
+
+	imgT, errT := f.Decode(rr)
+	if errT != nil {
+		return nil, formatT, errT
+	}
+
+	return imgT, formatT, nil
+}
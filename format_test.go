@@ -0,0 +1,149 @@
+package imagetk
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	p := NewImageTK()
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"png", "\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR", "png"},
+		{"jpeg", "\xff\xd8\xff\xe0", "jpeg"},
+		{"gif87a", "GIF87a\x01\x00", "gif"},
+		{"gif89a", "GIF89a\x01\x00", "gif"},
+		{"bmp", "BM\x36\x00\x0c\x00\x00\x00\x00\x00\x36\x00", "bmp"},
+		{"bmp reserved bytes set", "BM\x36\x00\x0c\x00\x01\x00\x00\x00\x36\x00", ""},
+		{"tiff little-endian", "II*\x00\x08\x00\x00\x00", "tiff"},
+		{"tiff big-endian", "MM\x00*\x00\x00\x00\x08", "tiff"},
+		{"webp", "RIFF\x24\x10\x00\x00WEBPVP8 ", "webp"},
+		{"riff not webp", "RIFF\x24\x10\x00\x00WAVEfmt ", ""},
+		{"heic", "\x00\x00\x00\x18ftypheic\x00\x00", "heic"},
+		{"avif", "\x00\x00\x00\x1cftypavif\x00\x00", "avif"},
+		{"qoi", "qoif\x00\x00\x00\x01", "qoi"},
+		{"pgm", "P5 1 1 255\n\x00", "pgm"},
+		{"truncated magic", "\x89PN", ""},
+		{"empty", "", ""},
+		{"text", "hello, world", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.DetectFormat([]byte(tt.data)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterFormatLatestWins(t *testing.T) {
+	p := NewImageTK()
+
+	decodeT := func(name string) func(io.Reader) (image.Image, error) {
+		return func(io.Reader) (image.Image, error) {
+			return nil, errors.New(name)
+		}
+	}
+
+	RegisterFormat("testfirst", "TsT?x", decodeT("first"), nil)
+
+	if got := p.DetectFormat([]byte("TsT0x")); got != "testfirst" {
+		t.Fatalf("got %q", got)
+	}
+
+	// the same magic registered later takes precedence
+	RegisterFormat("testsecond", "TsT?x", decodeT("second"), nil)
+
+	if got := p.DetectFormat([]byte("TsT1x")); got != "testsecond" {
+		t.Errorf("got %q, want testsecond", got)
+	}
+
+	// so does a decoder registered later for the same name
+	RegisterFormat("testsecond", "TsT?y", decodeT("third"), nil)
+
+	_, formatT, err := decodeImage(bytes.NewReader([]byte("TsT2x")), "")
+	if formatT != "testsecond" || err == nil || err.Error() != "third" {
+		t.Errorf("got %q, %v", formatT, err)
+	}
+}
+
+func TestNormalizeFormat(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"png", "png"},
+		{".PNG", "png"},
+		{" jpg ", "jpeg"},
+		{".jpe", "jpeg"},
+		{"JFIF", "jpeg"},
+		{"jpeg", "jpeg"},
+		{".tif", "tiff"},
+		{"TIFF", "tiff"},
+		{".ff", "farbfeld"},
+		{"dib", "bmp"},
+		{"", ""},
+		{".", ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeFormat(tt.in); got != tt.want {
+			t.Errorf("normalizeFormat(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLoadImageSniffsContent(t *testing.T) {
+	p := NewImageTK()
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, image.NewGray(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatal(err)
+	}
+
+	dirT := t.TempDir()
+
+	// the extension does not matter
+	pathT := filepath.Join(dirT, "image.jpg")
+	if err := os.WriteFile(pathT, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	img, formatT, err := p.LoadImage(pathT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if formatT != "png" || img.Bounds() != image.Rect(0, 0, 3, 2) {
+		t.Errorf("got %v of %v", formatT, img.Bounds())
+	}
+
+	unknownT := filepath.Join(dirT, "image.png")
+	if err := os.WriteFile(unknownT, []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := p.LoadImage(unknownT); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("got %v, want ErrUnknownFormat", err)
+	}
+
+	// recognized but without a decoder
+	webpT := filepath.Join(dirT, "image.webp")
+	if err := os.WriteFile(webpT, []byte("RIFF\x24\x10\x00\x00WEBPVP8 "), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, formatT, err := p.LoadImage(webpT); formatT != "webp" || err == nil {
+		t.Errorf("got %q, %v", formatT, err)
+	}
+}
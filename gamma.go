@@ -0,0 +1,364 @@
+package imagetk
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+)
+
+// lookup tables between 16-bit sRGB samples and 16-bit linear light, built on first use
+var (
+	srgbTablesOnceG sync.Once
+	srgbToLinearG   []uint16
+	linearToSRGBG   []uint16
+	linearToSRGB8G  []uint8
+)
+
+func srgbDecode(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func srgbEncode(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+func initSRGBTables() {
+	srgbToLinearG = make([]uint16, 0x10000)
+	linearToSRGBG = make([]uint16, 0x10000)
+	linearToSRGB8G = make([]uint8, 0x10000)
+
+	for i := 0; i < 0x10000; i++ {
+		v := float64(i) / 0xffff
+
+		srgbToLinearG[i] = uint16(math.Round(srgbDecode(v) * 0xffff))
+
+		e := srgbEncode(v)
+		linearToSRGBG[i] = uint16(math.Round(e * 0xffff))
+		linearToSRGB8G[i] = uint8(math.Round(e * 0xff))
+	}
+}
+
+// premultiplied 16-bit sRGB sample to premultiplied 16-bit linear sample, the color is decoded without the alpha
+func srgbToLinear16(c, a uint32) uint16 {
+	switch a {
+	case 0xffff:
+		return srgbToLinearG[c]
+	case 0:
+		return 0
+	}
+
+	u := c * 0xffff / a
+	if u > 0xffff {
+		u = 0xffff
+	}
+
+	return uint16(uint32(srgbToLinearG[u]) * a / 0xffff)
+}
+
+// unpremultiplied linear sample, the alpha should not be 0
+func unpremultiplyLinear(c, a uint32) uint32 {
+	if a == 0xffff {
+		return c
+	}
+
+	u := c * 0xffff / a
+	if u > 0xffff {
+		u = 0xffff
+	}
+
+	return u
+}
+
+// premultiplied 16-bit linear sample to premultiplied 16-bit sRGB sample
+func linearToSRGB16(c, a uint32) uint16 {
+	if a == 0 {
+		return 0
+	}
+
+	return uint16(uint32(linearToSRGBG[unpremultiplyLinear(c, a)]) * a / 0xffff)
+}
+
+// premultiplied 16-bit linear sample to premultiplied 8-bit sRGB sample, a8A is the 8-bit alpha
+func linearToSRGB8(c, a uint32, a8A uint8) uint8 {
+	if a8A == 0 {
+		return 0
+	}
+
+	s := uint32(linearToSRGB8G[unpremultiplyLinear(c, a)])
+	if a8A == 0xff {
+		return uint8(s)
+	}
+
+	return uint8((s*uint32(a8A) + 0x7f) / 0xff)
+}
+
+// grayToLinear converts the Gray or Gray16 image to linear light
+func (r *Resizer) grayToLinear(dstA *image.Gray16, srcA image.Image) {
+	w := dstA.Rect.Dx()
+
+	r.runRows(dstA.Rect.Dy(), func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			outT := dstA.Pix[y*dstA.Stride:]
+
+			switch nv := srcA.(type) {
+			case *image.Gray:
+				rowT := nv.Pix[y*nv.Stride:]
+				for x := 0; x < w; x++ {
+					l := srgbToLinearG[uint32(rowT[x])*0x101]
+					outT[2*x+0] = uint8(l >> 8)
+					outT[2*x+1] = uint8(l)
+				}
+			case *image.Gray16:
+				rowT := nv.Pix[y*nv.Stride:]
+				for x := 0; x < w; x++ {
+					l := srgbToLinearG[uint32(rowT[2*x+0])<<8|uint32(rowT[2*x+1])]
+					outT[2*x+0] = uint8(l >> 8)
+					outT[2*x+1] = uint8(l)
+				}
+			}
+		}
+	})
+}
+
+// rgbaToLinear converts the image to premultiplied linear light
+func (r *Resizer) rgbaToLinear(dstA *image.RGBA64, srcA image.Image) {
+	boundsT := srcA.Bounds()
+	w := dstA.Rect.Dx()
+
+	put := func(outA []uint8, cr, cg, cb, a uint32) {
+		lr, lg, lb := srgbToLinear16(cr, a), srgbToLinear16(cg, a), srgbToLinear16(cb, a)
+		outA[0], outA[1] = uint8(lr>>8), uint8(lr)
+		outA[2], outA[3] = uint8(lg>>8), uint8(lg)
+		outA[4], outA[5] = uint8(lb>>8), uint8(lb)
+		outA[6], outA[7] = uint8(a>>8), uint8(a)
+	}
+
+	// the color is not premultiplied
+	putStraight := func(outA []uint8, cr, cg, cb, a uint32) {
+		lr := uint32(srgbToLinearG[cr]) * a / 0xffff
+		lg := uint32(srgbToLinearG[cg]) * a / 0xffff
+		lb := uint32(srgbToLinearG[cb]) * a / 0xffff
+		outA[0], outA[1] = uint8(lr>>8), uint8(lr)
+		outA[2], outA[3] = uint8(lg>>8), uint8(lg)
+		outA[4], outA[5] = uint8(lb>>8), uint8(lb)
+		outA[6], outA[7] = uint8(a>>8), uint8(a)
+	}
+
+	r.runRows(dstA.Rect.Dy(), func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			outT := dstA.Pix[y*dstA.Stride:]
+
+			switch nv := srcA.(type) {
+			case *image.RGBA:
+				rowT := nv.Pix[y*nv.Stride:]
+				for x := 0; x < w; x++ {
+					s := rowT[4*x : 4*x+4]
+					put(outT[8*x:], uint32(s[0])*0x101, uint32(s[1])*0x101, uint32(s[2])*0x101, uint32(s[3])*0x101)
+				}
+			case *image.RGBA64:
+				rowT := nv.Pix[y*nv.Stride:]
+				for x := 0; x < w; x++ {
+					s := rowT[8*x : 8*x+8]
+					put(outT[8*x:], uint32(s[0])<<8|uint32(s[1]), uint32(s[2])<<8|uint32(s[3]), uint32(s[4])<<8|uint32(s[5]), uint32(s[6])<<8|uint32(s[7]))
+				}
+			case *image.NRGBA:
+				rowT := nv.Pix[y*nv.Stride:]
+				for x := 0; x < w; x++ {
+					s := rowT[4*x : 4*x+4]
+					putStraight(outT[8*x:], uint32(s[0])*0x101, uint32(s[1])*0x101, uint32(s[2])*0x101, uint32(s[3])*0x101)
+				}
+			case *image.NRGBA64:
+				rowT := nv.Pix[y*nv.Stride:]
+				for x := 0; x < w; x++ {
+					s := rowT[8*x : 8*x+8]
+					putStraight(outT[8*x:], uint32(s[0])<<8|uint32(s[1]), uint32(s[2])<<8|uint32(s[3]), uint32(s[4])<<8|uint32(s[5]), uint32(s[6])<<8|uint32(s[7]))
+				}
+			case *image.YCbCr:
+				for x := 0; x < w; x++ {
+					yi := nv.YOffset(boundsT.Min.X+x, boundsT.Min.Y+y)
+					ci := nv.COffset(boundsT.Min.X+x, boundsT.Min.Y+y)
+					cr, cg, cb := color.YCbCrToRGB(nv.Y[yi], nv.Cb[ci], nv.Cr[ci])
+					put(outT[8*x:], uint32(cr)*0x101, uint32(cg)*0x101, uint32(cb)*0x101, 0xffff)
+				}
+			default:
+				for x := 0; x < w; x++ {
+					cr, cg, cb, ca := srcA.At(boundsT.Min.X+x, boundsT.Min.Y+y).RGBA()
+					put(outT[8*x:], cr, cg, cb, ca)
+				}
+			}
+		}
+	})
+}
+
+// linearToGray converts the linear light image back to the Gray or Gray16 image
+func (r *Resizer) linearToGray(dstA image.Image, srcA *image.Gray16) {
+	w := srcA.Rect.Dx()
+
+	r.runRows(srcA.Rect.Dy(), func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			rowT := srcA.Pix[y*srcA.Stride:]
+
+			switch nv := dstA.(type) {
+			case *image.Gray:
+				outT := nv.Pix[y*nv.Stride:]
+				for x := 0; x < w; x++ {
+					outT[x] = linearToSRGB8G[uint32(rowT[2*x+0])<<8|uint32(rowT[2*x+1])]
+				}
+			case *image.Gray16:
+				outT := nv.Pix[y*nv.Stride:]
+				for x := 0; x < w; x++ {
+					s := linearToSRGBG[uint32(rowT[2*x+0])<<8|uint32(rowT[2*x+1])]
+					outT[2*x+0] = uint8(s >> 8)
+					outT[2*x+1] = uint8(s)
+				}
+			}
+		}
+	})
+}
+
+// linearToRGBA converts the premultiplied linear light image back to the RGBA, RGBA64, NRGBA or NRGBA64 image
+func (r *Resizer) linearToRGBA(dstA image.Image, srcA *image.RGBA64) {
+	w := srcA.Rect.Dx()
+
+	r.runRows(srcA.Rect.Dy(), func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			rowT := srcA.Pix[y*srcA.Stride:]
+
+			for x := 0; x < w; x++ {
+				s := rowT[8*x : 8*x+8]
+				cr, cg, cb := uint32(s[0])<<8|uint32(s[1]), uint32(s[2])<<8|uint32(s[3]), uint32(s[4])<<8|uint32(s[5])
+				ca := uint32(s[6])<<8 | uint32(s[7])
+
+				// filter overshoot could leave the color above the alpha
+				cr, cg, cb = minUint32(cr, ca), minUint32(cg, ca), minUint32(cb, ca)
+
+				switch nv := dstA.(type) {
+				case *image.RGBA:
+					a8 := uint8(ca >> 8)
+					o := nv.Pix[y*nv.Stride+4*x : y*nv.Stride+4*x+4]
+					o[0], o[1], o[2], o[3] = linearToSRGB8(cr, ca, a8), linearToSRGB8(cg, ca, a8), linearToSRGB8(cb, ca, a8), a8
+				case *image.NRGBA:
+					o := nv.Pix[y*nv.Stride+4*x : y*nv.Stride+4*x+4]
+					if ca == 0 {
+						o[0], o[1], o[2], o[3] = 0, 0, 0, 0
+						continue
+					}
+					o[0] = linearToSRGB8G[unpremultiplyLinear(cr, ca)]
+					o[1] = linearToSRGB8G[unpremultiplyLinear(cg, ca)]
+					o[2] = linearToSRGB8G[unpremultiplyLinear(cb, ca)]
+					o[3] = uint8(ca >> 8)
+				case *image.RGBA64:
+					lr, lg, lb := linearToSRGB16(cr, ca), linearToSRGB16(cg, ca), linearToSRGB16(cb, ca)
+					o := nv.Pix[y*nv.Stride+8*x : y*nv.Stride+8*x+8]
+					o[0], o[1] = uint8(lr>>8), uint8(lr)
+					o[2], o[3] = uint8(lg>>8), uint8(lg)
+					o[4], o[5] = uint8(lb>>8), uint8(lb)
+					o[6], o[7] = uint8(ca>>8), uint8(ca)
+				case *image.NRGBA64:
+					var lr, lg, lb uint16
+					if ca != 0 {
+						lr = linearToSRGBG[unpremultiplyLinear(cr, ca)]
+						lg = linearToSRGBG[unpremultiplyLinear(cg, ca)]
+						lb = linearToSRGBG[unpremultiplyLinear(cb, ca)]
+					}
+					o := nv.Pix[y*nv.Stride+8*x : y*nv.Stride+8*x+8]
+					o[0], o[1] = uint8(lr>>8), uint8(lr)
+					o[2], o[3] = uint8(lg>>8), uint8(lg)
+					o[4], o[5] = uint8(lb>>8), uint8(lb)
+					o[6], o[7] = uint8(ca>>8), uint8(ca)
+				}
+			}
+		}
+	})
+}
+
+func minUint32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// resizeLinear resizes the image in linear light, Gray, Gray16, NRGBA and NRGBA64 keep their types,
+// RGBA and YCbCr result in RGBA, other images result in RGBA64. See resize for where the result is written.
+func (r *Resizer) resizeLinear(img image.Image, outA draw.Image, scratchA *ResizeScratch) image.Image {
+	srgbTablesOnceG.Do(initSRGBTables)
+
+	boundsT := img.Bounds()
+	w, h := boundsT.Dx(), boundsT.Dy()
+
+	switch input := img.(type) {
+	case *image.Gray, *image.Gray16:
+		linT := scratchA.gray16(scratchLinear, w, h)
+		r.grayToLinear(linT, input)
+
+		resultT := r.resizeEncoded(linT, nil, scratchA).(*image.Gray16)
+
+		var outT image.Image
+		if _, ok := input.(*image.Gray); ok {
+			if out, ok := outA.(*image.Gray); ok {
+				outT = out
+			} else {
+				outT = scratchA.gray(scratchOutput, r.dstWidth, r.dstHeight)
+			}
+		} else {
+			if out, ok := outA.(*image.Gray16); ok {
+				outT = out
+			} else {
+				outT = scratchA.gray16(scratchOutput, r.dstWidth, r.dstHeight)
+			}
+		}
+
+		r.linearToGray(outT, resultT)
+
+		return outT
+	}
+
+	linT := scratchA.rgba64(scratchLinear, w, h)
+	r.rgbaToLinear(linT, img)
+
+	resultT := r.resizeEncoded(linT, nil, scratchA).(*image.RGBA64)
+
+	var outT image.Image
+	switch img.(type) {
+	case *image.RGBA, *image.YCbCr:
+		if out, ok := outA.(*image.RGBA); ok {
+			outT = out
+		} else {
+			outT = scratchA.rgba(scratchOutput, r.dstWidth, r.dstHeight)
+		}
+	case *image.NRGBA:
+		if out, ok := outA.(*image.NRGBA); ok {
+			outT = out
+		} else {
+			outT = scratchA.nrgba(scratchOutput, r.dstWidth, r.dstHeight)
+		}
+	case *image.NRGBA64:
+		if out, ok := outA.(*image.NRGBA64); ok {
+			outT = out
+		} else {
+			outT = scratchA.nrgba64(scratchOutput, r.dstWidth, r.dstHeight)
+		}
+	default:
+		if out, ok := outA.(*image.RGBA64); ok {
+			outT = out
+		} else {
+			outT = scratchA.rgba64(scratchOutput, r.dstWidth, r.dstHeight)
+		}
+	}
+
+	r.linearToRGBA(outT, resultT)
+
+	return outT
+}
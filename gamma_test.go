@@ -0,0 +1,90 @@
+package imagetk
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// checkerboard returns an image of alternating black and white pixels
+func checkerboard(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if (x+y)%2 == 0 {
+				img.SetRGBA(x, y, color.RGBA{255, 255, 255, 255})
+			} else {
+				img.SetRGBA(x, y, color.RGBA{0, 0, 0, 255})
+			}
+		}
+	}
+
+	return img
+}
+
+func TestGammaSRGBCheckerboard(t *testing.T) {
+	p := NewImageTK()
+
+	src := checkerboard(64, 64)
+	gray := image.NewGray(src.Rect)
+	for i := range gray.Pix {
+		gray.Pix[i] = src.Pix[4*i]
+	}
+
+	// half of the light is 0.5 in linear light, which is 188 in sRGB
+	tests := []struct {
+		name   string
+		interp InterpolationFunction
+		gamma  GammaMode
+		want   int
+	}{
+		{"lanczos srgb", Lanczos3, GammaSRGB, 188},
+		{"mitchell srgb", MitchellNetravali, GammaSRGB, 188},
+		{"lanczos none", Lanczos3, GammaNone, 128},
+		{"mitchell none", MitchellNetravali, GammaNone, 128},
+	}
+
+	for _, tt := range tests {
+		for _, img := range []image.Image{src, gray} {
+			optsT := ResizeOptions{Interpolation: tt.interp, Gamma: tt.gamma}
+			got := p.ResizeImage(8, 8, img, optsT)
+
+			// the inner pixels, the edges repeat the pattern with another phase
+			for y := 2; y < 6; y++ {
+				for x := 2; x < 6; x++ {
+					r, g, b, a := got.At(x, y).RGBA()
+
+					if d := int(r>>8) - tt.want; d < -2 || d > 2 || r != g || g != b || a != 0xffff {
+						t.Fatalf("%v %T: pixel (%v,%v) is %v, want about %v", tt.name, img, x, y, got.At(x, y), tt.want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestGammaSRGBKeepsFlatColors(t *testing.T) {
+	p := NewImageTK()
+
+	// a flat color is not changed by the conversion to linear light and back
+	for _, v := range []uint8{0, 1, 17, 128, 188, 254, 255} {
+		img := image.NewRGBA(image.Rect(0, 0, 12, 10))
+		for i := range img.Pix {
+			img.Pix[i] = v
+			if i%4 == 3 {
+				img.Pix[i] = 255
+			}
+		}
+
+		got := p.ResizeImage(5, 4, img, ResizeOptions{Interpolation: Lanczos3, Gamma: GammaSRGB})
+
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 5; x++ {
+				if c := color.RGBAModel.Convert(got.At(x, y)).(color.RGBA); c != (color.RGBA{v, v, v, 255}) {
+					t.Fatalf("%v: pixel (%v,%v) is %v", v, x, y, c)
+				}
+			}
+		}
+	}
+}
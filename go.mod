@@ -4,6 +4,7 @@ go 1.22.4
 
 require (
 	github.com/topxeq/tkc v0.0.0-20240618010942-dee68c50edd5
+	golang.org/x/image v0.17.0
 	gonum.org/v1/plot v0.12.0
 )
 
@@ -62,7 +63,6 @@ require (
 	github.com/yuin/goldmark v1.7.2 // indirect
 	github.com/yuin/goldmark-highlighting v0.0.0-20220208100518-594be1970594 // indirect
 	golang.org/x/crypto v0.24.0 // indirect
-	golang.org/x/image v0.17.0 // indirect
 	golang.org/x/net v0.26.0 // indirect
 	golang.org/x/sys v0.21.0 // indirect
 	golang.org/x/term v0.21.0 // indirect
@@ -6,12 +6,10 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
-	"image/gif"
-	"image/jpeg"
 	"image/png"
+	"io"
 	"math"
 	"os"
-	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
@@ -82,6 +80,30 @@ func (p *ImageTK) LoadRGBAFromImage(imageA image.Image) (*image.RGBA, error) {
 
 }
 
+// newImageOf creates an image with the same type as the image if possible, otherwise an RGBA image
+func newImageOf(imageA image.Image, rectA image.Rectangle) draw.Image {
+	switch imageT := imageA.(type) {
+	case *image.RGBA:
+		return image.NewRGBA(rectA)
+	case *image.NRGBA:
+		return image.NewNRGBA(rectA)
+	case *image.RGBA64:
+		return image.NewRGBA64(rectA)
+	case *image.NRGBA64:
+		return image.NewNRGBA64(rectA)
+	case *image.Gray:
+		return image.NewGray(rectA)
+	case *image.Gray16:
+		return image.NewGray16(rectA)
+	case *image.CMYK:
+		return image.NewCMYK(rectA)
+	case *image.Paletted:
+		return image.NewPaletted(rectA, imageT.Palette)
+	default:
+		return image.NewRGBA(rectA)
+	}
+}
+
 func (p *ImageTK) LoadPlotImage(plt *plot.Plot, w vg.Length, h vg.Length) (*image.RGBA, error) {
 
 	var bufT bytes.Buffer
@@ -153,7 +175,9 @@ func (p *ImageTK) ParseHexColor(x string) (r, g, b, a int) {
 	return
 }
 
-func (p *ImageTK) EncodePNG(imgA image.Image) {
+// EncodePNG encodes the image to PNG bytes
+func (p *ImageTK) EncodePNG(imgA image.Image) ([]byte, error) {
+	return p.EncodeToBytes(imgA, "png", nil)
 }
 
 // LoadPlotImageInMemory formatA support png, jpg...
@@ -177,33 +201,22 @@ func (p *ImageTK) LoadPlotImageInMemory(plotA *plot.Plot, w vg.Length, h vg.Leng
 
 }
 
-func (p *ImageTK) SaveImageAs(imageA image.Image, filePathA string, formatA ...string) error {
-	fileT, errT := os.Create(filePathA)
-	if errT != nil {
-		return errT
-	}
-	defer fileT.Close()
-
-	var formatT string
-
-	if formatA == nil || len(formatA) < 1 {
-		formatT = ".png"
-	} else {
-		formatT = tk.ToLower(formatA[0])
-	}
+// SaveImageAs saves the image to the file, optional arguments could be
+// a format string such as "png", ".jpg", *EncodeOptions/EncodeOptions, *SaveOptions/SaveOptions
+// and *ImageMeta(from LoadImageWithMeta, to keep the EXIF data and the PNG chunks).
+// If no format is given, the format is chosen from the file extension, and falls back to PNG if the extension
+// is not a known format. ErrUnsupportedFormat is returned if there is no encoder for the format.
+// The file is written to a temp file first and renamed into place, so a failed save never leaves a truncated file.
+func (p *ImageTK) SaveImageAs(imageA image.Image, filePathA string, optsA ...interface{}) error {
+	argsT := getSaveArgs(filePathA, optsA)
 
-	switch formatT {
-	case "", ".png":
-		errT = png.Encode(fileT, imageA)
-	case ".jpg", ".jpeg":
-		errT = jpeg.Encode(fileT, imageA, nil)
-	case ".gif":
-		errT = gif.Encode(fileT, imageA, nil)
-	default:
-		errT = png.Encode(fileT, imageA)
+	if lookupEncoder(argsT.format) == nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedFormat, argsT.format)
 	}
 
-	return errT
+	return writeFileAtomic(filePathA, argsT.saveOpts, func(w io.Writer) error {
+		return p.EncodeTo(w, imageA, argsT.format, argsT.encodeOpts)
+	})
 
 }
 
@@ -212,63 +225,35 @@ func (p *ImageTK) GetImageFileContent(fileNameA string, imageTypeA string) image
 	if err != nil {
 		return nil
 	}
+	defer file.Close()
 
-	fileExt := strings.ToLower(filepath.Ext(fileNameA))
-	var img image.Image
-
-	if imageTypeA != "" {
-		fileExt = imageTypeA
-	}
-
-	// tk.Pl("fileExt: %v", fileExt)
-
-	switch fileExt {
-	case ".jpg", ".jpeg", "jpg", "jpeg":
-		img, err = jpeg.Decode(file)
-	case ".png", "png":
-		img, err = png.Decode(file)
-	case ".gif", "gif":
-		img, err = gif.Decode(file)
-	default:
-		img, err = jpeg.Decode(file)
-	}
-
+	// the format is sniffed from the content unless imageTypeA is given
+	img, _, err := decodeImage(file, imageTypeA)
 	if err != nil {
 		// tk.Pl("err: %v", err)
 		return nil
 	}
-	defer file.Close()
 
 	return img
 }
 
-func (p *ImageTK) LoadImage(fileNameA string) (image.Image, error) {
+// LoadImage loads the image file, the format is detected from the content instead of the file extension.
+// Returns the image and the detected format name such as "png", "jpeg", "gif".
+// optsA could be *DecodeOptions or DecodeOptions, such as &DecodeOptions{AutoOrient: true}.
+func (p *ImageTK) LoadImage(fileNameA string, optsA ...interface{}) (image.Image, string, error) {
 	file, err := os.Open(fileNameA)
 	if err != nil {
-		return nil, err
-	}
-
-	fileExt := strings.ToLower(filepath.Ext(fileNameA))
-	var img image.Image
-
-	switch fileExt {
-	case ".jpg", ".jpeg", "jpg", "jpeg":
-		img, err = jpeg.Decode(file)
-	case ".png", "png":
-		img, err = png.Decode(file)
-	case ".gif", "gif":
-		img, err = gif.Decode(file)
-	default:
-		img, err = jpeg.Decode(file)
+		return nil, "", err
 	}
+	defer file.Close()
 
+	img, formatT, err := p.DecodeFrom(file, optsA...)
 	if err != nil {
 		// tk.Pl("err: %v", err)
-		return nil, err
+		return nil, formatT, err
 	}
-	defer file.Close()
 
-	return img, nil
+	return img, formatT, nil
 }
 
 func (p *ImageTK) GetImageFileContentAndThumb(fileNameA string, maxWidthA uint, maxHeightA uint, imageTypeA string) image.Image {
@@ -282,427 +267,18 @@ func (p *ImageTK) GetImageFileContentAndThumb(fileNameA string, maxWidthA uint,
 	return m
 }
 
-func (p *ImageTK) Thumbnail(maxWidth, maxHeight uint, img image.Image, interp InterpolationFunction) image.Image {
-	origBounds := img.Bounds()
-	origWidth := uint(origBounds.Dx())
-	origHeight := uint(origBounds.Dy())
-	newWidth, newHeight := origWidth, origHeight
-
-	// Return original image if it have same or smaller size as constraints
-	if maxWidth >= origWidth && maxHeight >= origHeight {
-		return img
-	}
-
-	// Preserve aspect ratio
-	if origWidth > maxWidth {
-		newHeight = uint(origHeight * maxWidth / origWidth)
-		if newHeight < 1 {
-			newHeight = 1
-		}
-		newWidth = maxWidth
-	}
-
-	if newHeight > maxHeight {
-		newWidth = uint(newWidth * maxHeight / newHeight)
-		if newWidth < 1 {
-			newWidth = 1
-		}
-		newHeight = maxHeight
-	}
-	return p.ResizeImage(int(newWidth), int(newHeight), img, interp)
+// Thumbnail resizes the image to the box, by default fitting it inside keeping the aspect ratio without enlarging it.
+// Optional arguments could be ThumbnailOptions or *ThumbnailOptions and the options of ResizeImage.
+func (p *ImageTK) Thumbnail(maxWidth, maxHeight uint, img image.Image, optsA ...interface{}) image.Image {
+	return p.thumbnail(int(maxWidth), int(maxHeight), img, optsA)
 }
 
-func resizeNearest(width, height uint, scaleX, scaleY float64, img image.Image, interp InterpolationFunction) image.Image {
-	taps, _ := interp.kernel()
-	cpus := runtime.NumCPU()
-	wg := sync.WaitGroup{}
-
-	switch input := img.(type) {
-	case *image.RGBA:
-		// 8-bit precision
-		temp := image.NewRGBA(image.Rect(0, 0, input.Bounds().Dy(), int(width)))
-		result := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
-
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeightsNearest(temp.Bounds().Dy(), taps, blur, scaleX)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.RGBA)
-			go func() {
-				defer wg.Done()
-				nearestRGBA(input, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeightsNearest(result.Bounds().Dy(), taps, blur, scaleY)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.RGBA)
-			go func() {
-				defer wg.Done()
-				nearestRGBA(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		return result
-	case *image.YCbCr:
-		// 8-bit precision
-		// accessing the YCbCr arrays in a tight loop is slow.
-		// converting the image to ycc increases performance by 2x.
-		temp := newYCC(image.Rect(0, 0, input.Bounds().Dy(), int(width)), input.SubsampleRatio)
-		result := newYCC(image.Rect(0, 0, int(width), int(height)), input.SubsampleRatio)
-
-		coeffs, offset, filterLength := createWeightsNearest(temp.Bounds().Dy(), taps, blur, scaleX)
-		in := imageYCbCrToYCC(input)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*ycc)
-			go func() {
-				defer wg.Done()
-				nearestYCbCr(in, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-
-		coeffs, offset, filterLength = createWeightsNearest(result.Bounds().Dy(), taps, blur, scaleY)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*ycc)
-			go func() {
-				defer wg.Done()
-				nearestYCbCr(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		return result.YCbCr()
-	case *image.RGBA64:
-		// 16-bit precision
-		temp := image.NewRGBA64(image.Rect(0, 0, input.Bounds().Dy(), int(width)))
-		result := image.NewRGBA64(image.Rect(0, 0, int(width), int(height)))
-
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeightsNearest(temp.Bounds().Dy(), taps, blur, scaleX)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.RGBA64)
-			go func() {
-				defer wg.Done()
-				nearestRGBA64(input, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeightsNearest(result.Bounds().Dy(), taps, blur, scaleY)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.RGBA64)
-			go func() {
-				defer wg.Done()
-				nearestGeneric(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		return result
-	case *image.Gray:
-		// 8-bit precision
-		temp := image.NewGray(image.Rect(0, 0, input.Bounds().Dy(), int(width)))
-		result := image.NewGray(image.Rect(0, 0, int(width), int(height)))
-
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeightsNearest(temp.Bounds().Dy(), taps, blur, scaleX)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.Gray)
-			go func() {
-				defer wg.Done()
-				nearestGray(input, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeightsNearest(result.Bounds().Dy(), taps, blur, scaleY)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.Gray)
-			go func() {
-				defer wg.Done()
-				nearestGray(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		return result
-	case *image.Gray16:
-		// 16-bit precision
-		temp := image.NewGray16(image.Rect(0, 0, input.Bounds().Dy(), int(width)))
-		result := image.NewGray16(image.Rect(0, 0, int(width), int(height)))
-
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeightsNearest(temp.Bounds().Dy(), taps, blur, scaleX)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.Gray16)
-			go func() {
-				defer wg.Done()
-				nearestGray16(input, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeightsNearest(result.Bounds().Dy(), taps, blur, scaleY)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.Gray16)
-			go func() {
-				defer wg.Done()
-				nearestGray16(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		return result
-	default:
-		// 16-bit precision
-		temp := image.NewRGBA64(image.Rect(0, 0, img.Bounds().Dy(), int(width)))
-		result := image.NewRGBA64(image.Rect(0, 0, int(width), int(height)))
-
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeightsNearest(temp.Bounds().Dy(), taps, blur, scaleX)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.RGBA64)
-			go func() {
-				defer wg.Done()
-				nearestGeneric(img, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeightsNearest(result.Bounds().Dy(), taps, blur, scaleY)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.RGBA64)
-			go func() {
-				defer wg.Done()
-				nearestRGBA64(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		return result
-	}
-
-}
-
-func (p *ImageTK) ResizeImage(widthA, heightA int, img image.Image, interpA ...InterpolationFunction) image.Image {
-	width := uint(widthA)
-	height := uint(heightA)
-	scaleX, scaleY := calcFactors(width, height, float64(img.Bounds().Dx()), float64(img.Bounds().Dy()))
-	if width == 0 {
-		width = uint(0.7 + float64(img.Bounds().Dx())/scaleX)
-	}
-	if height == 0 {
-		height = uint(0.7 + float64(img.Bounds().Dy())/scaleY)
-	}
-
-	var interp InterpolationFunction
-
-	if interpA == nil || len(interpA) < 1 {
-		interp = Lanczos3
-	} else {
-		interp = interpA[0]
-	}
-
-	if interp == NearestNeighbor {
-		return resizeNearest(width, height, scaleX, scaleY, img, interp)
-	}
-
-	taps, kernel := interp.kernel()
-	cpus := runtime.NumCPU()
-	wg := sync.WaitGroup{}
+// ResizeImage resizes the image to the size, 0 for width or height keeps the aspect ratio.
+// Optional arguments could be an InterpolationFunction(Lanczos3 by default), a Kernel and ResizeOptions or *ResizeOptions.
+func (p *ImageTK) ResizeImage(widthA, heightA int, img image.Image, optsA ...interface{}) image.Image {
+	boundsT := img.Bounds()
 
-	// Generic access to image.Image is slow in tight loops.
-	// The optimal access has to be determined from the concrete image type.
-	switch input := img.(type) {
-	case *image.RGBA:
-		// 8-bit precision
-		temp := image.NewRGBA(image.Rect(0, 0, input.Bounds().Dy(), int(width)))
-		result := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
-
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeights8(temp.Bounds().Dy(), taps, blur, scaleX, kernel)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.RGBA)
-			go func() {
-				defer wg.Done()
-				resizeRGBA(input, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeights8(result.Bounds().Dy(), taps, blur, scaleY, kernel)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.RGBA)
-			go func() {
-				defer wg.Done()
-				resizeRGBA(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		return result
-	case *image.YCbCr:
-		// 8-bit precision
-		// accessing the YCbCr arrays in a tight loop is slow.
-		// converting the image to ycc increases performance by 2x.
-		temp := newYCC(image.Rect(0, 0, input.Bounds().Dy(), int(width)), input.SubsampleRatio)
-		result := newYCC(image.Rect(0, 0, int(width), int(height)), input.SubsampleRatio)
-
-		coeffs, offset, filterLength := createWeights8(temp.Bounds().Dy(), taps, blur, scaleX, kernel)
-		in := imageYCbCrToYCC(input)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*ycc)
-			go func() {
-				defer wg.Done()
-				resizeYCbCr(in, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-
-		coeffs, offset, filterLength = createWeights8(result.Bounds().Dy(), taps, blur, scaleY, kernel)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*ycc)
-			go func() {
-				defer wg.Done()
-				resizeYCbCr(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		return result.YCbCr()
-	case *image.RGBA64:
-		// 16-bit precision
-		temp := image.NewRGBA64(image.Rect(0, 0, input.Bounds().Dy(), int(width)))
-		result := image.NewRGBA64(image.Rect(0, 0, int(width), int(height)))
-
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeights16(temp.Bounds().Dy(), taps, blur, scaleX, kernel)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.RGBA64)
-			go func() {
-				defer wg.Done()
-				resizeRGBA64(input, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeights16(result.Bounds().Dy(), taps, blur, scaleY, kernel)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.RGBA64)
-			go func() {
-				defer wg.Done()
-				resizeGeneric(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		return result
-	case *image.Gray:
-		// 8-bit precision
-		temp := image.NewGray(image.Rect(0, 0, input.Bounds().Dy(), int(width)))
-		result := image.NewGray(image.Rect(0, 0, int(width), int(height)))
-
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeights8(temp.Bounds().Dy(), taps, blur, scaleX, kernel)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.Gray)
-			go func() {
-				defer wg.Done()
-				resizeGray(input, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeights8(result.Bounds().Dy(), taps, blur, scaleY, kernel)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.Gray)
-			go func() {
-				defer wg.Done()
-				resizeGray(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		return result
-	case *image.Gray16:
-		// 16-bit precision
-		temp := image.NewGray16(image.Rect(0, 0, input.Bounds().Dy(), int(width)))
-		result := image.NewGray16(image.Rect(0, 0, int(width), int(height)))
-
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeights16(temp.Bounds().Dy(), taps, blur, scaleX, kernel)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.Gray16)
-			go func() {
-				defer wg.Done()
-				resizeGray16(input, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeights16(result.Bounds().Dy(), taps, blur, scaleY, kernel)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.Gray16)
-			go func() {
-				defer wg.Done()
-				resizeGray16(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		return result
-	default:
-		// 16-bit precision
-		temp := image.NewRGBA64(image.Rect(0, 0, img.Bounds().Dy(), int(width)))
-		result := image.NewRGBA64(image.Rect(0, 0, int(width), int(height)))
-
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeights16(temp.Bounds().Dy(), taps, blur, scaleX, kernel)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.RGBA64)
-			go func() {
-				defer wg.Done()
-				resizeGeneric(img, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeights16(result.Bounds().Dy(), taps, blur, scaleY, kernel)
-		wg.Add(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.RGBA64)
-			go func() {
-				defer wg.Done()
-				resizeRGBA64(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		return result
-	}
+	return NewResizer(boundsT.Dx(), boundsT.Dy(), widthA, heightA, optsA...).resize(img, nil, nil)
 }
 
 type InterpolationFunction int
@@ -721,6 +297,23 @@ const (
 	Lanczos2
 	// Lanczos interpolation (a=3)
 	Lanczos3
+	// Box filter, averages the covered pixels when downscaling
+	Box
+	// Hermite filter (cubic with zero derivatives at the ends)
+	Hermite
+	// Catmull-Rom spline, the same kernel as Bicubic
+	CatmullRom
+	// Gaussian filter (sigma=0.5)
+	Gaussian
+	// Hann-windowed sinc (a=3)
+	Hann
+	// Hamming-windowed sinc (a=3)
+	Hamming
+	// Cubic B-spline, smooth without ringing
+	BSpline
+	// Area averaging, each pixel is the exact average of the source area it covers when downscaling(Blur is ignored),
+	// the same as Bilinear when upscaling
+	Area
 )
 
 func nearest(in float64) float64 {
@@ -792,9 +385,15 @@ func createWeights8(dy, filterLength int, blur, scale float64, kernel func(float
 		interpX := scale * (float64(y) + 0.5)
 		start[y] = int(interpX) - filterLength/2 + 1
 		interpX -= float64(start[y])
+		sum := 0
 		for i := 0; i < filterLength; i++ {
 			in := (interpX - float64(i)) * filterFactor
 			coeffs[y*filterLength+i] = int16(kernel(in) * 256)
+			sum += int(coeffs[y*filterLength+i])
+		}
+		// a kernel narrower than the pixels could miss all of them, take the nearest pixel instead
+		if sum == 0 {
+			coeffs[y*filterLength+nearestTap(interpX, filterLength)] = 256
 		}
 	}
 
@@ -812,15 +411,26 @@ func createWeights16(dy, filterLength int, blur, scale float64, kernel func(floa
 		interpX := scale * (float64(y) + 0.5)
 		start[y] = int(interpX) - filterLength/2 + 1
 		interpX -= float64(start[y])
+		sum := 0
 		for i := 0; i < filterLength; i++ {
 			in := (interpX - float64(i)) * filterFactor
 			coeffs[y*filterLength+i] = int32(kernel(in) * 65536)
+			sum += int(coeffs[y*filterLength+i])
+		}
+		// a kernel narrower than the pixels could miss all of them, take the nearest pixel instead
+		if sum == 0 {
+			coeffs[y*filterLength+nearestTap(interpX, filterLength)] = 65536
 		}
 	}
 
 	return coeffs, start, filterLength
 }
 
+// nearestTap returns the filter tap closest to the interpolated position
+func nearestTap(interpX float64, filterLength int) int {
+	return minInt(maxInt(int(math.Floor(interpX+0.5)), 0), filterLength-1)
+}
+
 func createWeightsNearest(dy, filterLength int, blur, scale float64) ([]bool, []int, int) {
 	filterLength = filterLength * int(math.Max(math.Ceil(blur*scale), 1))
 	filterFactor := math.Min(1./(blur*scale), 1)
@@ -857,15 +467,32 @@ func (i InterpolationFunction) kernel() (int, func(float64) float64) {
 		return 4, lanczos2
 	case Lanczos3:
 		return 6, lanczos3
+	case Box:
+		return 2, box
+	case Hermite:
+		return 2, hermite
+	case CatmullRom:
+		return 4, cubic
+	case Gaussian:
+		return 4, gaussian
+	case Hann:
+		return 6, hann
+	case Hamming:
+		return 6, hamming
+	case BSpline:
+		return 4, bspline
+	case Area:
+		return 2, linear
 	default:
+		if k, ok := lookupKernel(i); ok {
+			return k.taps(), k.Func
+		}
+
 		// Default to NearestNeighbor.
 		return 2, nearest
 	}
 }
 
-// values <1 will sharpen the image
-var blur = 1.0
-
 // Calculates scaling factors using old and new image dimensions.
 func calcFactors(width, height uint, oldWidth, oldHeight float64) (scaleX, scaleY float64) {
 	if width == 0 {
@@ -1209,11 +836,15 @@ func (p *ycc) YCbCr() *image.YCbCr {
 	return ycbcr
 }
 
-// imageYCbCrToYCC converts a YCbCr image to a ycc image for resizing.
-func imageYCbCrToYCC(in *image.YCbCr) *ycc {
+// imageYCbCrToYCC converts a YCbCr image to a ycc image for resizing,
+// the pixels are stored in buf if it is large enough.
+func imageYCbCrToYCC(in *image.YCbCr, buf []uint8) *ycc {
 	w, h := in.Rect.Dx(), in.Rect.Dy()
 	r := image.Rect(0, 0, w, h)
-	buf := make([]uint8, 3*w*h)
+	if cap(buf) < 3*w*h {
+		buf = make([]uint8, 3*w*h)
+	}
+	buf = buf[:3*w*h]
 	p := ycc{Pix: buf, Stride: 3 * w, Rect: r, SubsampleRatio: in.SubsampleRatio}
 	var off int
 
@@ -1736,7 +1367,8 @@ var (
 	contextFlag [9]uint8
 )
 
-func (p *ImageTK) EnlargeImage(src image.Image, scaleA float64) (image.Image, error) {
+// EnlargeImage enlarges the image by the scale with hq2x, optional arguments are the options of ResizeImage for the final resizing
+func (p *ImageTK) EnlargeImage(src image.Image, scaleA float64, optsA ...interface{}) (image.Image, error) {
 	srcX, srcY := src.Bounds().Dx(), src.Bounds().Dy()
 
 	timesT := int(math.Ceil(math.Sqrt(scaleA)))
@@ -1759,7 +1391,7 @@ func (p *ImageTK) EnlargeImage(src image.Image, scaleA float64) (image.Image, er
 	nw, nh := int(float64(srcX)*scaleA), int(float64(srcY)*scaleA)
 
 	if (nw != w) && (nh != h) {
-		newImageT := p.ResizeImage(nw, nh, destT)
+		newImageT := p.ResizeImage(nw, nh, destT, optsA...)
 
 		return newImageT, nil
 	}
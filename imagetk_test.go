@@ -0,0 +1,65 @@
+package imagetk
+
+import (
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveImageAsFormat(t *testing.T) {
+	p := NewImageTK()
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 3))
+
+	tests := []struct {
+		name string
+		file string
+		opts []interface{}
+		want string
+		err  error
+	}{
+		{"extension", "a.jpg", nil, "jpeg", nil},
+		{"explicit format", "b.png", []interface{}{"gif"}, "gif", nil},
+		{"explicit extension form", "c", []interface{}{".TIF"}, "tiff", nil},
+		{"unknown extension", "d.xyz", nil, "png", nil},
+		{"no extension", "e", nil, "png", nil},
+		{"explicit without encoder", "f.png", []interface{}{"webp"}, "", ErrUnsupportedFormat},
+		{"explicit unknown", "g.png", []interface{}{"xyz"}, "", ErrUnsupportedFormat},
+		{"extension without encoder", "h.webp", nil, "", ErrUnsupportedFormat},
+	}
+
+	dirT := t.TempDir()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pathT := filepath.Join(dirT, tt.file)
+
+			err := p.SaveImageAs(img, pathT, tt.opts...)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("got %v, want %v", err, tt.err)
+				}
+
+				if _, err := os.Stat(pathT); !os.IsNotExist(err) {
+					t.Error("file written")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dataT, err := os.ReadFile(pathT)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := p.DetectFormat(dataT); got != tt.want {
+				t.Errorf("format %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package imagetk
+
+import (
+	"math"
+	"sync"
+)
+
+// Kernel is a resampling filter, Func is the weight of a source pixel at the distance(in source pixels)
+// and should be 0 outside of [-Support, Support]. A Support below minKernelSupport is raised to it.
+type Kernel struct {
+	Support float64
+	Func    func(float64) float64
+}
+
+// normalized returns the kernel with the support raised to minKernelSupport, a NaN support included
+func (k Kernel) normalized() Kernel {
+	if !(k.Support >= minKernelSupport) {
+		k.Support = minKernelSupport
+	}
+
+	return k
+}
+
+// taps returns the filter length covering the support at scale 1
+func (k Kernel) taps() int {
+	return 2 * int(math.Ceil(k.normalized().Support))
+}
+
+// minKernelSupport is the smallest support reaching the nearest source pixel
+const minKernelSupport = 0.5
+
+// firstCustomKernel is the first InterpolationFunction returned by RegisterKernel
+const firstCustomKernel InterpolationFunction = 256
+
+var (
+	kernelsMutexG sync.RWMutex
+	kernelsG      = map[InterpolationFunction]Kernel{}
+)
+
+// RegisterKernel registers the custom kernel and returns the InterpolationFunction to pass to ResizeImage and others,
+// it panics if the Func of the kernel is nil
+func RegisterKernel(kernelA Kernel) InterpolationFunction {
+	if kernelA.Func == nil {
+		panic("imagetk: RegisterKernel with a nil Func")
+	}
+
+	kernelsMutexG.Lock()
+	defer kernelsMutexG.Unlock()
+
+	idT := firstCustomKernel + InterpolationFunction(len(kernelsG))
+	kernelsG[idT] = kernelA.normalized()
+
+	return idT
+}
+
+func (p *ImageTK) RegisterKernel(kernelA Kernel) InterpolationFunction {
+	return RegisterKernel(kernelA)
+}
+
+func lookupKernel(idA InterpolationFunction) (Kernel, bool) {
+	kernelsMutexG.RLock()
+	defer kernelsMutexG.RUnlock()
+
+	k, ok := kernelsG[idA]
+
+	return k, ok
+}
+
+func box(in float64) float64 {
+	if in >= -0.5 && in < 0.5 {
+		return 1
+	}
+	return 0
+}
+
+func hermite(in float64) float64 {
+	in = math.Abs(in)
+	if in < 1 {
+		return in*in*(2*in-3) + 1
+	}
+	return 0
+}
+
+func gaussian(in float64) float64 {
+	if in > -2 && in < 2 {
+		return math.Exp(-2 * in * in)
+	}
+	return 0
+}
+
+func hann(in float64) float64 {
+	if in > -3 && in < 3 {
+		return sinc(in) * (0.5 + 0.5*math.Cos(math.Pi*in/3))
+	}
+	return 0
+}
+
+func hamming(in float64) float64 {
+	if in > -3 && in < 3 {
+		return sinc(in) * (0.54 + 0.46*math.Cos(math.Pi*in/3))
+	}
+	return 0
+}
+
+func bspline(in float64) float64 {
+	in = math.Abs(in)
+	if in < 1 {
+		return (in*in*(3*in-6) + 4) / 6
+	}
+	if in < 2 {
+		in = 2 - in
+		return in * in * in / 6
+	}
+	return 0
+}
@@ -0,0 +1,122 @@
+package imagetk
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// narrowBox is 1 only within a quarter pixel, so upscaling samples miss it
+func narrowBox(in float64) float64 {
+	if in >= -0.25 && in < 0.25 {
+		return 1
+	}
+	return 0
+}
+
+func TestRegisterKernelClampsSupport(t *testing.T) {
+	tests := []struct {
+		support float64
+		want    float64
+	}{
+		{0.25, minKernelSupport},
+		{0, minKernelSupport},
+		{-1, minKernelSupport},
+		{math.NaN(), minKernelSupport},
+		{0.5, 0.5},
+		{2.5, 2.5},
+	}
+
+	for _, tt := range tests {
+		k, ok := lookupKernel(RegisterKernel(Kernel{Support: tt.support, Func: narrowBox}))
+		if !ok {
+			t.Fatalf("support %v: kernel not registered", tt.support)
+		}
+
+		if k.Support != tt.want {
+			t.Errorf("support %v: got %v, want %v", tt.support, k.Support, tt.want)
+		}
+	}
+}
+
+func TestNarrowKernelFallsBackToNearest(t *testing.T) {
+	p := NewImageTK()
+
+	gray := image.NewGray(image.Rect(0, 0, 10, 10))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i * 2)
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	rgba64 := image.NewRGBA64(image.Rect(0, 0, 10, 10))
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			c := color.RGBA{uint8(20 * x), uint8(20 * y), uint8(x * y), 255}
+			rgba.Set(x, y, c)
+			rgba64.Set(x, y, c)
+			nrgba.Set(x, y, c)
+		}
+	}
+
+	idT := RegisterKernel(Kernel{Support: 0.25, Func: narrowBox})
+
+	tests := []struct {
+		name string
+		img  image.Image
+		opts interface{}
+	}{
+		{"gray registered", gray, idT},
+		{"rgba registered", rgba, idT},
+		{"rgba64 registered", rgba64, idT},
+		{"nrgba registered", nrgba, idT},
+		{"rgba option", rgba, Kernel{Support: 0.25, Func: narrowBox}},
+		{"gray zero kernel", gray, Kernel{Support: 1, Func: func(float64) float64 { return 0 }}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.ResizeImage(37, 23, tt.img, tt.opts)
+			want := p.ResizeImage(37, 23, tt.img, NearestNeighbor)
+
+			if got.Bounds() != want.Bounds() {
+				t.Fatalf("bounds %v, want %v", got.Bounds(), want.Bounds())
+			}
+
+			// every output pixel is one of the source pixels, not black
+			for y := 0; y < 23; y++ {
+				for x := 0; x < 37; x++ {
+					r, g, b, a := got.At(x, y).RGBA()
+					if a == 0 || (r == 0 && g == 0 && b == 0 && x > 0 && y > 0) {
+						t.Fatalf("pixel (%v,%v) is %v", x, y, got.At(x, y))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterKernelNilFunc(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic")
+		}
+	}()
+
+	RegisterKernel(Kernel{Support: 1})
+}
+
+func TestKernelDirectMatchesRegistered(t *testing.T) {
+	p := NewImageTK()
+	src := fillPattern(image.NewRGBA(image.Rect(0, 0, 20, 15)), false)
+
+	for _, v := range []float64{0.25, math.NaN(), -3, 2} {
+		k := Kernel{Support: v, Func: linear}
+
+		registered := p.ResizeImage(33, 7, src, RegisterKernel(k))
+		direct := p.ResizeImage(33, 7, src, k)
+
+		sameImage(t, direct, registered)
+	}
+}
@@ -0,0 +1,391 @@
+package imagetk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+var (
+	// ErrImageTooLarge is returned when the image dimensions exceed the decode limits
+	ErrImageTooLarge = errors.New("image too large")
+	// ErrTooManyFrames is returned when the animation has more frames than the decode limits allow
+	ErrTooManyFrames = errors.New("too many frames")
+	// ErrInputTooLarge is returned when the input is longer than the decode limits allow
+	ErrInputTooLarge = errors.New("input too large")
+)
+
+// DecodeLimits holds the limits checked before the full decoding, 0 means no limit for each field
+type DecodeLimits struct {
+	MaxWidth  int
+	MaxHeight int
+	// MaxPixels limits width*height of the image or the animation canvas
+	MaxPixels int64
+	// MaxFrames limits the number of frames of animations. The frames are counted before decoding, from an
+	// io.ReadSeeker(such as a file or bytes.Reader) by seeking back afterwards, from other readers by keeping
+	// the content read while counting in memory for the decoder, up to the whole GIF input(MaxBytes bounds it)
+	MaxFrames int
+	// MaxBytes limits the number of bytes read from the input, an io.ReadSeeker is checked by its length before reading
+	MaxBytes int64
+}
+
+// checkSize checks the image dimensions against the limits
+func (l *DecodeLimits) checkSize(widthA, heightA int) error {
+	if l == nil {
+		return nil
+	}
+
+	if (l.MaxWidth > 0 && widthA > l.MaxWidth) || (l.MaxHeight > 0 && heightA > l.MaxHeight) ||
+		(l.MaxPixels > 0 && int64(widthA)*int64(heightA) > l.MaxPixels) {
+		return fmt.Errorf("%w: %vx%v", ErrImageTooLarge, widthA, heightA)
+	}
+
+	return nil
+}
+
+// checkFrames checks the number of frames against the limits
+func (l *DecodeLimits) checkFrames(countA int) error {
+	if l == nil || l.MaxFrames < 1 || countA <= l.MaxFrames {
+		return nil
+	}
+
+	return fmt.Errorf("%w: more than %v", ErrTooManyFrames, l.MaxFrames)
+}
+
+// limitedReader returns ErrInputTooLarge instead of io.EOF if the underlying reader has more than n bytes
+type limitedReader struct {
+	r        io.Reader
+	n        int64
+	limit    int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// check whether there is more data
+		var bufT [1]byte
+
+		n, errT := l.r.Read(bufT[:])
+		if n > 0 {
+			l.exceeded = true
+			return 0, fmt.Errorf("%w: more than %v bytes", ErrInputTooLarge, l.limit)
+		}
+
+		return 0, errT
+	}
+
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+
+	n, errT := l.r.Read(p)
+	l.n -= int64(n)
+
+	return n, errT
+}
+
+// err returns ErrInputTooLarge if the limit has been exceeded, some decoders don't keep the original error
+func (l *limitedReader) err(errA error) error {
+	if l != nil && l.exceeded && !errors.Is(errA, ErrInputTooLarge) {
+		return fmt.Errorf("%w: %v", ErrInputTooLarge, errA)
+	}
+
+	return errA
+}
+
+// wrapReader limits the bytes read from the reader if MaxBytes is set
+func (l *DecodeLimits) wrapReader(r io.Reader) (io.Reader, *limitedReader) {
+	if l == nil || l.MaxBytes < 1 {
+		return r, nil
+	}
+
+	lr := &limitedReader{r: r, n: l.MaxBytes, limit: l.MaxBytes}
+
+	return lr, lr
+}
+
+// check checks the image size by the DecodeConfig of the format(and the frame count if framesA is true),
+// returns a reader replaying the consumed content followed by the rest of the stream
+func (l *DecodeLimits) check(r io.Reader, formatA string, framesA bool) (io.Reader, error) {
+	var bufT bytes.Buffer
+	teeT := io.TeeReader(r, &bufT)
+
+	f := lookupFormat(formatA)
+	if f != nil && f.DecodeConfig != nil {
+		cfgT, errT := f.DecodeConfig(teeT)
+		if errT != nil {
+			return nil, errT
+		}
+
+		errT = l.checkSize(cfgT.Width, cfgT.Height)
+		if errT != nil {
+			return nil, errT
+		}
+	}
+
+	if framesA && l.MaxFrames > 0 {
+		headT := bufT.Bytes()
+		replayT := io.MultiReader(bytes.NewReader(headT[:len(headT):len(headT)]), teeT)
+
+		countT, errT := countFrames(replayT, formatA, l.MaxFrames+1)
+		if errT != nil {
+			return nil, errT
+		}
+
+		errT = l.checkFrames(countT)
+		if errT != nil {
+			return nil, errT
+		}
+	}
+
+	return io.MultiReader(&bufT, r), nil
+}
+
+// checkSeeker checks the input length and the frame count of the seekable reader and seeks back to where it was,
+// so nothing is buffered while counting. Returns false if the reader could not seek, then check should be used.
+func (l *DecodeLimits) checkSeeker(s io.ReadSeeker) (bool, error) {
+	startT, errT := s.Seek(0, io.SeekCurrent)
+	if errT != nil {
+		return false, nil
+	}
+
+	endT, errT := s.Seek(0, io.SeekEnd)
+	if errT != nil {
+		return false, nil
+	}
+
+	_, errT = s.Seek(startT, io.SeekStart)
+	if errT != nil {
+		return true, errT
+	}
+
+	if l.MaxBytes > 0 && endT-startT > l.MaxBytes {
+		return true, fmt.Errorf("%w: %v bytes, more than %v", ErrInputTooLarge, endT-startT, l.MaxBytes)
+	}
+
+	if l.MaxFrames < 1 {
+		return true, nil
+	}
+
+	headT := make([]byte, maxMagicLen())
+	n, _ := io.ReadFull(s, headT)
+
+	_, errT = s.Seek(startT, io.SeekStart)
+	if errT != nil {
+		return true, errT
+	}
+
+	countT, errT := countFrames(s, detectFormat(headT[:n]), l.MaxFrames+1)
+
+	_, errSeekT := s.Seek(startT, io.SeekStart)
+	if errT != nil {
+		return true, errT
+	}
+
+	if errSeekT != nil {
+		return true, errSeekT
+	}
+
+	return true, l.checkFrames(countT)
+}
+
+// countFrames counts the frames of the animation in the format without decoding, stops counting at maxA for GIF
+func countFrames(r io.Reader, formatA string, maxA int) (int, error) {
+	switch formatA {
+	case "gif":
+		countT, _, errT := scanGIFFrames(r, maxA)
+		return countT, errT
+	case "png":
+		return countAPNGFrames(r)
+	}
+
+	return 1, nil
+}
+
+func discardGIFSubBlocks(r *bufio.Reader) error {
+	for {
+		sizeT, errT := r.ReadByte()
+		if errT != nil {
+			return errT
+		}
+
+		if sizeT == 0 {
+			return nil
+		}
+
+		_, errT = r.Discard(int(sizeT))
+		if errT != nil {
+			return errT
+		}
+	}
+}
+
+// scanGIFFrames counts the image descriptors of a GIF stream without decoding, stops counting at maxA if maxA > 0.
+// Also returns whether any frame has a transparent color.
+func scanGIFFrames(r io.Reader, maxA int) (int, bool, error) {
+	br := bufio.NewReader(r)
+
+	headerT := make([]byte, 13)
+
+	_, errT := io.ReadFull(br, headerT)
+	if errT != nil {
+		return 0, false, errT
+	}
+
+	if string(headerT[:3]) != "GIF" {
+		return 0, false, errors.New("gif: invalid format")
+	}
+
+	if headerT[10]&0x80 != 0 {
+		_, errT = br.Discard(3 << (headerT[10]&0x07 + 1))
+		if errT != nil {
+			return 0, false, errT
+		}
+	}
+
+	countT := 0
+	transparentT := false
+
+	for maxA < 1 || countT < maxA {
+		b, errT := br.ReadByte()
+		if errT != nil {
+			// truncated files are decoded up to the last complete frame
+			return countT, transparentT, nil
+		}
+
+		switch b {
+		case 0x21: // extension
+			var labelT byte
+
+			labelT, errT = br.ReadByte()
+			if errT != nil {
+				break
+			}
+
+			// graphic control extension, the lowest bit of the packed fields is the transparent flag
+			if labelT == 0xf9 {
+				var gceT []byte
+
+				gceT, errT = br.Peek(2)
+				if errT == nil && gceT[0] >= 1 && gceT[1]&0x01 != 0 {
+					transparentT = true
+				}
+			}
+
+			errT = discardGIFSubBlocks(br)
+		case 0x2c: // image descriptor
+			descT := make([]byte, 9)
+
+			_, errT = io.ReadFull(br, descT)
+			if errT != nil {
+				break
+			}
+
+			if descT[8]&0x80 != 0 {
+				_, errT = br.Discard(3 << (descT[8]&0x07 + 1))
+				if errT != nil {
+					break
+				}
+			}
+
+			// the LZW minimum code size
+			_, errT = br.ReadByte()
+			if errT == nil {
+				errT = discardGIFSubBlocks(br)
+			}
+
+			countT++
+		case 0x3b: // trailer
+			return countT, transparentT, nil
+		default:
+			return countT, transparentT, errors.New("gif: unknown block type")
+		}
+
+		if errT != nil {
+			return countT, transparentT, nil
+		}
+	}
+
+	return countT, transparentT, nil
+}
+
+// countAPNGFrames returns the number of frames declared by the acTL chunk, 1 for non-animated PNG
+func countAPNGFrames(r io.Reader) (int, error) {
+	br := bufio.NewReader(r)
+
+	sigT := make([]byte, len(pngSignature))
+
+	_, errT := io.ReadFull(br, sigT)
+	if errT != nil || string(sigT) != pngSignature {
+		return 0, errPNGFormat
+	}
+
+	headerT := make([]byte, 8)
+
+	for {
+		_, errT = io.ReadFull(br, headerT)
+		if errT != nil {
+			return 0, errT
+		}
+
+		lengthT := int64(binary.BigEndian.Uint32(headerT[:4]))
+
+		switch string(headerT[4:8]) {
+		case "acTL":
+			dataT := make([]byte, 4)
+
+			_, errT = io.ReadFull(br, dataT)
+			if errT != nil {
+				return 0, errT
+			}
+
+			return int(binary.BigEndian.Uint32(dataT)), nil
+		case "IDAT", "IEND":
+			return 1, nil
+		}
+
+		_, errT = io.CopyN(io.Discard, br, lengthT+4)
+		if errT != nil {
+			return 0, errT
+		}
+	}
+}
+
+// decodeImageLimited decodes the image after checking the limits, limitsA could be nil
+func decodeImageLimited(r io.Reader, limitsA *DecodeLimits) (image.Image, string, error) {
+	if limitsA == nil {
+		return decodeImage(r, "")
+	}
+
+	r, lr := limitsA.wrapReader(r)
+	rr := asPeekReader(r)
+
+	formatT, errT := sniffFormat(rr)
+	if errT != nil {
+		return nil, "", lr.err(errT)
+	}
+
+	replayT, errT := limitsA.check(rr, formatT, false)
+	if errT != nil {
+		return nil, formatT, lr.err(errT)
+	}
+
+	imgT, formatT, errT := decodeImage(replayT, formatT)
+	if errT != nil {
+		return nil, formatT, lr.err(errT)
+	}
+
+	// in case the format has no DecodeConfig
+	boundsT := imgT.Bounds()
+
+	errT = limitsA.checkSize(boundsT.Dx(), boundsT.Dy())
+	if errT != nil {
+		return nil, formatT, errT
+	}
+
+	return imgT, formatT, nil
+}
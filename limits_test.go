@@ -0,0 +1,185 @@
+package imagetk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"testing"
+)
+
+func TestDecodeLimits(t *testing.T) {
+	p := NewImageTK()
+	img := fillPattern(image.NewNRGBA(image.Rect(0, 0, 40, 30)), true)
+
+	formats := []string{"png", "jpeg", "gif", "bmp", "tiff", "qoi", "farbfeld", "pam"}
+
+	tests := []struct {
+		name   string
+		limits DecodeLimits
+		want   error
+	}{
+		{"within", DecodeLimits{MaxWidth: 40, MaxHeight: 30, MaxPixels: 1200, MaxBytes: 1 << 20}, nil},
+		{"width", DecodeLimits{MaxWidth: 39}, ErrImageTooLarge},
+		{"height", DecodeLimits{MaxHeight: 29}, ErrImageTooLarge},
+		{"pixels", DecodeLimits{MaxPixels: 1199}, ErrImageTooLarge},
+		{"bytes", DecodeLimits{MaxBytes: 100}, ErrInputTooLarge},
+	}
+
+	for _, f := range formats {
+		dataT, err := p.EncodeToBytes(img, f, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tt := range tests {
+			t.Run(f+" "+tt.name, func(t *testing.T) {
+				got, _, err := p.DecodeBytes(dataT, tt.limits)
+				if tt.want == nil {
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if got.Bounds().Size() != image.Pt(40, 30) {
+						t.Errorf("size %v", got.Bounds().Size())
+					}
+
+					return
+				}
+
+				if !errors.Is(err, tt.want) {
+					t.Errorf("got %v, want %v", err, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestDecodeLimitsForgedHeader(t *testing.T) {
+	p := NewImageTK()
+
+	// a header claiming 40000x40000 pixels without the pixels, below the size the codecs reject themselves
+	var pngT bytes.Buffer
+	pngT.WriteString(pngSignature)
+	ihdrT := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdrT[0:4], 40000)
+	binary.BigEndian.PutUint32(ihdrT[4:8], 40000)
+	ihdrT[8], ihdrT[9] = 8, 6
+	writePNGChunk(&pngT, "IHDR", ihdrT)
+
+	farbfeldT := []byte("farbfeld\x00\x00\x9c\x40\x00\x00\x9c\x40")
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"png", pngT.Bytes()},
+		{"farbfeld", farbfeldT},
+		{"pgm", []byte("P5 40000 40000 255\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := p.DecodeBytes(tt.data, &DecodeLimits{MaxPixels: 1 << 24})
+			if !errors.Is(err, ErrImageTooLarge) {
+				t.Errorf("got %v, want ErrImageTooLarge", err)
+			}
+		})
+	}
+}
+
+func TestDecodeLimitsFrames(t *testing.T) {
+	p := NewImageTK()
+	a := testAnimation(0, true)
+
+	for _, f := range []string{"gif", "apng"} {
+		var b bytes.Buffer
+		if err := p.EncodeAnimation(&b, a, f, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name   string
+			limits *DecodeLimits
+			want   error
+		}{
+			{"within", &DecodeLimits{MaxFrames: 4}, nil},
+			{"frames", &DecodeLimits{MaxFrames: 3}, ErrTooManyFrames},
+			{"canvas", &DecodeLimits{MaxPixels: 199}, ErrImageTooLarge},
+			{"bytes", &DecodeLimits{MaxBytes: 50}, ErrInputTooLarge},
+		}
+
+		// the frames of seekable readers are counted without buffering, others through the replay buffer
+		readers := []struct {
+			name string
+			new  func() io.Reader
+		}{
+			{"seeker", func() io.Reader { return bytes.NewReader(b.Bytes()) }},
+			{"stream", func() io.Reader { return struct{ io.Reader }{bytes.NewReader(b.Bytes())} }},
+		}
+
+		for _, rd := range readers {
+			for _, tt := range tests {
+				t.Run(f+" "+rd.name+" "+tt.name, func(t *testing.T) {
+					got, _, err := p.DecodeAnimation(rd.new(), tt.limits)
+					if tt.want == nil {
+						if err != nil {
+							t.Fatal(err)
+						}
+
+						if len(got.Frames) != len(a.Frames) {
+							t.Errorf("%v frames", len(got.Frames))
+						}
+
+						return
+					}
+
+					if !errors.Is(err, tt.want) {
+						t.Errorf("got %v, want %v", err, tt.want)
+					}
+				})
+			}
+		}
+	}
+}
+
+// countingSeeker counts the bytes read from the seekable reader
+type countingSeeker struct {
+	*bytes.Reader
+	n int
+}
+
+func (c *countingSeeker) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.n += n
+
+	return n, err
+}
+
+func TestDecodeLimitsSeeker(t *testing.T) {
+	var b bytes.Buffer
+	if err := NewImageTK().EncodeAnimation(&b, testAnimation(0, true), "gif", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the length is checked before anything is read
+	s := &countingSeeker{Reader: bytes.NewReader(b.Bytes())}
+
+	_, err := (&DecodeLimits{MaxBytes: 50}).checkSeeker(s)
+	if !errors.Is(err, ErrInputTooLarge) || s.n != 0 {
+		t.Errorf("got %v after reading %v bytes", err, s.n)
+	}
+
+	// counting the frames leaves the reader where it was
+	s = &countingSeeker{Reader: bytes.NewReader(b.Bytes())}
+
+	counted, err := (&DecodeLimits{MaxFrames: 3}).checkSeeker(s)
+	if !counted || !errors.Is(err, ErrTooManyFrames) {
+		t.Errorf("got %v, %v", counted, err)
+	}
+
+	if pos, _ := s.Seek(0, io.SeekCurrent); pos != 0 {
+		t.Errorf("reader left at %v", pos)
+	}
+}
@@ -0,0 +1,106 @@
+package imagetk
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"os"
+)
+
+// ImageMeta holds the metadata of an image kept across loading and saving
+type ImageMeta struct {
+	// Format is the format name detected from the content
+	Format string
+	// EXIF is nil if the image has no EXIF data
+	EXIF *EXIFInfo
+	// PNG is nil if the image is not PNG
+	PNG *PNGMeta
+}
+
+// encodeOptions returns a copy of the encode options with the metadata filled in, optsA could be nil
+func (m *ImageMeta) encodeOptions(optsA *EncodeOptions) *EncodeOptions {
+	if m == nil {
+		return optsA
+	}
+
+	optsT := &EncodeOptions{}
+	if optsA != nil {
+		*optsT = *optsA
+	}
+
+	if optsT.EXIF == nil && m.EXIF != nil && len(m.EXIF.Raw) > 0 && m.Format != "tiff" {
+		optsT.EXIF = m.EXIF.Raw
+	}
+
+	if optsT.PNGMeta == nil {
+		optsT.PNGMeta = m.PNG
+	}
+
+	return optsT
+}
+
+// decodeWithMeta decodes the image and reads the metadata from the same content
+func decodeWithMeta(r io.Reader, optsA *DecodeOptions) (image.Image, *ImageMeta, error) {
+	var limitsT *DecodeLimits
+	if optsA != nil {
+		limitsT = optsA.Limits
+	}
+
+	r, lr := limitsT.wrapReader(r)
+
+	dataT, errT := io.ReadAll(r)
+	if errT != nil {
+		return nil, nil, lr.err(errT)
+	}
+
+	imgT, formatT, errT := decodeImageWithOptions(bytes.NewReader(dataT), optsA)
+	if errT != nil {
+		return nil, nil, errT
+	}
+
+	metaT := &ImageMeta{Format: formatT}
+
+	rawT, errT := readEXIFPayload(bytes.NewReader(dataT))
+	if errT == nil && formatT != "tiff" {
+		metaT.EXIF, _ = parseEXIF(rawT)
+
+		// the orientation has been applied to the pixels
+		if metaT.EXIF != nil && metaT.EXIF.Orientation > 1 && optsA != nil && optsA.AutoOrient {
+			rawT = append([]byte(nil), rawT...)
+			if setEXIFOrientation(rawT, 1) == nil {
+				metaT.EXIF, _ = parseEXIF(rawT)
+			}
+		}
+	} else if errT == nil {
+		// the EXIF of TIFF is the file itself, not usable as a payload for other formats
+		metaT.EXIF, _ = parseEXIF(rawT)
+		if metaT.EXIF != nil {
+			metaT.EXIF.Raw = nil
+		}
+	}
+
+	// the pixels are decoded, a broken ancillary chunk only loses its own metadata
+	if formatT == "png" {
+		metaT.PNG, _ = readPNGMeta(bytes.NewReader(dataT), true)
+	}
+
+	return imgT, metaT, nil
+}
+
+// DecodeWithMeta decodes the image and its metadata(EXIF and PNG chunks) from the reader,
+// optional arguments are the same as DecodeFrom. Invalid ancillary PNG chunks are left out of the metadata.
+func (p *ImageTK) DecodeWithMeta(readerA io.Reader, optsA ...interface{}) (image.Image, *ImageMeta, error) {
+	return decodeWithMeta(readerA, getDecodeOptions(optsA))
+}
+
+// LoadImageWithMeta loads the image file and its metadata, passing the metadata to SaveImageAs keeps
+// the EXIF data and the PNG chunks. Optional arguments are the same as LoadImage.
+func (p *ImageTK) LoadImageWithMeta(fileNameA string, optsA ...interface{}) (image.Image, *ImageMeta, error) {
+	fileT, errT := os.Open(fileNameA)
+	if errT != nil {
+		return nil, nil, errT
+	}
+	defer fileT.Close()
+
+	return decodeWithMeta(fileT, getDecodeOptions(optsA))
+}
@@ -0,0 +1,785 @@
+package imagetk
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// netpbm(PBM/PGM/PPM/PAM) codecs, in both plain(ASCII) and raw(binary) forms
+
+var errNetpbmFormat = errors.New("netpbm: invalid format")
+
+type netpbmHeader struct {
+	// Magic is the number in the magic "P1"..."P7"
+	Magic     byte
+	Width     int
+	Height    int
+	Depth     int
+	MaxVal    int
+	TupleType string
+}
+
+func (h *netpbmHeader) plain() bool {
+	return h.Magic >= '1' && h.Magic <= '3'
+}
+
+func (h *netpbmHeader) hasAlpha() bool {
+	return h.Magic == '7' && (h.Depth == 2 || h.Depth == 4)
+}
+
+func (h *netpbmHeader) colorModel() color.Model {
+	wideT := h.MaxVal > 255
+
+	switch {
+	case h.Depth == 1 && wideT:
+		return color.Gray16Model
+	case h.Depth == 1:
+		return color.GrayModel
+	case h.hasAlpha() && wideT:
+		return color.NRGBA64Model
+	case h.hasAlpha():
+		return color.NRGBAModel
+	case wideT:
+		return color.RGBA64Model
+	default:
+		return color.RGBAModel
+	}
+}
+
+func isNetpbmSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// readNetpbmToken reads the next whitespace separated token, skipping comments
+func readNetpbmToken(r *bufio.Reader) (string, error) {
+	var sb strings.Builder
+
+	for {
+		c, errT := r.ReadByte()
+		if errT != nil {
+			if errT == io.EOF && sb.Len() > 0 {
+				return sb.String(), nil
+			}
+			return "", errT
+		}
+
+		if c == '#' {
+			_, errT = r.ReadString('\n')
+			if errT != nil && errT != io.EOF {
+				return "", errT
+			}
+
+			if sb.Len() > 0 {
+				return sb.String(), nil
+			}
+
+			continue
+		}
+
+		if isNetpbmSpace(c) {
+			if sb.Len() > 0 {
+				return sb.String(), nil
+			}
+
+			continue
+		}
+
+		sb.WriteByte(c)
+	}
+}
+
+func readNetpbmInt(r *bufio.Reader) (int, error) {
+	tokenT, errT := readNetpbmToken(r)
+	if errT != nil {
+		return 0, errT
+	}
+
+	n, errT := strconv.Atoi(tokenT)
+	if errT != nil || n < 0 {
+		return 0, errNetpbmFormat
+	}
+
+	return n, nil
+}
+
+// readNetpbmHeader reads the header, the reader is positioned at the start of the pixel data
+func readNetpbmHeader(r *bufio.Reader) (*netpbmHeader, error) {
+	magicT := make([]byte, 2)
+
+	_, errT := io.ReadFull(r, magicT)
+	if errT != nil {
+		return nil, errT
+	}
+
+	if magicT[0] != 'P' || magicT[1] < '1' || magicT[1] > '7' {
+		return nil, errNetpbmFormat
+	}
+
+	h := &netpbmHeader{Magic: magicT[1]}
+
+	if h.Magic == '7' {
+		return readPAMHeader(r, h)
+	}
+
+	h.Width, errT = readNetpbmInt(r)
+	if errT != nil {
+		return nil, errT
+	}
+
+	h.Height, errT = readNetpbmInt(r)
+	if errT != nil {
+		return nil, errT
+	}
+
+	switch h.Magic {
+	case '1', '4':
+		h.MaxVal = 1
+		h.Depth = 1
+		h.TupleType = "BLACKANDWHITE"
+	case '2', '5':
+		h.Depth = 1
+		h.TupleType = "GRAYSCALE"
+	default:
+		h.Depth = 3
+		h.TupleType = "RGB"
+	}
+
+	if h.MaxVal == 0 {
+		// the single whitespace after maxval is consumed by readNetpbmToken
+		h.MaxVal, errT = readNetpbmInt(r)
+		if errT != nil {
+			return nil, errT
+		}
+	}
+
+	return h, h.validate()
+}
+
+func readPAMHeader(r *bufio.Reader, h *netpbmHeader) (*netpbmHeader, error) {
+	for {
+		lineT, errT := r.ReadString('\n')
+		if errT != nil {
+			return nil, errNetpbmFormat
+		}
+
+		lineT = strings.TrimSpace(lineT)
+		if lineT == "" || strings.HasPrefix(lineT, "#") {
+			continue
+		}
+
+		fieldsT := strings.Fields(lineT)
+
+		if fieldsT[0] == "ENDHDR" {
+			break
+		}
+
+		if len(fieldsT) < 2 {
+			return nil, errNetpbmFormat
+		}
+
+		if fieldsT[0] == "TUPLTYPE" {
+			h.TupleType = strings.Join(fieldsT[1:], " ")
+			continue
+		}
+
+		n, errT := strconv.Atoi(fieldsT[1])
+		if errT != nil || n < 0 {
+			return nil, errNetpbmFormat
+		}
+
+		switch fieldsT[0] {
+		case "WIDTH":
+			h.Width = n
+		case "HEIGHT":
+			h.Height = n
+		case "DEPTH":
+			h.Depth = n
+		case "MAXVAL":
+			h.MaxVal = n
+		}
+	}
+
+	if h.Depth < 1 || h.Depth > 4 {
+		return nil, fmt.Errorf("netpbm: unsupported PAM depth %v", h.Depth)
+	}
+
+	return h, h.validate()
+}
+
+func (h *netpbmHeader) validate() error {
+	if h.Width < 1 || h.Height < 1 || h.MaxVal < 1 || h.MaxVal > 65535 {
+		return errNetpbmFormat
+	}
+
+	if int64(h.Width)*int64(h.Height) > 1<<31 {
+		return fmt.Errorf("netpbm: image too large (%vx%v)", h.Width, h.Height)
+	}
+
+	return nil
+}
+
+func (h *netpbmHeader) writeTo(w io.Writer) error {
+	var errT error
+
+	switch h.Magic {
+	case '7':
+		_, errT = fmt.Fprintf(w, "P7\nWIDTH %d\nHEIGHT %d\nDEPTH %d\nMAXVAL %d\nTUPLTYPE %s\nENDHDR\n", h.Width, h.Height, h.Depth, h.MaxVal, h.TupleType)
+	case '1', '4':
+		_, errT = fmt.Fprintf(w, "P%c\n%d %d\n", h.Magic, h.Width, h.Height)
+	default:
+		_, errT = fmt.Fprintf(w, "P%c\n%d %d\n%d\n", h.Magic, h.Width, h.Height, h.MaxVal)
+	}
+
+	return errT
+}
+
+// netpbmSampleReader reads samples scaled to 16 bits
+type netpbmSampleReader struct {
+	r      *bufio.Reader
+	h      *netpbmHeader
+	bitBuf byte
+	bitCnt int
+}
+
+func (s *netpbmSampleReader) scale(v int) uint16 {
+	if v > s.h.MaxVal {
+		v = s.h.MaxVal
+	}
+
+	return uint16((v*65535 + s.h.MaxVal/2) / s.h.MaxVal)
+}
+
+// next returns the next sample scaled to [0, 65535]
+func (s *netpbmSampleReader) next() (uint16, error) {
+	switch s.h.Magic {
+	case '1':
+		// plain PBM digits may not be separated by whitespace
+		for {
+			c, errT := s.r.ReadByte()
+			if errT != nil {
+				return 0, errT
+			}
+
+			if c == '#' {
+				s.r.ReadString('\n')
+				continue
+			}
+
+			if c == '0' {
+				return 0xffff, nil
+			}
+
+			if c == '1' {
+				return 0, nil
+			}
+
+			if !isNetpbmSpace(c) {
+				return 0, errNetpbmFormat
+			}
+		}
+	case '2', '3':
+		n, errT := readNetpbmInt(s.r)
+		if errT != nil {
+			return 0, errT
+		}
+
+		return s.scale(n), nil
+	case '4':
+		if s.bitCnt == 0 {
+			c, errT := s.r.ReadByte()
+			if errT != nil {
+				return 0, errT
+			}
+
+			s.bitBuf, s.bitCnt = c, 8
+		}
+
+		s.bitCnt--
+		if (s.bitBuf>>uint(s.bitCnt))&1 == 1 {
+			return 0, nil
+		}
+
+		return 0xffff, nil
+	default:
+		if s.h.MaxVal < 256 {
+			c, errT := s.r.ReadByte()
+			if errT != nil {
+				return 0, errT
+			}
+
+			return s.scale(int(c)), nil
+		}
+
+		c1, errT := s.r.ReadByte()
+		if errT != nil {
+			return 0, errT
+		}
+
+		c2, errT := s.r.ReadByte()
+		if errT != nil {
+			return 0, errT
+		}
+
+		return s.scale(int(c1)<<8 | int(c2)), nil
+	}
+}
+
+// endRow drops the padding bits at the end of a raw PBM row
+func (s *netpbmSampleReader) endRow() {
+	s.bitCnt = 0
+}
+
+// readRow reads a row of pixels as 16-bit non-premultiplied RGBA samples, 4 per pixel
+func (s *netpbmSampleReader) readRow(rowA []uint16) error {
+	var v [4]uint16
+
+	for x := 0; x < s.h.Width; x++ {
+		for c := 0; c < s.h.Depth; c++ {
+			sampleT, errT := s.next()
+			if errT != nil {
+				if errT == io.EOF {
+					errT = io.ErrUnexpectedEOF
+				}
+				return errT
+			}
+
+			v[c] = sampleT
+		}
+
+		switch s.h.Depth {
+		case 1:
+			v[1], v[2], v[3] = v[0], v[0], 0xffff
+		case 2:
+			v[3] = v[1]
+			v[1], v[2] = v[0], v[0]
+		case 3:
+			v[3] = 0xffff
+		}
+
+		copy(rowA[x*4:x*4+4], v[:])
+	}
+
+	s.endRow()
+
+	return nil
+}
+
+func decodeNetpbmConfig(r io.Reader) (image.Config, error) {
+	h, errT := readNetpbmHeader(bufio.NewReader(r))
+	if errT != nil {
+		return image.Config{}, errT
+	}
+
+	return image.Config{ColorModel: h.colorModel(), Width: h.Width, Height: h.Height}, nil
+}
+
+func decodeNetpbm(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+
+	h, errT := readNetpbmHeader(br)
+	if errT != nil {
+		return nil, errT
+	}
+
+	rectT := image.Rect(0, 0, h.Width, h.Height)
+	readerT := &netpbmSampleReader{r: br, h: h}
+	rowT := make([]uint16, h.Width*4)
+	wideT := h.MaxVal > 255
+
+	var imgT image.Image
+
+	for y := 0; y < h.Height; y++ {
+		errT = readerT.readRow(rowT)
+		if errT != nil {
+			return nil, errT
+		}
+
+		switch m := h.colorModel(); m {
+		case color.GrayModel:
+			if imgT == nil {
+				imgT = image.NewGray(rectT)
+			}
+			pixT := imgT.(*image.Gray).Pix[y*h.Width:]
+			for x := 0; x < h.Width; x++ {
+				pixT[x] = uint8(rowT[x*4] >> 8)
+			}
+		case color.Gray16Model:
+			if imgT == nil {
+				imgT = image.NewGray16(rectT)
+			}
+			pixT := imgT.(*image.Gray16).Pix[y*h.Width*2:]
+			for x := 0; x < h.Width; x++ {
+				pixT[x*2+0] = uint8(rowT[x*4] >> 8)
+				pixT[x*2+1] = uint8(rowT[x*4])
+			}
+		default:
+			var pixT []uint8
+
+			if imgT == nil {
+				switch {
+				case m == color.NRGBAModel:
+					imgT = image.NewNRGBA(rectT)
+				case m == color.NRGBA64Model:
+					imgT = image.NewNRGBA64(rectT)
+				case wideT:
+					imgT = image.NewRGBA64(rectT)
+				default:
+					imgT = image.NewRGBA(rectT)
+				}
+			}
+
+			switch nv := imgT.(type) {
+			case *image.NRGBA:
+				pixT = nv.Pix[y*nv.Stride:]
+			case *image.RGBA:
+				pixT = nv.Pix[y*nv.Stride:]
+			case *image.NRGBA64:
+				pixT = nv.Pix[y*nv.Stride:]
+			case *image.RGBA64:
+				pixT = nv.Pix[y*nv.Stride:]
+			}
+
+			if wideT {
+				// RGBA64 only used for opaque images so the samples need not be premultiplied
+				for i, v := range rowT {
+					pixT[i*2+0] = uint8(v >> 8)
+					pixT[i*2+1] = uint8(v)
+				}
+			} else {
+				for i, v := range rowT {
+					pixT[i] = uint8(v >> 8)
+				}
+			}
+		}
+	}
+
+	return imgT, nil
+}
+
+// netpbmHeaderFor returns the header used to encode the image in the format(pbm, pgm, ppm or pam)
+func netpbmHeaderFor(imageA image.Image, formatA string, plainA bool) (*netpbmHeader, error) {
+	boundsT := imageA.Bounds()
+
+	wideT := false
+	grayT := false
+
+	switch imageA.ColorModel() {
+	case color.Gray16Model:
+		wideT, grayT = true, true
+	case color.GrayModel:
+		grayT = true
+	case color.RGBA64Model, color.NRGBA64Model:
+		wideT = true
+	}
+
+	return newNetpbmHeader(boundsT.Dx(), boundsT.Dy(), formatA, wideT, grayT, formatA == "pam" && isOpaque(imageA), plainA)
+}
+
+// newNetpbmHeader returns the header of the format(pbm, pgm, ppm or pam) for the samples,
+// opaqueA is only used by pam
+func newNetpbmHeader(widthA, heightA int, formatA string, wideA, grayA, opaqueA, plainA bool) (*netpbmHeader, error) {
+	h := &netpbmHeader{Width: widthA, Height: heightA, MaxVal: 255}
+
+	if wideA {
+		h.MaxVal = 65535
+	}
+
+	switch formatA {
+	case "pbm":
+		h.Magic, h.Depth, h.MaxVal, h.TupleType = '4', 1, 1, "BLACKANDWHITE"
+	case "pgm":
+		h.Magic, h.Depth, h.TupleType = '5', 1, "GRAYSCALE"
+	case "ppm":
+		h.Magic, h.Depth, h.TupleType = '6', 3, "RGB"
+	case "pam":
+		h.Magic = '7'
+
+		switch {
+		case grayA:
+			h.Depth, h.TupleType = 1, "GRAYSCALE"
+		case opaqueA:
+			h.Depth, h.TupleType = 3, "RGB"
+		default:
+			h.Depth, h.TupleType = 4, "RGB_ALPHA"
+		}
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, formatA)
+	}
+
+	if plainA && h.Magic != '7' {
+		h.Magic -= 3
+	}
+
+	return h, nil
+}
+
+func isOpaque(imageA image.Image) bool {
+	if o, ok := imageA.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+
+	return false
+}
+
+// netpbmSampleWriter writes rows of 16-bit non-premultiplied RGBA samples in the header's form
+type netpbmSampleWriter struct {
+	w       *bufio.Writer
+	h       *netpbmHeader
+	lineLen int
+}
+
+func (s *netpbmSampleWriter) writePlain(v int) error {
+	strT := strconv.Itoa(v)
+
+	// plain lines should not be longer than 70 characters
+	if s.lineLen > 0 && s.lineLen+len(strT)+1 > 70 {
+		s.w.WriteByte('\n')
+		s.lineLen = 0
+	} else if s.lineLen > 0 {
+		s.w.WriteByte(' ')
+		s.lineLen++
+	}
+
+	s.lineLen += len(strT)
+	_, errT := s.w.WriteString(strT)
+
+	return errT
+}
+
+func (s *netpbmSampleWriter) writeRow(rowA []uint16) error {
+	var errT error
+
+	if s.h.Magic == '1' || s.h.Magic == '4' {
+		var bitsT byte
+		var nT int
+
+		for x := 0; x < s.h.Width; x++ {
+			r, g, b := uint32(rowA[x*4]), uint32(rowA[x*4+1]), uint32(rowA[x*4+2])
+
+			// 1 is black in PBM
+			bitT := 0
+			if (19595*r+38470*g+7471*b+1<<15)>>16 < 0x8000 {
+				bitT = 1
+			}
+
+			if s.h.Magic == '1' {
+				errT = s.writePlain(bitT)
+				continue
+			}
+
+			bitsT = bitsT<<1 | byte(bitT)
+			nT++
+
+			if nT == 8 {
+				s.w.WriteByte(bitsT)
+				bitsT, nT = 0, 0
+			}
+		}
+
+		if nT > 0 {
+			errT = s.w.WriteByte(bitsT << uint(8-nT))
+		}
+
+		if s.h.Magic == '1' {
+			s.w.WriteByte('\n')
+			s.lineLen = 0
+		}
+
+		return errT
+	}
+
+	var v [4]uint16
+
+	for x := 0; x < s.h.Width; x++ {
+		r, g, b, a := rowA[x*4], rowA[x*4+1], rowA[x*4+2], rowA[x*4+3]
+
+		switch s.h.Depth {
+		case 1:
+			v[0] = uint16((19595*uint32(r) + 38470*uint32(g) + 7471*uint32(b) + 1<<15) >> 16)
+		case 2:
+			v[0] = uint16((19595*uint32(r) + 38470*uint32(g) + 7471*uint32(b) + 1<<15) >> 16)
+			v[1] = a
+		default:
+			v[0], v[1], v[2], v[3] = r, g, b, a
+		}
+
+		for c := 0; c < s.h.Depth; c++ {
+			sampleT := (uint32(v[c])*uint32(s.h.MaxVal) + 32767) / 65535
+
+			switch {
+			case s.h.plain():
+				errT = s.writePlain(int(sampleT))
+			case s.h.MaxVal < 256:
+				errT = s.w.WriteByte(byte(sampleT))
+			default:
+				s.w.WriteByte(byte(sampleT >> 8))
+				errT = s.w.WriteByte(byte(sampleT))
+			}
+		}
+	}
+
+	if s.h.plain() {
+		s.w.WriteByte('\n')
+		s.lineLen = 0
+	}
+
+	return errT
+}
+
+// readImageRow reads a row of the image as 16-bit non-premultiplied RGBA samples
+func readImageRow(imageA image.Image, yA int, rowA []uint16) {
+	boundsT := imageA.Bounds()
+
+	for x := boundsT.Min.X; x < boundsT.Max.X; x++ {
+		c := color.NRGBA64Model.Convert(imageA.At(x, yA)).(color.NRGBA64)
+		i := (x - boundsT.Min.X) * 4
+		rowA[i+0], rowA[i+1], rowA[i+2], rowA[i+3] = c.R, c.G, c.B, c.A
+	}
+}
+
+func encodeNetpbm(w io.Writer, imageA image.Image, formatA string, optsA *EncodeOptions) error {
+	h, errT := netpbmHeaderFor(imageA, formatA, optsA != nil && optsA.NetpbmPlain)
+	if errT != nil {
+		return errT
+	}
+
+	bw := bufio.NewWriter(w)
+
+	errT = h.writeTo(bw)
+	if errT != nil {
+		return errT
+	}
+
+	writerT := &netpbmSampleWriter{w: bw, h: h}
+	rowT := make([]uint16, h.Width*4)
+
+	for y := imageA.Bounds().Min.Y; y < imageA.Bounds().Max.Y; y++ {
+		readImageRow(imageA, y, rowT)
+
+		errT = writerT.writeRow(rowT)
+		if errT != nil {
+			return errT
+		}
+	}
+
+	return bw.Flush()
+}
+
+// netpbmRowSource reads the rows of a netpbm image
+type netpbmRowSource struct {
+	reader *netpbmSampleReader
+	row    []uint16
+}
+
+func newNetpbmRowSource(br *bufio.Reader) (RowSource, error) {
+	h, errT := readNetpbmHeader(br)
+	if errT != nil {
+		return nil, errT
+	}
+
+	return &netpbmRowSource{reader: &netpbmSampleReader{r: br, h: h}, row: make([]uint16, h.Width*4)}, nil
+}
+
+// NewNetpbmRowSource returns a RowSource reading the PBM, PGM, PPM or PAM stream row by row
+func (p *ImageTK) NewNetpbmRowSource(r io.Reader) (RowSource, error) {
+	return newNetpbmRowSource(bufio.NewReader(r))
+}
+
+func (s *netpbmRowSource) Size() (int, int) {
+	return s.reader.h.Width, s.reader.h.Height
+}
+
+func (s *netpbmRowSource) wide() bool {
+	return s.reader.h.MaxVal > 255
+}
+
+func (s *netpbmRowSource) ReadRows(stripA *image.RGBA64) error {
+	for y := 0; y < stripA.Rect.Dy(); y++ {
+		errT := s.reader.readRow(s.row)
+		if errT != nil {
+			return errT
+		}
+
+		putPremultipliedRow(stripA.Pix[y*stripA.Stride:], s.row)
+	}
+
+	return nil
+}
+
+// netpbmRowSink writes the rows as a netpbm image
+type netpbmRowSink struct {
+	bw     *bufio.Writer
+	format string
+	wide   bool
+	plain  bool
+	writer *netpbmSampleWriter
+	row    []uint16
+}
+
+// NewNetpbmRowSink returns a RowSink writing the rows in the format(pbm, pgm, ppm or pam), wideA for 16-bit samples.
+// pam is written with alpha, the NetpbmPlain of optsA is used, optsA could be nil.
+func (p *ImageTK) NewNetpbmRowSink(w io.Writer, formatA string, wideA bool, optsA *EncodeOptions) (RowSink, error) {
+	formatT := normalizeFormat(formatA)
+
+	// checks the format
+	_, errT := newNetpbmHeader(0, 0, formatT, wideA, false, false, false)
+	if errT != nil {
+		return nil, errT
+	}
+
+	return &netpbmRowSink{bw: bufio.NewWriter(w), format: formatT, wide: wideA, plain: optsA != nil && optsA.NetpbmPlain}, nil
+}
+
+func (s *netpbmRowSink) Start(widthA, heightA int) error {
+	h, errT := newNetpbmHeader(widthA, heightA, s.format, s.wide, false, false, s.plain)
+	if errT != nil {
+		return errT
+	}
+
+	errT = h.writeTo(s.bw)
+	if errT != nil {
+		return errT
+	}
+
+	s.writer = &netpbmSampleWriter{w: s.bw, h: h}
+	s.row = make([]uint16, widthA*4)
+
+	return nil
+}
+
+func (s *netpbmRowSink) WriteRows(stripA *image.RGBA64) error {
+	for y := 0; y < stripA.Rect.Dy(); y++ {
+		getUnpremultipliedRow(s.row, stripA.Pix[y*stripA.Stride:])
+
+		errT := s.writer.writeRow(s.row)
+		if errT != nil {
+			return errT
+		}
+	}
+
+	return nil
+}
+
+func (s *netpbmRowSink) Finish() error {
+	return s.bw.Flush()
+}
+
+func init() {
+	for _, v := range [][2]string{{"pbm", "P1"}, {"pgm", "P2"}, {"ppm", "P3"}, {"pbm", "P4"}, {"pgm", "P5"}, {"ppm", "P6"}, {"pam", "P7"}} {
+		RegisterFormat(v[0], v[1], decodeNetpbm, decodeNetpbmConfig)
+		image.RegisterFormat(v[0], v[1], decodeNetpbm, decodeNetpbmConfig)
+	}
+
+	for _, v := range []string{"pbm", "pgm", "ppm", "pam"} {
+		formatT := v
+		RegisterEncoder(formatT, func(w io.Writer, imageA image.Image, optsA *EncodeOptions) error {
+			return encodeNetpbm(w, imageA, formatT, optsA)
+		})
+	}
+}
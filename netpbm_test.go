@@ -0,0 +1,115 @@
+package imagetk
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestNetpbmRoundTrip(t *testing.T) {
+	p := NewImageTK()
+
+	bw := image.NewGray(image.Rect(0, 0, 13, 5))
+	for i := range bw.Pix {
+		if i%3 == 0 {
+			bw.Pix[i] = 0xff
+		}
+	}
+
+	rgba := fillPattern(image.NewRGBA(image.Rect(0, 0, 7, 6)), false)
+
+	tests := []struct {
+		name   string
+		format string
+		plain  bool
+		img    image.Image
+		model  color.Model
+	}{
+		{"raw pbm", "pbm", false, bw, color.GrayModel},
+		{"plain pbm", "pbm", true, bw, color.GrayModel},
+		{"raw pgm", "pgm", false, fillPattern(image.NewGray(image.Rect(0, 0, 7, 6)), false), color.GrayModel},
+		{"plain pgm", "pgm", true, fillPattern(image.NewGray(image.Rect(0, 0, 7, 6)), false), color.GrayModel},
+		{"raw pgm 16-bit", "pgm", false, fillPattern(image.NewGray16(image.Rect(0, 0, 7, 6)), false), color.Gray16Model},
+		{"plain pgm 16-bit", "pgm", true, fillPattern(image.NewGray16(image.Rect(0, 0, 7, 6)), false), color.Gray16Model},
+		{"raw ppm", "ppm", false, rgba, color.RGBAModel},
+		{"plain ppm", "ppm", true, rgba, color.RGBAModel},
+		{"raw ppm 16-bit", "ppm", false, fillPattern(image.NewRGBA64(image.Rect(0, 0, 7, 6)), false), color.RGBA64Model},
+		{"ppm sub-image", "ppm", false, rgba.(*image.RGBA).SubImage(image.Rect(2, 1, 6, 5)), color.RGBAModel},
+		{"pam gray", "pam", false, fillPattern(image.NewGray(image.Rect(0, 0, 7, 6)), false), color.GrayModel},
+		{"pam rgb", "pam", false, rgba, color.RGBAModel},
+		{"pam rgba", "pam", false, fillPattern(image.NewNRGBA(image.Rect(0, 0, 7, 6)), true), color.NRGBAModel},
+		{"pam rgba 16-bit", "pam", false, fillPattern(image.NewNRGBA64(image.Rect(0, 0, 7, 6)), true), color.NRGBA64Model},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataT, err := p.EncodeToBytes(tt.img, tt.format, &EncodeOptions{NetpbmPlain: tt.plain})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, formatT, err := p.DecodeBytes(dataT)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if formatT != tt.format {
+				t.Errorf("format %v, want %v", formatT, tt.format)
+			}
+
+			if got.ColorModel() != tt.model {
+				t.Errorf("got %T", got)
+			}
+
+			sameImage(t, got, tt.img)
+		})
+	}
+}
+
+func TestNetpbmDecodeErrors(t *testing.T) {
+	p := NewImageTK()
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"truncated raw", "P5 4 4 255\n\x00\x01"},
+		{"truncated plain", "P2 2 2 255\n1 2 3"},
+		{"zero maxval", "P5 1 1 0\n\x00"},
+		{"large maxval", "P5 1 1 65536\n\x00\x00"},
+		{"non-numeric sample", "P2 1 1 10\nx"},
+		{"pam without depth", "P7\nWIDTH 1\nHEIGHT 1\nMAXVAL 255\nENDHDR\n\x00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := p.DecodeBytes([]byte(tt.data))
+			if err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
+
+func TestNetpbmComments(t *testing.T) {
+	p := NewImageTK()
+
+	got, _, err := p.DecodeBytes([]byte("P2 # width\n2 # height\n1\n# max\n255\n0 255"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := image.NewGray(image.Rect(0, 0, 2, 1))
+	want.Pix[1] = 255
+
+	sameImage(t, got, want)
+}
+
+func TestNetpbmEncodeUnknownFormat(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := encodeNetpbm(&b, image.NewGray(image.Rect(0, 0, 1, 1)), "pnm", nil); err == nil {
+		t.Error("no error")
+	}
+}
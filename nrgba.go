@@ -0,0 +1,125 @@
+package imagetk
+
+import (
+	"image"
+	"image/draw"
+)
+
+// premultiply converts the NRGBA or NRGBA64 image to premultiplied 16-bit samples
+func (r *Resizer) premultiply(dstA *image.RGBA64, srcA image.Image) {
+	w := dstA.Rect.Dx()
+
+	r.runRows(dstA.Rect.Dy(), func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			outT := dstA.Pix[y*dstA.Stride:]
+
+			switch nv := srcA.(type) {
+			case *image.NRGBA:
+				rowT := nv.Pix[y*nv.Stride:]
+				for x := 0; x < w; x++ {
+					s := rowT[4*x : 4*x+4]
+					a := uint32(s[3]) * 0x101
+					for i := 0; i < 3; i++ {
+						c := uint32(s[i]) * 0x101 * a / 0xffff
+						outT[8*x+2*i+0] = uint8(c >> 8)
+						outT[8*x+2*i+1] = uint8(c)
+					}
+					outT[8*x+6] = uint8(a >> 8)
+					outT[8*x+7] = uint8(a)
+				}
+			case *image.NRGBA64:
+				rowT := nv.Pix[y*nv.Stride:]
+				for x := 0; x < w; x++ {
+					s := rowT[8*x : 8*x+8]
+					a := uint32(s[6])<<8 | uint32(s[7])
+					for i := 0; i < 3; i++ {
+						c := (uint32(s[2*i])<<8 | uint32(s[2*i+1])) * a / 0xffff
+						outT[8*x+2*i+0] = uint8(c >> 8)
+						outT[8*x+2*i+1] = uint8(c)
+					}
+					outT[8*x+6] = s[6]
+					outT[8*x+7] = s[7]
+				}
+			}
+		}
+	})
+}
+
+// unpremultiply converts the premultiplied 16-bit samples back to the NRGBA or NRGBA64 image
+func (r *Resizer) unpremultiply(dstA image.Image, srcA *image.RGBA64) {
+	w := srcA.Rect.Dx()
+
+	r.runRows(srcA.Rect.Dy(), func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			rowT := srcA.Pix[y*srcA.Stride:]
+
+			switch nv := dstA.(type) {
+			case *image.NRGBA:
+				outT := nv.Pix[y*nv.Stride:]
+				for x := 0; x < w; x++ {
+					s := rowT[8*x : 8*x+8]
+					a := uint32(s[6])<<8 | uint32(s[7])
+					if a == 0 {
+						outT[4*x+0], outT[4*x+1], outT[4*x+2], outT[4*x+3] = 0, 0, 0, 0
+						continue
+					}
+					for i := 0; i < 3; i++ {
+						// filter overshoot could leave the color above the alpha
+						c := minUint32(uint32(s[2*i])<<8|uint32(s[2*i+1]), a)
+						outT[4*x+i] = uint8((c*0xff + a/2) / a)
+					}
+					// rounded, the high byte could be one above the alpha the colors were divided by
+					outT[4*x+3] = uint8((a*0xff + 0x7fff) / 0xffff)
+				}
+			case *image.NRGBA64:
+				outT := nv.Pix[y*nv.Stride:]
+				for x := 0; x < w; x++ {
+					s := rowT[8*x : 8*x+8]
+					a := uint32(s[6])<<8 | uint32(s[7])
+					if a == 0 {
+						copy(outT[8*x:8*x+8], []uint8{0, 0, 0, 0, 0, 0, 0, 0})
+						continue
+					}
+					for i := 0; i < 3; i++ {
+						c := minUint32(uint32(s[2*i])<<8|uint32(s[2*i+1]), a)
+						c = (c*0xffff + a/2) / a
+						outT[8*x+2*i+0] = uint8(c >> 8)
+						outT[8*x+2*i+1] = uint8(c)
+					}
+					outT[8*x+6] = s[6]
+					outT[8*x+7] = s[7]
+				}
+			}
+		}
+	})
+}
+
+// resizeNRGBA resizes the NRGBA or NRGBA64 image in premultiplied alpha and keeps its type,
+// so the colors of transparent pixels do not bleed into the edges. See resize for where the result is written.
+func (r *Resizer) resizeNRGBA(img image.Image, outA draw.Image, scratchA *ResizeScratch) image.Image {
+	boundsT := img.Bounds()
+
+	premulT := scratchA.rgba64(scratchSource, boundsT.Dx(), boundsT.Dy())
+	r.premultiply(premulT, img)
+
+	resultT := r.resizeEncoded(premulT, nil, scratchA).(*image.RGBA64)
+
+	var outT image.Image
+	if _, ok := img.(*image.NRGBA); ok {
+		if out, ok := outA.(*image.NRGBA); ok {
+			outT = out
+		} else {
+			outT = scratchA.nrgba(scratchOutput, r.dstWidth, r.dstHeight)
+		}
+	} else {
+		if out, ok := outA.(*image.NRGBA64); ok {
+			outT = out
+		} else {
+			outT = scratchA.nrgba64(scratchOutput, r.dstWidth, r.dstHeight)
+		}
+	}
+
+	r.unpremultiply(outT, resultT)
+
+	return outT
+}
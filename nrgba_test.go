@@ -0,0 +1,137 @@
+package imagetk
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+// noiseNRGBA returns an image of random colors and alpha, the alpha of some pixels is 0 and of some 255
+func noiseNRGBA(w, h int, seedA int64) *image.NRGBA {
+	rT := rand.New(rand.NewSource(seedA))
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	rT.Read(img.Pix)
+
+	for i := 3; i < len(img.Pix); i += 16 {
+		img.Pix[i] = 0
+		if i+8 < len(img.Pix) {
+			img.Pix[i+8] = 0xff
+		}
+	}
+
+	return img
+}
+
+// toNRGBA64 converts the image to NRGBA64
+func toNRGBA64(img image.Image) *image.NRGBA64 {
+	outT := image.NewNRGBA64(img.Bounds())
+	draw.Draw(outT, outT.Rect, img, outT.Rect.Min, draw.Src)
+
+	return outT
+}
+
+func TestResizeNRGBAKeepsType(t *testing.T) {
+	p := NewImageTK()
+	src := noiseNRGBA(30, 20, 1)
+
+	if got, ok := p.ResizeImage(12, 8, src).(*image.NRGBA); !ok {
+		t.Errorf("NRGBA resized to %T", got)
+	}
+
+	if got, ok := p.ResizeImage(12, 8, toNRGBA64(src)).(*image.NRGBA64); !ok {
+		t.Errorf("NRGBA64 resized to %T", got)
+	}
+
+	// an offset sub-image keeps the type as well
+	if got, ok := p.ResizeImage(45, 30, src.SubImage(image.Rect(5, 5, 20, 15))).(*image.NRGBA); !ok {
+		t.Errorf("NRGBA sub-image resized to %T", got)
+	}
+
+	// written directly into a destination of the same type
+	want := p.ResizeImage(12, 8, src)
+	dst := image.NewNRGBA(image.Rect(0, 0, 12, 8))
+
+	if err := p.ResizeInto(dst, src, Lanczos3, &ResizeScratch{}); err != nil {
+		t.Fatal(err)
+	}
+
+	sameImage(t, dst, want)
+}
+
+func TestResizeNRGBANoFringe(t *testing.T) {
+	p := NewImageTK()
+
+	// pure red on the left, transparent black on the right
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 20; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{255, 0, 0, 255})
+		}
+	}
+
+	for _, interp := range []InterpolationFunction{Bilinear, MitchellNetravali, Lanczos3} {
+		for _, img := range []image.Image{src, toNRGBA64(src)} {
+			for _, size := range []int{13, 90} {
+				got := p.ResizeImage(size, size, img, interp)
+
+				b := got.Bounds()
+				for y := b.Min.Y; y < b.Max.Y; y++ {
+					for x := b.Min.X; x < b.Max.X; x++ {
+						c := color.NRGBA64Model.Convert(got.At(x, y)).(color.NRGBA64)
+						if c.A == 0 {
+							continue
+						}
+
+						if c.R < 0xfe00 || c.G != 0 || c.B != 0 {
+							t.Fatalf("%v %T to %v: pixel (%v,%v) is %v, want pure red", interp, img, size, x, y, c)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestResizeNRGBAMatchesRGBA(t *testing.T) {
+	p := NewImageTK()
+
+	src := noiseNRGBA(36, 28, 2)
+
+	// the same pixels premultiplied, in 16 bits like the NRGBA path filters them
+	premulT := image.NewRGBA64(src.Rect)
+	draw.Draw(premulT, premulT.Rect, src, image.Point{}, draw.Src)
+
+	for _, interp := range []InterpolationFunction{NearestNeighbor, Bilinear, Lanczos3} {
+		for _, size := range []image.Point{{12, 9}, {50, 40}} {
+			got := p.ResizeImage(size.X, size.Y, src, interp)
+			want := p.ResizeImage(size.X, size.Y, premulT, interp)
+
+			for y := 0; y < size.Y; y++ {
+				for x := 0; x < size.X; x++ {
+					g := color.RGBAModel.Convert(got.At(x, y)).(color.RGBA)
+					w := color.RGBAModel.Convert(want.At(x, y)).(color.RGBA)
+
+					// the overshoot of the filter is kept at the alpha in NRGBA, RGBA could leave the color above it
+					w.R, w.G, w.B = minUint8(w.R, w.A), minUint8(w.G, w.A), minUint8(w.B, w.A)
+
+					for i, v := range [][2]uint8{{g.R, w.R}, {g.G, w.G}, {g.B, w.B}, {g.A, w.A}} {
+						if d := int(v[0]) - int(v[1]); d < -1 || d > 1 {
+							t.Fatalf("%v to %v: pixel (%v,%v) channel %v is %v, want %v±1", interp, size, x, y, i, g, w)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func minUint8(a, b uint8) uint8 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
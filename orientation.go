@@ -0,0 +1,178 @@
+package imagetk
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"io"
+)
+
+// DecodeOptions holds the options used when decoding images
+type DecodeOptions struct {
+	// AutoOrient rotates/flips the image according to the EXIF orientation tag
+	AutoOrient bool
+
+	// Limits are checked before the full decoding, nil means no limits
+	Limits *DecodeLimits
+}
+
+// getDecodeOptions collects the options from *DecodeOptions, DecodeOptions, *DecodeLimits and DecodeLimits
+func getDecodeOptions(optsA []interface{}) *DecodeOptions {
+	var optsT *DecodeOptions
+
+	for _, v := range optsA {
+		switch nv := v.(type) {
+		case *DecodeOptions:
+			if nv != nil {
+				limitsT := optsT
+				optsT = &DecodeOptions{}
+				*optsT = *nv
+				if limitsT != nil && optsT.Limits == nil {
+					optsT.Limits = limitsT.Limits
+				}
+			}
+		case DecodeOptions:
+			if optsT != nil && nv.Limits == nil {
+				nv.Limits = optsT.Limits
+			}
+			optsT = &nv
+		case *DecodeLimits:
+			if nv != nil {
+				if optsT == nil {
+					optsT = &DecodeOptions{}
+				}
+				optsT.Limits = nv
+			}
+		case DecodeLimits:
+			if optsT == nil {
+				optsT = &DecodeOptions{}
+			}
+			optsT.Limits = &nv
+		}
+	}
+
+	return optsT
+}
+
+func decodeImageWithOptions(r io.Reader, optsA *DecodeOptions) (image.Image, string, error) {
+	if optsA == nil {
+		return decodeImage(r, "")
+	}
+
+	if !optsA.AutoOrient {
+		return decodeImageLimited(r, optsA.Limits)
+	}
+
+	r, lr := optsA.Limits.wrapReader(r)
+
+	dataT, errT := io.ReadAll(r)
+	if errT != nil {
+		return nil, "", lr.err(errT)
+	}
+
+	imgT, formatT, errT := decodeImageLimited(bytes.NewReader(dataT), optsA.Limits)
+	if errT != nil {
+		return nil, formatT, errT
+	}
+
+	rawT, errT := readEXIFPayload(bytes.NewReader(dataT))
+	if errT != nil {
+		return imgT, formatT, nil
+	}
+
+	infoT, errT := parseEXIF(rawT)
+	if errT != nil {
+		return imgT, formatT, nil
+	}
+
+	return applyOrientation(imgT, infoT.Orientation), formatT, nil
+}
+
+// pixBuffer returns the pixel buffer, the stride and the bytes per pixel for the images storing pixels in Pix
+func pixBuffer(imageA image.Image) ([]uint8, int, int, bool) {
+	switch nv := imageA.(type) {
+	case *image.RGBA:
+		return nv.Pix, nv.Stride, 4, true
+	case *image.NRGBA:
+		return nv.Pix, nv.Stride, 4, true
+	case *image.RGBA64:
+		return nv.Pix, nv.Stride, 8, true
+	case *image.NRGBA64:
+		return nv.Pix, nv.Stride, 8, true
+	case *image.Gray:
+		return nv.Pix, nv.Stride, 1, true
+	case *image.Gray16:
+		return nv.Pix, nv.Stride, 2, true
+	case *image.CMYK:
+		return nv.Pix, nv.Stride, 4, true
+	case *image.Paletted:
+		return nv.Pix, nv.Stride, 1, true
+	}
+
+	return nil, 0, 0, false
+}
+
+// applyOrientation transforms the image according to the EXIF orientation(1-8), the result starts at (0,0)
+func applyOrientation(imageA image.Image, orientationA int) image.Image {
+	if orientationA < 2 || orientationA > 8 {
+		return imageA
+	}
+
+	boundsT := imageA.Bounds()
+	w, h := boundsT.Dx(), boundsT.Dy()
+
+	dstW, dstH := w, h
+	if orientationA >= 5 {
+		dstW, dstH = h, w
+	}
+
+	// mapT maps the source pixel to the destination pixel
+	var mapT func(x, y int) (int, int)
+
+	switch orientationA {
+	case 2: // flip horizontally
+		mapT = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3: // rotate 180
+		mapT = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4: // flip vertically
+		mapT = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5: // transpose
+		mapT = func(x, y int) (int, int) { return y, x }
+	case 6: // rotate 90 clockwise
+		mapT = func(x, y int) (int, int) { return h - 1 - y, x }
+	case 7: // transverse
+		mapT = func(x, y int) (int, int) { return h - 1 - y, w - 1 - x }
+	case 8: // rotate 90 counter-clockwise
+		mapT = func(x, y int) (int, int) { return y, w - 1 - x }
+	}
+
+	srcT := imageA
+	if _, _, _, ok := pixBuffer(srcT); !ok {
+		// such as YCbCr, convert to RGBA first
+		rgbaT := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(rgbaT, rgbaT.Bounds(), imageA, boundsT.Min, draw.Src)
+		srcT = rgbaT
+	}
+
+	dstT := newImageOf(srcT, image.Rect(0, 0, dstW, dstH))
+
+	srcPixT, srcStrideT, bppT, _ := pixBuffer(srcT)
+	dstPixT, dstStrideT, _, _ := pixBuffer(dstT)
+
+	for y := 0; y < h; y++ {
+		srcOffT := y * srcStrideT
+		for x := 0; x < w; x++ {
+			dx, dy := mapT(x, y)
+			dstOffT := dy*dstStrideT + dx*bppT
+			copy(dstPixT[dstOffT:dstOffT+bppT], srcPixT[srcOffT+x*bppT:srcOffT+(x+1)*bppT])
+		}
+	}
+
+	return dstT
+}
+
+// ApplyOrientation rotates/flips the image according to the EXIF orientation(1-8),
+// returns the image itself for orientation 1 or invalid values
+func (p *ImageTK) ApplyOrientation(imageA image.Image, orientationA int) image.Image {
+	return applyOrientation(imageA, orientationA)
+}
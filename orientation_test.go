@@ -0,0 +1,201 @@
+package imagetk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"testing"
+)
+
+// exifWithOrientation returns a big endian EXIF block holding only the orientation
+func exifWithOrientation(orientationA int) []byte {
+	var b bytes.Buffer
+
+	b.WriteString("MM\x00\x2a\x00\x00\x00\x08")
+	binary.Write(&b, binary.BigEndian, []uint16{1, 0x0112, 3})
+	binary.Write(&b, binary.BigEndian, []uint32{1, uint32(orientationA) << 16, 0})
+
+	return b.Bytes()
+}
+
+// orientedSource returns the source pixel shown at (x,y) of the image of the size w x h after the EXIF orientation
+func orientedSource(orientationA, x, y, w, h int) (int, int) {
+	switch orientationA {
+	case 2:
+		return w - 1 - x, y
+	case 3:
+		return w - 1 - x, h - 1 - y
+	case 4:
+		return x, h - 1 - y
+	case 5:
+		return y, x
+	case 6:
+		return y, h - 1 - x
+	case 7:
+		return w - 1 - y, h - 1 - x
+	case 8:
+		return w - 1 - y, x
+	}
+
+	return x, y
+}
+
+// checkOriented compares the result of the orientation with the source pixels it should show
+func checkOriented(t *testing.T, got, src image.Image, orientationA int) {
+	t.Helper()
+
+	sb := src.Bounds()
+	w, h := sb.Dx(), sb.Dy()
+
+	wantSizeT := image.Pt(w, h)
+	if orientationA >= 5 {
+		wantSizeT = image.Pt(h, w)
+	}
+
+	if got.Bounds() != (image.Rectangle{Max: wantSizeT}) {
+		t.Fatalf("bounds %v, want %v at (0,0)", got.Bounds(), wantSizeT)
+	}
+
+	for y := 0; y < wantSizeT.Y; y++ {
+		for x := 0; x < wantSizeT.X; x++ {
+			sx, sy := orientedSource(orientationA, x, y, w, h)
+			g := color.NRGBA64Model.Convert(got.At(x, y))
+			s := color.NRGBA64Model.Convert(src.At(sb.Min.X+sx, sb.Min.Y+sy))
+			if g != s {
+				t.Fatalf("pixel (%v,%v) is %v, want %v of (%v,%v)", x, y, g, s, sx, sy)
+			}
+		}
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	p := NewImageTK()
+
+	rgba := fillPattern(image.NewRGBA(image.Rect(0, 0, 5, 3)), false).(*image.RGBA)
+
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, 5, 3), image.YCbCrSubsampleRatio444)
+	for i := range ycbcr.Y {
+		ycbcr.Y[i], ycbcr.Cb[i], ycbcr.Cr[i] = uint8(16*i), uint8(100+i), uint8(150-i)
+	}
+
+	// YCbCr is converted to RGBA before it is oriented
+	ycbcrRGBA := image.NewRGBA(ycbcr.Rect)
+	draw.Draw(ycbcrRGBA, ycbcrRGBA.Rect, ycbcr, image.Point{}, draw.Src)
+
+	images := []struct {
+		name string
+		img  image.Image
+		want image.Image
+	}{
+		{"rgba", rgba, nil},
+		{"nrgba64", fillPattern(image.NewNRGBA64(image.Rect(0, 0, 5, 3)), true), nil},
+		{"gray", fillPattern(image.NewGray(image.Rect(0, 0, 5, 3)), false), nil},
+		{"gray16", fillPattern(image.NewGray16(image.Rect(0, 0, 4, 7)), false), nil},
+		{"sub-image", rgba.SubImage(image.Rect(1, 1, 5, 3)), nil},
+		{"ycbcr", ycbcr, ycbcrRGBA},
+	}
+
+	for _, im := range images {
+		for o := 1; o <= 8; o++ {
+			got := p.ApplyOrientation(im.img, o)
+
+			if o == 1 {
+				if got != im.img {
+					t.Errorf("%v: orientation 1 copied the image", im.name)
+				}
+				continue
+			}
+
+			wantT := im.want
+			if wantT == nil {
+				wantT = im.img
+			}
+
+			checkOriented(t, got, wantT, o)
+		}
+	}
+
+	for _, o := range []int{0, -1, 9} {
+		if p.ApplyOrientation(rgba, o) != image.Image(rgba) {
+			t.Errorf("orientation %v changed the image", o)
+		}
+	}
+}
+
+func TestAutoOrient(t *testing.T) {
+	p := NewImageTK()
+	src := fillPattern(image.NewNRGBA(image.Rect(0, 0, 6, 4)), false)
+
+	for o := 1; o <= 8; o++ {
+		dataT, err := p.EncodeToBytes(src, "jpg", &EncodeOptions{EXIF: exifWithOrientation(o)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// not rotated without AutoOrient, the JPEG pixels are compared to themselves
+		plainT, _, err := p.DecodeBytes(dataT)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if plainT.Bounds() != src.Bounds() {
+			t.Fatalf("bounds %v without AutoOrient", plainT.Bounds())
+		}
+
+		// compared in 8-bit RGBA, the rotated YCbCr pixels are converted to it
+		wantT := image.NewRGBA(plainT.Bounds())
+		draw.Draw(wantT, wantT.Rect, plainT, image.Point{}, draw.Src)
+
+		got, _, err := p.DecodeBytes(dataT, DecodeOptions{AutoOrient: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		gotT := image.NewRGBA(got.Bounds())
+		draw.Draw(gotT, gotT.Rect, got, got.Bounds().Min, draw.Src)
+
+		checkOriented(t, gotT, wantT, o)
+	}
+}
+
+func TestAutoOrientJPEG(t *testing.T) {
+	p := NewImageTK()
+
+	var jpegT bytes.Buffer
+	if err := jpeg.Encode(&jpegT, image.NewGray(image.Rect(0, 0, 16, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for o := 1; o <= 8; o++ {
+		var b bytes.Buffer
+		if err := writeJPEGWithEXIF(&b, jpegT.Bytes(), exifWithOrientation(o)); err != nil {
+			t.Fatal(err)
+		}
+
+		infoT, err := p.ReadEXIF(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if infoT.Orientation != o {
+			t.Errorf("orientation %v, want %v", infoT.Orientation, o)
+		}
+
+		got, _, err := p.DecodeBytes(b.Bytes(), &DecodeOptions{AutoOrient: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wantT := image.Rect(0, 0, 16, 8)
+		if o >= 5 {
+			wantT = image.Rect(0, 0, 8, 16)
+		}
+
+		if got.Bounds() != wantT {
+			t.Errorf("orientation %v: bounds %v, want %v", o, got.Bounds(), wantT)
+		}
+	}
+}
@@ -0,0 +1,616 @@
+package imagetk
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// PNGText is a text chunk of PNG(tEXt, zTXt or iTXt)
+type PNGText struct {
+	Keyword string
+	Text    string
+	// Compressed writes the text compressed(zTXt or compressed iTXt)
+	Compressed bool
+	// International writes the text as iTXt, which is also used automatically if the text is not Latin-1
+	International     bool
+	LanguageTag       string
+	TranslatedKeyword string
+}
+
+// PNGMeta holds the ancillary chunks of PNG, the zero value means no metadata
+type PNGMeta struct {
+	Texts []PNGText
+
+	// PixelsPerUnitX and PixelsPerUnitY are from the pHYs chunk, 0 means no pHYs chunk
+	PixelsPerUnitX uint32
+	PixelsPerUnitY uint32
+	// PhysUnit is 1 for meters, 0 for unknown(the aspect ratio only)
+	PhysUnit uint8
+
+	// Gamma is the value of the gAMA chunk, such as 0.45455, 0 means no gAMA chunk
+	Gamma float64
+
+	// HasSRGB means an sRGB chunk with the rendering intent SRGBIntent
+	HasSRGB    bool
+	SRGBIntent uint8
+
+	// ICCProfile is the uncompressed ICC profile of the iCCP chunk, nil means no iCCP chunk
+	ICCProfileName string
+	ICCProfile     []byte
+
+	// Chunks holds the other ancillary chunks such as cHRM, bKGD and tIME, written back as is,
+	// except that bKGD, sBIT and hIST are dropped if the color type, bit depth or palette size of the image differs
+	Chunks []PNGChunk
+
+	// source is the color of the image the chunks were read from, the zero value means unknown
+	source pngColorInfo
+}
+
+// pngColorInfo is the color type and bit depth of IHDR and the number of PLTE entries, which bKGD, sBIT and hIST depend on
+type pngColorInfo struct {
+	colorType  uint8
+	bitDepth   uint8
+	paletteLen int
+}
+
+// pngColorChunkLength returns the valid data length of bKGD, sBIT or hIST for the image, -1 if the chunk does not
+// depend on the color or is not allowed
+func pngColorChunkLength(typeA string, infoA pngColorInfo) int {
+	switch typeA {
+	case "bKGD":
+		switch infoA.colorType {
+		case 0, 4:
+			return 2
+		case 2, 6:
+			return 6
+		case 3:
+			return 1
+		}
+	case "sBIT":
+		switch infoA.colorType {
+		case 0:
+			return 1
+		case 2, 3:
+			return 3
+		case 4:
+			return 2
+		case 6:
+			return 4
+		}
+	case "hIST":
+		if infoA.colorType == 3 && infoA.paletteLen > 0 {
+			return 2 * infoA.paletteLen
+		}
+	default:
+		return -1
+	}
+
+	return 0
+}
+
+// keepColorChunk reports whether the chunk could be written into the image, the chunks depending on the color are kept only
+// if they were read from an image of the same color and fit it
+func (m *PNGMeta) keepColorChunk(c *PNGChunk, imageA pngColorInfo) bool {
+	lengthT := pngColorChunkLength(c.Type, imageA)
+	if lengthT < 0 {
+		return true
+	}
+
+	if m.source.bitDepth != 0 {
+		if m.source.colorType != imageA.colorType || m.source.bitDepth != imageA.bitDepth {
+			return false
+		}
+
+		if c.Type == "hIST" && m.source.paletteLen != imageA.paletteLen {
+			return false
+		}
+	}
+
+	return lengthT > 0 && len(c.Data) == lengthT
+}
+
+// parsePNGColorInfo returns the color type and bit depth of the IHDR data
+func parsePNGColorInfo(ihdrA []byte) pngColorInfo {
+	if len(ihdrA) < 13 {
+		return pngColorInfo{}
+	}
+
+	return pngColorInfo{colorType: ihdrA[9], bitDepth: ihdrA[8]}
+}
+
+// DPI returns the horizontal and vertical resolution in dots per inch, 0 if unknown
+func (m *PNGMeta) DPI() (float64, float64) {
+	if m.PhysUnit != 1 {
+		return 0, 0
+	}
+
+	return float64(m.PixelsPerUnitX) * 0.0254, float64(m.PixelsPerUnitY) * 0.0254
+}
+
+// SetDPI sets the pHYs chunk by the resolution in dots per inch
+func (m *PNGMeta) SetDPI(xA, yA float64) {
+	m.PixelsPerUnitX = uint32(math.Round(xA / 0.0254))
+	m.PixelsPerUnitY = uint32(math.Round(yA / 0.0254))
+	m.PhysUnit = 1
+}
+
+// GetText returns the text of the first text chunk with the keyword, or "" if not found
+func (m *PNGMeta) GetText(keywordA string) string {
+	for _, v := range m.Texts {
+		if v.Keyword == keywordA {
+			return v.Text
+		}
+	}
+
+	return ""
+}
+
+// SetText replaces the text of the chunk with the keyword or adds a new tEXt chunk
+func (m *PNGMeta) SetText(keywordA, textA string) {
+	for i, v := range m.Texts {
+		if v.Keyword == keywordA {
+			m.Texts[i].Text = textA
+			return
+		}
+	}
+
+	m.Texts = append(m.Texts, PNGText{Keyword: keywordA, Text: textA})
+}
+
+// chunks handled by the encoder or the APNG layer, not kept in PNGMeta
+var pngSkippedChunks = map[string]bool{"IHDR": true, "PLTE": true, "IDAT": true, "IEND": true, "tRNS": true, "acTL": true, "fcTL": true, "fdAT": true, "eXIf": true}
+
+// chunks which must appear before PLTE
+var pngHeadChunks = map[string]bool{"cHRM": true, "gAMA": true, "iCCP": true, "sBIT": true, "sRGB": true, "cICP": true, "mDCv": true, "cLLi": true}
+
+func latin1ToString(dataA []byte) string {
+	var sb strings.Builder
+
+	for _, v := range dataA {
+		sb.WriteRune(rune(v))
+	}
+
+	return sb.String()
+}
+
+// stringToLatin1 converts the string to Latin-1, returns false if any character is out of Latin-1
+func stringToLatin1(strA string) ([]byte, bool) {
+	bufT := make([]byte, 0, len(strA))
+
+	for _, v := range strA {
+		if v > 0xff {
+			return nil, false
+		}
+
+		bufT = append(bufT, byte(v))
+	}
+
+	return bufT, true
+}
+
+// maxPNGInflatedLength is the largest decompressed text or ICC profile, so a small chunk could not expand without bound
+const maxPNGInflatedLength = 1 << 24
+
+// zlibDecompress decompresses the data of zTXt, iTXt or iCCP, up to maxPNGInflatedLength bytes
+func zlibDecompress(dataA []byte) ([]byte, error) {
+	r, errT := zlib.NewReader(bytes.NewReader(dataA))
+	if errT != nil {
+		return nil, errT
+	}
+	defer r.Close()
+
+	dataT, errT := io.ReadAll(io.LimitReader(r, maxPNGInflatedLength+1))
+	if errT != nil {
+		return nil, errT
+	}
+
+	if len(dataT) > maxPNGInflatedLength {
+		return nil, fmt.Errorf("%w: decompressed chunk over %v bytes", ErrInputTooLarge, maxPNGInflatedLength)
+	}
+
+	return dataT, nil
+}
+
+func zlibCompress(dataA []byte) []byte {
+	var bufT bytes.Buffer
+
+	w := zlib.NewWriter(&bufT)
+	w.Write(dataA)
+	w.Close()
+
+	return bufT.Bytes()
+}
+
+// parsePNGText parses tEXt, zTXt and iTXt chunks
+func parsePNGText(c *PNGChunk) (*PNGText, error) {
+	keywordT, restT, ok := bytes.Cut(c.Data, []byte{0})
+	if !ok || len(keywordT) < 1 {
+		return nil, errPNGFormat
+	}
+
+	t := &PNGText{Keyword: latin1ToString(keywordT)}
+
+	switch c.Type {
+	case "tEXt":
+		t.Text = latin1ToString(restT)
+	case "zTXt":
+		if len(restT) < 1 || restT[0] != 0 {
+			return nil, errPNGFormat
+		}
+
+		dataT, errT := zlibDecompress(restT[1:])
+		if errT != nil {
+			return nil, errT
+		}
+
+		t.Text = latin1ToString(dataT)
+		t.Compressed = true
+	case "iTXt":
+		if len(restT) < 2 {
+			return nil, errPNGFormat
+		}
+
+		t.International = true
+		t.Compressed = restT[0] == 1
+
+		languageT, restT, ok := bytes.Cut(restT[2:], []byte{0})
+		if !ok {
+			return nil, errPNGFormat
+		}
+
+		translatedT, textT, ok := bytes.Cut(restT, []byte{0})
+		if !ok {
+			return nil, errPNGFormat
+		}
+
+		if t.Compressed {
+			var errT error
+
+			textT, errT = zlibDecompress(textT)
+			if errT != nil {
+				return nil, errT
+			}
+		}
+
+		t.LanguageTag = string(languageT)
+		t.TranslatedKeyword = string(translatedT)
+		t.Text = string(textT)
+	}
+
+	return t, nil
+}
+
+// chunk encodes the text as tEXt, zTXt or iTXt
+func (t *PNGText) chunk() (PNGChunk, error) {
+	keywordT, ok := stringToLatin1(t.Keyword)
+	if !ok || len(keywordT) < 1 || len(keywordT) > 79 {
+		return PNGChunk{}, fmt.Errorf("png: invalid keyword %q", t.Keyword)
+	}
+
+	textT, latin1T := stringToLatin1(t.Text)
+
+	var bufT bytes.Buffer
+	bufT.Write(keywordT)
+	bufT.WriteByte(0)
+
+	if t.International || !latin1T || t.LanguageTag != "" || t.TranslatedKeyword != "" {
+		if !utf8.ValidString(t.Text) {
+			return PNGChunk{}, fmt.Errorf("png: invalid UTF-8 text for %q", t.Keyword)
+		}
+
+		if t.Compressed {
+			bufT.Write([]byte{1, 0})
+		} else {
+			bufT.Write([]byte{0, 0})
+		}
+
+		bufT.WriteString(t.LanguageTag)
+		bufT.WriteByte(0)
+		bufT.WriteString(t.TranslatedKeyword)
+		bufT.WriteByte(0)
+
+		if t.Compressed {
+			bufT.Write(zlibCompress([]byte(t.Text)))
+		} else {
+			bufT.WriteString(t.Text)
+		}
+
+		return PNGChunk{Type: "iTXt", Data: bufT.Bytes()}, nil
+	}
+
+	if t.Compressed {
+		bufT.WriteByte(0)
+		bufT.Write(zlibCompress(textT))
+
+		return PNGChunk{Type: "zTXt", Data: bufT.Bytes()}, nil
+	}
+
+	bufT.Write(textT)
+
+	return PNGChunk{Type: "tEXt", Data: bufT.Bytes()}, nil
+}
+
+// addChunk parses the ancillary chunk into the metadata
+func (m *PNGMeta) addChunk(c *PNGChunk) error {
+	switch c.Type {
+	case "tEXt", "zTXt", "iTXt":
+		t, errT := parsePNGText(c)
+		if errT != nil {
+			return errT
+		}
+
+		m.Texts = append(m.Texts, *t)
+	case "pHYs":
+		if len(c.Data) != 9 {
+			return errPNGFormat
+		}
+
+		m.PixelsPerUnitX = binary.BigEndian.Uint32(c.Data[0:4])
+		m.PixelsPerUnitY = binary.BigEndian.Uint32(c.Data[4:8])
+		m.PhysUnit = c.Data[8]
+	case "gAMA":
+		if len(c.Data) != 4 {
+			return errPNGFormat
+		}
+
+		m.Gamma = float64(binary.BigEndian.Uint32(c.Data)) / 100000
+	case "sRGB":
+		if len(c.Data) != 1 {
+			return errPNGFormat
+		}
+
+		m.HasSRGB = true
+		m.SRGBIntent = c.Data[0]
+	case "iCCP":
+		nameT, restT, ok := bytes.Cut(c.Data, []byte{0})
+		if !ok || len(restT) < 1 || restT[0] != 0 {
+			return errPNGFormat
+		}
+
+		profileT, errT := zlibDecompress(restT[1:])
+		if errT != nil {
+			return errT
+		}
+
+		m.ICCProfileName = latin1ToString(nameT)
+		m.ICCProfile = profileT
+	default:
+		m.Chunks = append(m.Chunks, PNGChunk{Type: c.Type, Data: append([]byte(nil), c.Data...)})
+	}
+
+	return nil
+}
+
+// encodeChunks returns the chunks to be written before PLTE(headA is true) or before IDAT(headA is false) of the image
+func (m *PNGMeta) encodeChunks(headA bool, imageA pngColorInfo) ([]PNGChunk, error) {
+	var chunksT []PNGChunk
+
+	if headA {
+		if m.ICCProfile != nil {
+			nameT, ok := stringToLatin1(m.ICCProfileName)
+			if !ok || len(nameT) < 1 {
+				nameT = []byte("ICC profile")
+			}
+
+			dataT := append(nameT, 0, 0)
+			dataT = append(dataT, zlibCompress(m.ICCProfile)...)
+
+			chunksT = append(chunksT, PNGChunk{Type: "iCCP", Data: dataT})
+		}
+
+		if m.HasSRGB {
+			chunksT = append(chunksT, PNGChunk{Type: "sRGB", Data: []byte{m.SRGBIntent}})
+		}
+
+		if m.Gamma > 0 {
+			dataT := make([]byte, 4)
+			binary.BigEndian.PutUint32(dataT, uint32(math.Round(m.Gamma*100000)))
+
+			chunksT = append(chunksT, PNGChunk{Type: "gAMA", Data: dataT})
+		}
+	} else {
+		if m.PixelsPerUnitX > 0 || m.PixelsPerUnitY > 0 {
+			dataT := make([]byte, 9)
+			binary.BigEndian.PutUint32(dataT[0:4], m.PixelsPerUnitX)
+			binary.BigEndian.PutUint32(dataT[4:8], m.PixelsPerUnitY)
+			dataT[8] = m.PhysUnit
+
+			chunksT = append(chunksT, PNGChunk{Type: "pHYs", Data: dataT})
+		}
+
+		for i := range m.Texts {
+			c, errT := m.Texts[i].chunk()
+			if errT != nil {
+				return nil, errT
+			}
+
+			chunksT = append(chunksT, c)
+		}
+	}
+
+	for _, v := range m.Chunks {
+		if len(v.Type) != 4 || pngSkippedChunks[v.Type] || pngHeadChunks[v.Type] != headA || !m.keepColorChunk(&v, imageA) {
+			continue
+		}
+
+		chunksT = append(chunksT, v)
+	}
+
+	return chunksT, nil
+}
+
+// writePNGMetaChunks writes the metadata chunks and the eXIf chunk(for the tail part) into the image, metaA could be nil
+func writePNGMetaChunks(w io.Writer, metaA *PNGMeta, exifA []byte, headA bool, imageA pngColorInfo) error {
+	if metaA != nil {
+		chunksT, errT := metaA.encodeChunks(headA, imageA)
+		if errT != nil {
+			return errT
+		}
+
+		for _, v := range chunksT {
+			errT = writePNGChunk(w, v.Type, v.Data)
+			if errT != nil {
+				return errT
+			}
+		}
+	}
+
+	if !headA && len(exifA) > 0 {
+		return writePNGChunk(w, "eXIf", exifA)
+	}
+
+	return nil
+}
+
+// insertPNGMeta copies the PNG stream and inserts the metadata chunks after IHDR and before the first IDAT
+func insertPNGMeta(w io.Writer, r io.Reader, metaA *PNGMeta, exifA []byte) error {
+	sigT := make([]byte, len(pngSignature))
+
+	_, errT := io.ReadFull(r, sigT)
+	if errT != nil || string(sigT) != pngSignature {
+		return errPNGFormat
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.Write(sigT)
+
+	tailWrittenT := false
+	var infoT pngColorInfo
+
+	for {
+		c, errT := readPNGChunk(r)
+		if errT != nil {
+			return errT
+		}
+
+		switch c.Type {
+		case "IHDR":
+			infoT = parsePNGColorInfo(c.Data)
+		case "PLTE":
+			infoT.paletteLen = len(c.Data) / 3
+		}
+
+		if c.Type == "IDAT" && !tailWrittenT {
+			errT = writePNGMetaChunks(bw, metaA, exifA, false, infoT)
+			if errT != nil {
+				return errT
+			}
+
+			tailWrittenT = true
+		}
+
+		errT = writePNGChunk(bw, c.Type, c.Data)
+		if errT != nil {
+			return errT
+		}
+
+		if c.Type == "IHDR" {
+			errT = writePNGMetaChunks(bw, metaA, exifA, true, infoT)
+			if errT != nil {
+				return errT
+			}
+		}
+
+		if c.Type == "IEND" {
+			break
+		}
+	}
+
+	return bw.Flush()
+}
+
+// readPNGMeta reads the ancillary chunks of the PNG stream, the image data is skipped without buffering.
+// The invalid ancillary chunks are skipped if skipInvalidA is true, otherwise they are reported as errors.
+func readPNGMeta(r io.Reader, skipInvalidA bool) (*PNGMeta, error) {
+	br := bufio.NewReader(r)
+
+	sigT := make([]byte, len(pngSignature))
+
+	_, errT := io.ReadFull(br, sigT)
+	if errT != nil || string(sigT) != pngSignature {
+		return nil, errPNGFormat
+	}
+
+	m := &PNGMeta{}
+	headerT := make([]byte, 8)
+
+	for {
+		headerBytesT, errT := br.Peek(8)
+		if errT != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		copy(headerT, headerBytesT)
+
+		lengthT := int64(binary.BigEndian.Uint32(headerT[:4]))
+		typeT := string(headerT[4:8])
+
+		if typeT == "IEND" {
+			return m, nil
+		}
+
+		if typeT == "IHDR" || typeT == "PLTE" {
+			c, errT := readPNGChunk(br)
+			if errT != nil {
+				return nil, errT
+			}
+
+			if typeT == "IHDR" {
+				m.source = parsePNGColorInfo(c.Data)
+			} else {
+				m.source.paletteLen = len(c.Data) / 3
+			}
+
+			continue
+		}
+
+		if pngSkippedChunks[typeT] {
+			_, errT = io.CopyN(io.Discard, br, 8+lengthT+4)
+			if errT != nil {
+				return nil, io.ErrUnexpectedEOF
+			}
+
+			continue
+		}
+
+		c, errT := readPNGChunk(br)
+		if errT != nil {
+			return nil, errT
+		}
+
+		// the lowercase first letter means ancillary
+		if c.Type[0]&0x20 == 0 {
+			continue
+		}
+
+		errT = m.addChunk(c)
+		if errT != nil && !skipInvalidA {
+			return nil, fmt.Errorf("png: invalid %v chunk: %w", c.Type, errT)
+		}
+	}
+}
+
+// ReadPNGMeta reads the text, pHYs, gAMA, sRGB, iCCP and other ancillary chunks of a PNG stream,
+// the eXIf chunk is read by ReadEXIF
+func (p *ImageTK) ReadPNGMeta(readerA io.Reader) (*PNGMeta, error) {
+	return readPNGMeta(readerA, false)
+}
+
+// LoadPNGMeta reads the ancillary chunks of a PNG file
+func (p *ImageTK) LoadPNGMeta(fileNameA string) (*PNGMeta, error) {
+	fileT, errT := os.Open(fileNameA)
+	if errT != nil {
+		return nil, errT
+	}
+	defer fileT.Close()
+
+	return readPNGMeta(fileT, false)
+}
@@ -0,0 +1,230 @@
+package imagetk
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestPNGMetaTextRoundTrip(t *testing.T) {
+	p := NewImageTK()
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 3))
+
+	tests := []struct {
+		name string
+		text PNGText
+	}{
+		{"tEXt", PNGText{Keyword: "Title", Text: "plain"}},
+		{"zTXt", PNGText{Keyword: "Comment", Text: strings.Repeat("compressed ", 100), Compressed: true}},
+		{"iTXt", PNGText{Keyword: "Author", Text: "画像", LanguageTag: "ja"}},
+		{"compressed iTXt", PNGText{Keyword: "Author", Text: "画像", Compressed: true, International: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			err := p.EncodeTo(&b, img, "png", &EncodeOptions{PNGMeta: &PNGMeta{Texts: []PNGText{tt.text}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m, err := p.ReadPNGMeta(&b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(m.Texts) != 1 || m.Texts[0].Keyword != tt.text.Keyword || m.Texts[0].Text != tt.text.Text {
+				t.Errorf("got %+v, want %+v", m.Texts, tt.text)
+			}
+		})
+	}
+}
+
+func TestPNGMetaRejectsLargeDecompressed(t *testing.T) {
+	p := NewImageTK()
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 3))
+
+	tests := []struct {
+		name string
+		meta *PNGMeta
+	}{
+		{"zTXt", &PNGMeta{Texts: []PNGText{{Keyword: "Comment", Text: strings.Repeat("a", maxPNGInflatedLength+1), Compressed: true}}}},
+		{"iCCP", &PNGMeta{ICCProfile: make([]byte, maxPNGInflatedLength+1)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			err := p.EncodeTo(&b, img, "png", &EncodeOptions{PNGMeta: tt.meta})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = p.ReadPNGMeta(&b)
+			if !errors.Is(err, ErrInputTooLarge) {
+				t.Errorf("got %v, want ErrInputTooLarge", err)
+			}
+		})
+	}
+}
+
+// pngChunkTypes returns the types of the ancillary chunks in the PNG stream
+func pngChunkTypes(t *testing.T, dataA []byte) map[string]bool {
+	m, err := readPNGMeta(bytes.NewReader(dataA), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	typesT := map[string]bool{}
+	for _, v := range m.Chunks {
+		typesT[v.Type] = true
+	}
+
+	return typesT
+}
+
+func TestPNGMetaColorChunks(t *testing.T) {
+	p := NewImageTK()
+
+	paletteT := color.Palette{color.Black, color.White, color.Gray{0x80}, color.Gray{0x40}}
+
+	srcMetaT := &PNGMeta{Chunks: []PNGChunk{
+		{Type: "bKGD", Data: []byte{0, 1, 0, 2, 0, 3}},
+		{Type: "sBIT", Data: []byte{8, 8, 8, 8}},
+		{Type: "tIME", Data: []byte{0x07, 0xea, 1, 2, 3, 4, 5}},
+	}}
+
+	var srcT bytes.Buffer
+	err := p.EncodeTo(&srcT, image.NewNRGBA(image.Rect(0, 0, 4, 3)), "png", &EncodeOptions{PNGMeta: srcMetaT})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metaT, err := p.ReadPNGMeta(bytes.NewReader(srcT.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paletted4 := image.NewPaletted(image.Rect(0, 0, 4, 3), paletteT)
+	paletted3 := image.NewPaletted(image.Rect(0, 0, 4, 3), paletteT[:3])
+
+	var histSrcT bytes.Buffer
+	err = p.EncodeTo(&histSrcT, paletted4, "png", &EncodeOptions{PNGMeta: &PNGMeta{Chunks: []PNGChunk{
+		{Type: "hIST", Data: []byte{0, 1, 0, 2, 0, 3, 0, 4}},
+		{Type: "bKGD", Data: []byte{1}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	histMetaT, err := p.ReadPNGMeta(bytes.NewReader(histSrcT.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		meta *PNGMeta
+		img  image.Image
+		want []string
+		drop []string
+	}{
+		{"unknown source", srcMetaT, image.NewNRGBA(image.Rect(0, 0, 4, 3)), []string{"bKGD", "sBIT", "tIME"}, nil},
+		{"unknown source invalid length", srcMetaT, image.NewGray(image.Rect(0, 0, 4, 3)), []string{"tIME"}, []string{"bKGD", "sBIT"}},
+		{"same color", metaT, image.NewNRGBA(image.Rect(0, 0, 4, 3)), []string{"bKGD", "sBIT", "tIME"}, nil},
+		{"other color type", metaT, image.NewGray16(image.Rect(0, 0, 4, 3)), []string{"tIME"}, []string{"bKGD", "sBIT"}},
+		{"other bit depth", metaT, image.NewNRGBA64(image.Rect(0, 0, 4, 3)), []string{"tIME"}, []string{"bKGD", "sBIT"}},
+		{"same palette", histMetaT, paletted4, []string{"hIST", "bKGD"}, nil},
+		{"other palette", histMetaT, paletted3, []string{"bKGD"}, []string{"hIST"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			err := p.EncodeTo(&b, tt.img, "png", &EncodeOptions{PNGMeta: tt.meta})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			typesT := pngChunkTypes(t, b.Bytes())
+			for _, v := range tt.want {
+				if !typesT[v] {
+					t.Errorf("%v dropped", v)
+				}
+			}
+			for _, v := range tt.drop {
+				if typesT[v] {
+					t.Errorf("%v kept", v)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeWithMetaSkipsInvalidChunks(t *testing.T) {
+	p := NewImageTK()
+	img := fillPattern(image.NewNRGBA(image.Rect(0, 0, 4, 3)), true)
+
+	plainT, err := p.EncodeToBytes(img, "png", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the chunks are inserted after IHDR, which ends at byte 33
+	var b bytes.Buffer
+	b.Write(plainT[:33])
+	writePNGChunk(&b, "zTXt", []byte("Comment\x00\x00not zlib data"))
+	writePNGChunk(&b, "iCCP", append([]byte("ICC profile\x00\x00"), zlibCompress(make([]byte, maxPNGInflatedLength+1))...))
+	writePNGChunk(&b, "tEXt", []byte("Title\x00kept"))
+	b.Write(plainT[33:])
+
+	got, metaT, err := p.DecodeWithMeta(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sameImage(t, got, img)
+
+	if metaT.PNG == nil || len(metaT.PNG.Texts) != 1 || metaT.PNG.Texts[0].Text != "kept" {
+		t.Fatalf("got %+v", metaT.PNG)
+	}
+
+	if metaT.PNG.ICCProfile != nil {
+		t.Error("oversized ICC profile kept")
+	}
+
+	// reading the metadata alone still reports the first invalid chunk
+	if _, err := p.ReadPNGMeta(bytes.NewReader(b.Bytes())); err == nil {
+		t.Error("no error from ReadPNGMeta")
+	}
+}
+
+func TestPNGEXIFAutoOrient(t *testing.T) {
+	p := NewImageTK()
+	src := fillPattern(image.NewNRGBA(image.Rect(0, 0, 6, 4)), true)
+
+	for o := 1; o <= 8; o++ {
+		dataT, err := p.EncodeToBytes(src, "png", &EncodeOptions{EXIF: exifWithOrientation(o)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, _, err := p.DecodeBytes(dataT, DecodeOptions{AutoOrient: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkOriented(t, got, src, o)
+
+		// not rotated without AutoOrient
+		plainT, _, err := p.DecodeBytes(dataT)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sameImage(t, plainT, src)
+	}
+}
@@ -0,0 +1,526 @@
+package imagetk
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+)
+
+// PNG row by row decoding and encoding for RowSource and RowSink, only the IDAT data being
+// decompressed or compressed and two rows are held in memory
+
+// pngIDATChunkSize is the size of the IDAT chunks written by the PNG row sink
+const pngIDATChunkSize = 1 << 16
+
+// pngIDATReader reads the data of the consecutive IDAT chunks, checking their CRC
+type pngIDATReader struct {
+	r         io.Reader
+	remaining uint32
+	crc       hash.Hash32
+	done      bool
+}
+
+func (d *pngIDATReader) Read(p []byte) (int, error) {
+	for d.remaining == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+
+		headerT := make([]byte, 8)
+
+		_, errT := io.ReadFull(d.r, headerT[:4])
+		if errT != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		if binary.BigEndian.Uint32(headerT[:4]) != d.crc.Sum32() {
+			return 0, fmt.Errorf("png: invalid checksum in chunk IDAT")
+		}
+
+		_, errT = io.ReadFull(d.r, headerT)
+		if errT != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		// the data ends with the first chunk other than IDAT, the chunks after it are not read
+		if string(headerT[4:8]) != "IDAT" {
+			d.done = true
+			return 0, io.EOF
+		}
+
+		d.start(headerT)
+	}
+
+	if uint32(len(p)) > d.remaining {
+		p = p[:d.remaining]
+	}
+
+	n, errT := d.r.Read(p)
+	d.crc.Write(p[:n])
+	d.remaining -= uint32(n)
+
+	if errT == io.EOF {
+		errT = io.ErrUnexpectedEOF
+	}
+
+	return n, errT
+}
+
+// start begins reading the IDAT chunk with the header
+func (d *pngIDATReader) start(headerA []byte) {
+	d.remaining = binary.BigEndian.Uint32(headerA[:4])
+	d.crc = crc32.NewIEEE()
+	d.crc.Write(headerA[4:8])
+}
+
+// pngRowSource decodes the rows of a non-interlaced PNG
+type pngRowSource struct {
+	width, height int
+	depth         int
+	colorType     int
+
+	// non-premultiplied 16-bit RGBA of the palette entries
+	palette [][4]uint16
+	// the transparent color of gray or RGB images, in samples of the image depth
+	transparent    [3]uint16
+	hasTransparent bool
+
+	z    io.ReadCloser
+	bpp  int
+	cur  []uint8
+	prev []uint8
+	row  []uint16
+	y    int
+}
+
+// channels returns the number of samples of a pixel
+func (s *pngRowSource) channels() int {
+	switch s.colorType {
+	case 2:
+		return 3
+	case 4:
+		return 2
+	case 6:
+		return 4
+	}
+
+	return 1
+}
+
+func newPNGRowSource(br *bufio.Reader) (RowSource, error) {
+	sigT := make([]byte, len(pngSignature))
+
+	_, errT := io.ReadFull(br, sigT)
+	if errT != nil || string(sigT) != pngSignature {
+		return nil, errPNGFormat
+	}
+
+	c, errT := readPNGChunk(br)
+	if errT != nil {
+		return nil, errT
+	}
+
+	if c.Type != "IHDR" || len(c.Data) != 13 {
+		return nil, errPNGFormat
+	}
+
+	s := &pngRowSource{width: int(binary.BigEndian.Uint32(c.Data[0:4])), height: int(binary.BigEndian.Uint32(c.Data[4:8])),
+		depth: int(c.Data[8]), colorType: int(c.Data[9])}
+
+	validT := false
+	switch s.colorType {
+	case 0:
+		validT = s.depth == 1 || s.depth == 2 || s.depth == 4 || s.depth == 8 || s.depth == 16
+	case 3:
+		validT = s.depth == 1 || s.depth == 2 || s.depth == 4 || s.depth == 8
+	case 2, 4, 6:
+		validT = s.depth == 8 || s.depth == 16
+	}
+
+	if !validT || s.width < 1 || s.height < 1 || s.width > 0x7fffffff || s.height > 0x7fffffff {
+		return nil, errPNGFormat
+	}
+
+	if c.Data[12] != 0 {
+		return nil, fmt.Errorf("%w: interlaced PNG could not be read row by row", ErrUnsupportedFormat)
+	}
+
+	idatT := &pngIDATReader{r: br}
+
+	for {
+		headerT := make([]byte, 8)
+
+		_, errT = io.ReadFull(br, headerT)
+		if errT != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		if string(headerT[4:8]) == "IDAT" {
+			idatT.start(headerT)
+			break
+		}
+
+		c, errT = readPNGChunkBody(br, headerT)
+		if errT != nil {
+			return nil, errT
+		}
+
+		switch c.Type {
+		case "IEND":
+			return nil, errPNGFormat
+		case "PLTE":
+			if len(c.Data)%3 != 0 || len(c.Data) > 256*3 {
+				return nil, errPNGFormat
+			}
+
+			s.palette = make([][4]uint16, len(c.Data)/3)
+			for i := range s.palette {
+				s.palette[i] = [4]uint16{uint16(c.Data[3*i]) * 0x101, uint16(c.Data[3*i+1]) * 0x101, uint16(c.Data[3*i+2]) * 0x101, 0xffff}
+			}
+		case "tRNS":
+			switch s.colorType {
+			case 0:
+				if len(c.Data) >= 2 {
+					v := binary.BigEndian.Uint16(c.Data)
+					s.transparent, s.hasTransparent = [3]uint16{v, v, v}, true
+				}
+			case 2:
+				if len(c.Data) >= 6 {
+					s.transparent = [3]uint16{binary.BigEndian.Uint16(c.Data), binary.BigEndian.Uint16(c.Data[2:]), binary.BigEndian.Uint16(c.Data[4:])}
+					s.hasTransparent = true
+				}
+			case 3:
+				for i, v := range c.Data {
+					if i < len(s.palette) {
+						s.palette[i][3] = uint16(v) * 0x101
+					}
+				}
+			}
+		}
+	}
+
+	if s.colorType == 3 && s.palette == nil {
+		return nil, errPNGFormat
+	}
+
+	s.z, errT = zlib.NewReader(idatT)
+	if errT != nil {
+		return nil, errT
+	}
+
+	bitsT := s.depth * s.channels()
+	s.bpp = maxInt(1, bitsT/8)
+	s.cur = make([]uint8, 1+(s.width*bitsT+7)/8)
+	s.prev = make([]uint8, len(s.cur))
+	s.row = make([]uint16, 4*s.width)
+
+	return s, nil
+}
+
+// NewPNGRowSource returns a RowSource decoding the PNG stream row by row, interlaced PNG is not supported
+func (p *ImageTK) NewPNGRowSource(r io.Reader) (RowSource, error) {
+	return newPNGRowSource(bufio.NewReader(r))
+}
+
+func (s *pngRowSource) Size() (int, int) {
+	return s.width, s.height
+}
+
+func (s *pngRowSource) wide() bool {
+	return s.depth == 16
+}
+
+// unfilter reverses the filter of the current row
+func (s *pngRowSource) unfilter() error {
+	cur, prev, bpp := s.cur[1:], s.prev[1:], s.bpp
+
+	switch s.cur[0] {
+	case 0:
+	case 1:
+		for i := bpp; i < len(cur); i++ {
+			cur[i] += cur[i-bpp]
+		}
+	case 2:
+		for i := range cur {
+			cur[i] += prev[i]
+		}
+	case 3:
+		for i := range cur {
+			var a uint8
+			if i >= bpp {
+				a = cur[i-bpp]
+			}
+			cur[i] += uint8((int(a) + int(prev[i])) / 2)
+		}
+	case 4:
+		for i := range cur {
+			var a, c uint8
+			if i >= bpp {
+				a, c = cur[i-bpp], prev[i-bpp]
+			}
+			cur[i] += pngPaeth(a, prev[i], c)
+		}
+	default:
+		return errPNGFormat
+	}
+
+	return nil
+}
+
+// sample returns the sample of the current row at the index, in the depth of the image
+func (s *pngRowSource) sample(i int) uint16 {
+	pixT := s.cur[1:]
+
+	switch s.depth {
+	case 16:
+		return uint16(pixT[2*i])<<8 | uint16(pixT[2*i+1])
+	case 8:
+		return uint16(pixT[i])
+	}
+
+	perByteT := 8 / s.depth
+	shiftT := uint(8 - s.depth*(i%perByteT+1))
+
+	return uint16(pixT[i/perByteT]>>shiftT) & (1<<uint(s.depth) - 1)
+}
+
+// readRow decodes the next row to 16-bit non-premultiplied RGBA samples
+func (s *pngRowSource) readRow() error {
+	s.cur, s.prev = s.prev, s.cur
+
+	_, errT := io.ReadFull(s.z, s.cur)
+	if errT != nil {
+		if errT == io.EOF {
+			errT = io.ErrUnexpectedEOF
+		}
+		return errT
+	}
+
+	errT = s.unfilter()
+	if errT != nil {
+		return errT
+	}
+
+	maxT := uint32(1)<<uint(s.depth) - 1
+	scale := func(v uint16) uint16 {
+		return uint16(uint32(v) * 0xffff / maxT)
+	}
+
+	chT := s.channels()
+
+	for x := 0; x < s.width; x++ {
+		o := s.row[4*x : 4*x+4]
+
+		switch s.colorType {
+		case 0:
+			v := s.sample(x)
+			g := scale(v)
+			o[0], o[1], o[2], o[3] = g, g, g, 0xffff
+			if s.hasTransparent && v == s.transparent[0] {
+				o[3] = 0
+			}
+		case 2:
+			r, g, b := s.sample(3*x), s.sample(3*x+1), s.sample(3*x+2)
+			o[0], o[1], o[2], o[3] = scale(r), scale(g), scale(b), 0xffff
+			if s.hasTransparent && r == s.transparent[0] && g == s.transparent[1] && b == s.transparent[2] {
+				o[3] = 0
+			}
+		case 3:
+			i := int(s.sample(x))
+			if i >= len(s.palette) {
+				return errPNGFormat
+			}
+			copy(o, s.palette[i][:])
+		default:
+			for c := 0; c < chT; c++ {
+				o[c] = scale(s.sample(chT*x + c))
+			}
+			if chT == 2 {
+				o[3] = o[1]
+				o[1], o[2] = o[0], o[0]
+			}
+		}
+	}
+
+	s.y++
+
+	return nil
+}
+
+func (s *pngRowSource) ReadRows(stripA *image.RGBA64) error {
+	for y := 0; y < stripA.Rect.Dy(); y++ {
+		if s.y >= s.height {
+			return io.ErrUnexpectedEOF
+		}
+
+		errT := s.readRow()
+		if errT != nil {
+			return errT
+		}
+
+		putPremultipliedRow(stripA.Pix[y*stripA.Stride:], s.row)
+	}
+
+	return nil
+}
+
+// pngChunkWriter writes the data in IDAT chunks of pngIDATChunkSize bytes
+type pngChunkWriter struct {
+	w   io.Writer
+	buf []uint8
+}
+
+func (c *pngChunkWriter) Write(p []byte) (int, error) {
+	n := len(p)
+
+	for len(p) > 0 {
+		m := minInt(len(p), pngIDATChunkSize-len(c.buf))
+		c.buf = append(c.buf, p[:m]...)
+		p = p[m:]
+
+		if len(c.buf) == pngIDATChunkSize {
+			errT := c.flush()
+			if errT != nil {
+				return 0, errT
+			}
+		}
+	}
+
+	return n, nil
+}
+
+func (c *pngChunkWriter) flush() error {
+	if len(c.buf) == 0 {
+		return nil
+	}
+
+	errT := writePNGChunk(c.w, "IDAT", c.buf)
+	c.buf = c.buf[:0]
+
+	return errT
+}
+
+// pngRowSink encodes the rows as a non-interlaced 8-bit or 16-bit RGBA PNG
+type pngRowSink struct {
+	w     io.Writer
+	wide  bool
+	level png.CompressionLevel
+
+	chunks *pngChunkWriter
+	z      *zlib.Writer
+
+	width  int
+	row    []uint16
+	cur    []uint8
+	prev   []uint8
+	filter [][]uint8
+}
+
+// NewPNGRowSink returns a RowSink encoding the rows as RGBA PNG, wideA for 16-bit samples.
+// The PNGCompression of optsA is used, optsA could be nil.
+func (p *ImageTK) NewPNGRowSink(w io.Writer, wideA bool, optsA *EncodeOptions) RowSink {
+	s := &pngRowSink{w: w, wide: wideA}
+
+	if optsA != nil {
+		s.level = optsA.PNGCompression
+	}
+
+	return s
+}
+
+func (s *pngRowSink) Start(widthA, heightA int) error {
+	if widthA < 1 || heightA < 1 {
+		return fmt.Errorf("png: invalid image size %vx%v", widthA, heightA)
+	}
+
+	_, errT := io.WriteString(s.w, pngSignature)
+	if errT != nil {
+		return errT
+	}
+
+	ihdrT := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdrT[0:4], uint32(widthA))
+	binary.BigEndian.PutUint32(ihdrT[4:8], uint32(heightA))
+	ihdrT[8] = 8 // bit depth
+	if s.wide {
+		ihdrT[8] = 16
+	}
+	ihdrT[9] = 6 // color type RGBA
+
+	errT = writePNGChunk(s.w, "IHDR", ihdrT)
+	if errT != nil {
+		return errT
+	}
+
+	s.chunks = &pngChunkWriter{w: s.w, buf: make([]uint8, 0, pngIDATChunkSize)}
+
+	s.z, errT = zlib.NewWriterLevel(s.chunks, zlibLevel(s.level))
+	if errT != nil {
+		return errT
+	}
+
+	rowLenT := 4 * widthA
+	if s.wide {
+		rowLenT *= 2
+	}
+
+	s.width = widthA
+	s.row = make([]uint16, 4*widthA)
+	s.cur = make([]uint8, rowLenT)
+	s.prev = make([]uint8, rowLenT)
+	s.filter = make([][]uint8, 5)
+	for i := range s.filter {
+		s.filter[i] = make([]uint8, rowLenT+1)
+	}
+
+	return nil
+}
+
+func (s *pngRowSink) WriteRows(stripA *image.RGBA64) error {
+	bppT := 4
+	if s.wide {
+		bppT = 8
+	}
+
+	for y := 0; y < stripA.Rect.Dy(); y++ {
+		getUnpremultipliedRow(s.row, stripA.Pix[y*stripA.Stride:])
+
+		for i, v := range s.row {
+			if s.wide {
+				s.cur[2*i+0] = uint8(v >> 8)
+				s.cur[2*i+1] = uint8(v)
+			} else {
+				s.cur[i] = uint8((uint32(v)*0xff + 0x7fff) / 0xffff)
+			}
+		}
+
+		_, errT := s.z.Write(filterPNGRow(s.cur, s.prev, bppT, s.filter))
+		if errT != nil {
+			return errT
+		}
+
+		s.cur, s.prev = s.prev, s.cur
+	}
+
+	return nil
+}
+
+func (s *pngRowSink) Finish() error {
+	errT := s.z.Close()
+	if errT != nil {
+		return errT
+	}
+
+	errT = s.chunks.flush()
+	if errT != nil {
+		return errT
+	}
+
+	return writePNGChunk(s.w, "IEND", nil)
+}
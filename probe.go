@@ -0,0 +1,689 @@
+package imagetk
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+)
+
+// ImageInfo holds the basic information of an image read from the headers only
+type ImageInfo struct {
+	// Format is the format name such as "png", "jpeg", "gif"
+	Format string
+	// Width and Height are the stored dimensions(or the canvas of animations), before applying the orientation
+	Width  int
+	Height int
+	// ColorModel is the name of the color model the image decodes to, such as "rgba", "nrgba", "gray", "ycbcr", "paletted"
+	ColorModel string
+	// BitDepth is the number of bits per channel
+	BitDepth int
+	// FrameCount is the number of frames of animations or pages of TIFF, 1 for still images
+	FrameCount int
+	// Orientation is the EXIF orientation(1-8), 0 if not present
+	Orientation int
+	HasAlpha    bool
+}
+
+var errProbeFormat = errors.New("probe: invalid format")
+
+// colorModelName returns the name of the standard color models
+func colorModelName(m color.Model) string {
+	switch m {
+	case color.RGBAModel:
+		return "rgba"
+	case color.RGBA64Model:
+		return "rgba64"
+	case color.NRGBAModel:
+		return "nrgba"
+	case color.NRGBA64Model:
+		return "nrgba64"
+	case color.AlphaModel:
+		return "alpha"
+	case color.Alpha16Model:
+		return "alpha16"
+	case color.GrayModel:
+		return "gray"
+	case color.Gray16Model:
+		return "gray16"
+	case color.YCbCrModel:
+		return "ycbcr"
+	case color.NYCbCrAModel:
+		return "nycbcra"
+	case color.CMYKModel:
+		return "cmyk"
+	}
+
+	if _, ok := m.(color.Palette); ok {
+		return "paletted"
+	}
+
+	return "unknown"
+}
+
+// wideModelName returns the name of the 16-bit variant of the color model if wideA is true
+func wideModelName(nameA string, wideA bool) string {
+	if !wideA {
+		return nameA
+	}
+
+	if nameA == "gray" {
+		return "gray16"
+	}
+
+	return nameA + "64"
+}
+
+// setColorModel fills the color model name, the bit depth and the alpha flag from a color model
+func (info *ImageInfo) setColorModel(m color.Model) {
+	info.ColorModel = colorModelName(m)
+	info.BitDepth = 8
+
+	switch m {
+	case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model, color.Alpha16Model:
+		info.BitDepth = 16
+	}
+
+	switch m {
+	case color.NRGBAModel, color.NRGBA64Model, color.AlphaModel, color.Alpha16Model, color.NYCbCrAModel:
+		info.HasAlpha = true
+	}
+
+	if paletteT, ok := m.(color.Palette); ok {
+		for _, v := range paletteT {
+			if _, _, _, a := v.RGBA(); a < 0xffff {
+				info.HasAlpha = true
+				break
+			}
+		}
+	}
+}
+
+// orientationFromEXIF returns the orientation of the raw EXIF payload, 0 if not valid
+func orientationFromEXIF(rawA []byte) int {
+	exifT, errT := parseEXIF(rawA)
+	if errT != nil {
+		return 0
+	}
+
+	return exifT.Orientation
+}
+
+// probePNG reads the chunks before the image data
+func probePNG(r *bufio.Reader, info *ImageInfo) error {
+	headerT := make([]byte, len(pngSignature)+8+13)
+
+	_, errT := io.ReadFull(r, headerT)
+	if errT != nil {
+		return errT
+	}
+
+	if string(headerT[:len(pngSignature)]) != pngSignature || string(headerT[12:16]) != "IHDR" {
+		return errPNGFormat
+	}
+
+	ihdrT := headerT[16:]
+
+	info.Width = int(binary.BigEndian.Uint32(ihdrT[0:4]))
+	info.Height = int(binary.BigEndian.Uint32(ihdrT[4:8]))
+	info.BitDepth = int(ihdrT[8])
+
+	colorTypeT := ihdrT[9]
+	transparentT := false
+
+	// the CRC of IHDR
+	_, errT = r.Discard(4)
+	if errT != nil {
+		return errT
+	}
+
+	chunkT := make([]byte, 8)
+
+loop:
+	for {
+		_, errT = io.ReadFull(r, chunkT)
+		if errT != nil {
+			return errT
+		}
+
+		lengthT := int(binary.BigEndian.Uint32(chunkT[:4]))
+		if lengthT < 0 || lengthT > 1<<30 {
+			return errPNGFormat
+		}
+
+		switch string(chunkT[4:8]) {
+		case "acTL":
+			dataT, errT := r.Peek(4)
+			if errT == nil {
+				info.FrameCount = int(binary.BigEndian.Uint32(dataT))
+			}
+		case "tRNS":
+			transparentT = true
+		case "eXIf":
+			if lengthT >= maxEXIFLength {
+				break
+			}
+
+			dataT := make([]byte, lengthT)
+
+			_, errT = io.ReadFull(r, dataT)
+			if errT != nil {
+				return errT
+			}
+
+			info.Orientation = orientationFromEXIF(dataT)
+
+			_, errT = r.Discard(4)
+			if errT != nil {
+				return errT
+			}
+
+			continue
+		case "IDAT", "IEND":
+			break loop
+		}
+
+		_, errT = r.Discard(lengthT + 4)
+		if errT != nil {
+			return errT
+		}
+	}
+
+	wideT := info.BitDepth == 16
+
+	// the same as the color models produced by image/png
+	switch colorTypeT {
+	case 0:
+		info.ColorModel = wideModelName("gray", wideT)
+		if transparentT {
+			info.ColorModel = wideModelName("nrgba", wideT)
+		}
+	case 2:
+		info.ColorModel = wideModelName("rgba", wideT)
+		if transparentT {
+			info.ColorModel = wideModelName("nrgba", wideT)
+		}
+	case 3:
+		info.ColorModel = "paletted"
+	case 4, 6:
+		info.ColorModel = wideModelName("nrgba", wideT)
+		transparentT = true
+	default:
+		return errPNGFormat
+	}
+
+	info.HasAlpha = transparentT
+
+	return nil
+}
+
+// probeJPEG reads the segments before the scan data
+func probeJPEG(r *bufio.Reader, info *ImageInfo) error {
+	bufT := make([]byte, 4)
+
+	_, errT := io.ReadFull(r, bufT[:2])
+	if errT != nil {
+		return errT
+	}
+
+	if bufT[0] != 0xff || bufT[1] != 0xd8 {
+		return errProbeFormat
+	}
+
+	foundT := false
+
+	for {
+		_, errT = io.ReadFull(r, bufT[:2])
+		if errT != nil {
+			return errT
+		}
+
+		if bufT[0] != 0xff {
+			return errProbeFormat
+		}
+
+		markerT := bufT[1]
+
+		if markerT == 0xff {
+			r.UnreadByte()
+			continue
+		}
+
+		if markerT == 0xda || markerT == 0xd9 {
+			break
+		}
+
+		if markerT >= 0xd0 && markerT <= 0xd7 || markerT == 0x01 {
+			continue
+		}
+
+		_, errT = io.ReadFull(r, bufT[:2])
+		if errT != nil {
+			return errT
+		}
+
+		lengthT := int(binary.BigEndian.Uint16(bufT[:2])) - 2
+		if lengthT < 0 {
+			return errProbeFormat
+		}
+
+		switch {
+		case markerT >= 0xc0 && markerT <= 0xcf && markerT != 0xc4 && markerT != 0xc8 && markerT != 0xcc:
+			// start of frame
+			dataT := make([]byte, lengthT)
+
+			_, errT = io.ReadFull(r, dataT)
+			if errT != nil {
+				return errT
+			}
+
+			if len(dataT) < 6 {
+				return errProbeFormat
+			}
+
+			info.BitDepth = int(dataT[0])
+			info.Height = int(binary.BigEndian.Uint16(dataT[1:3]))
+			info.Width = int(binary.BigEndian.Uint16(dataT[3:5]))
+
+			switch dataT[5] {
+			case 1:
+				info.ColorModel = "gray"
+			case 3:
+				info.ColorModel = "ycbcr"
+			case 4:
+				info.ColorModel = "cmyk"
+			default:
+				return errProbeFormat
+			}
+
+			foundT = true
+		case markerT == 0xe1 && lengthT >= len(exifHeader):
+			dataT := make([]byte, lengthT)
+
+			_, errT = io.ReadFull(r, dataT)
+			if errT != nil {
+				return errT
+			}
+
+			if string(dataT[:len(exifHeader)]) == exifHeader {
+				info.Orientation = orientationFromEXIF(dataT[len(exifHeader):])
+			}
+		default:
+			_, errT = r.Discard(lengthT)
+			if errT != nil {
+				return errT
+			}
+		}
+	}
+
+	if !foundT {
+		return errProbeFormat
+	}
+
+	return nil
+}
+
+// probeGIF reads the logical screen descriptor and walks the blocks to count the frames
+func probeGIF(r *bufio.Reader, info *ImageInfo) error {
+	headerT, errT := r.Peek(10)
+	if errT != nil {
+		return errT
+	}
+
+	info.Width = int(binary.LittleEndian.Uint16(headerT[6:8]))
+	info.Height = int(binary.LittleEndian.Uint16(headerT[8:10]))
+	info.ColorModel = "paletted"
+	info.BitDepth = 8
+
+	info.FrameCount, info.HasAlpha, errT = scanGIFFrames(r, 0)
+
+	return errT
+}
+
+// probeWebP reads the RIFF chunks, walks through the whole file only for animations or images with EXIF
+func probeWebP(r *bufio.Reader, info *ImageInfo) error {
+	headerT := make([]byte, 12)
+
+	_, errT := io.ReadFull(r, headerT)
+	if errT != nil {
+		return errT
+	}
+
+	if string(headerT[:4]) != "RIFF" || string(headerT[8:12]) != "WEBP" {
+		return errProbeFormat
+	}
+
+	info.BitDepth = 8
+
+	extendedT := false
+	animatedT := false
+	exifT := false
+	alphaChunkT := false
+	framesT := 0
+
+	for {
+		_, errT = io.ReadFull(r, headerT[:8])
+		if errT != nil {
+			if extendedT && errT == io.EOF {
+				break
+			}
+			return errT
+		}
+
+		lengthT := int(binary.LittleEndian.Uint32(headerT[4:8]))
+		if lengthT < 0 || lengthT > 1<<30 {
+			return errProbeFormat
+		}
+
+		paddedT := lengthT + lengthT&1
+		dataT, _ := r.Peek(minInt(lengthT, 10))
+
+		switch string(headerT[:4]) {
+		case "VP8 ":
+			if len(dataT) < 10 || dataT[3] != 0x9d || dataT[4] != 0x01 || dataT[5] != 0x2a {
+				return errProbeFormat
+			}
+
+			if !extendedT {
+				info.Width = int(binary.LittleEndian.Uint16(dataT[6:8]) & 0x3fff)
+				info.Height = int(binary.LittleEndian.Uint16(dataT[8:10]) & 0x3fff)
+			}
+
+			if info.ColorModel == "" {
+				info.ColorModel = "ycbcr"
+				if alphaChunkT {
+					info.ColorModel = "nycbcra"
+				}
+			}
+		case "VP8L":
+			if len(dataT) < 5 || dataT[0] != 0x2f {
+				return errProbeFormat
+			}
+
+			v := binary.LittleEndian.Uint32(dataT[1:5])
+
+			if !extendedT {
+				info.Width = int(v&0x3fff) + 1
+				info.Height = int((v>>14)&0x3fff) + 1
+				info.HasAlpha = (v>>28)&1 != 0
+			}
+
+			if info.ColorModel == "" {
+				info.ColorModel = "nrgba"
+			}
+		case "VP8X":
+			if len(dataT) < 10 {
+				return errProbeFormat
+			}
+
+			extendedT = true
+			animatedT = dataT[0]&0x02 != 0
+			exifT = dataT[0]&0x08 != 0
+			info.HasAlpha = dataT[0]&0x10 != 0
+			info.Width = int(uint32(dataT[4])|uint32(dataT[5])<<8|uint32(dataT[6])<<16) + 1
+			info.Height = int(uint32(dataT[7])|uint32(dataT[8])<<8|uint32(dataT[9])<<16) + 1
+		case "ALPH":
+			alphaChunkT = true
+		case "ANMF":
+			framesT++
+		case "EXIF":
+			exifT = false
+			if lengthT >= maxEXIFLength {
+				break
+			}
+
+			rawT := make([]byte, lengthT)
+
+			_, errT = io.ReadFull(r, rawT)
+			if errT != nil {
+				return errT
+			}
+
+			if len(rawT) >= len(exifHeader) && string(rawT[:len(exifHeader)]) == exifHeader {
+				rawT = rawT[len(exifHeader):]
+			}
+
+			info.Orientation = orientationFromEXIF(rawT)
+			paddedT -= lengthT
+		}
+
+		_, errT = r.Discard(paddedT)
+		if errT != nil {
+			if extendedT && errT == io.EOF {
+				break
+			}
+			return errT
+		}
+
+		if !extendedT || (!animatedT && !exifT && info.ColorModel != "") {
+			break
+		}
+	}
+
+	if animatedT {
+		info.FrameCount = framesT
+		if info.ColorModel == "" {
+			info.ColorModel = "nrgba"
+		}
+	}
+
+	if info.ColorModel == "" {
+		return errProbeFormat
+	}
+
+	return nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// probeTIFF reads the first IFD for the image information and counts the IFDs for the pages
+func probeTIFF(r io.ReaderAt, info *ImageInfo) error {
+	orderT, offsetsT, errT := tiffPageOffsets(r)
+	if errT != nil {
+		return errT
+	}
+
+	if len(offsetsT) < 1 {
+		return errProbeFormat
+	}
+
+	info.FrameCount = len(offsetsT)
+
+	countBufT := make([]byte, 2)
+
+	_, errT = r.ReadAt(countBufT, int64(offsetsT[0]))
+	if errT != nil {
+		return errT
+	}
+
+	entriesT := make([]byte, 12*int(orderT.Uint16(countBufT)))
+
+	_, errT = r.ReadAt(entriesT, int64(offsetsT[0])+2)
+	if errT != nil {
+		return errT
+	}
+
+	// the first value of the tags, values not stored inline are read from the offset
+	valueT := func(e []byte) int {
+		typeT := orderT.Uint16(e[2:4])
+		countT := orderT.Uint32(e[4:8])
+
+		sizeT := map[uint16]uint32{1: 1, 3: 2, 4: 4}[typeT]
+		if sizeT == 0 || countT == 0 {
+			return 0
+		}
+
+		dataT := e[8:12]
+		if sizeT*countT > 4 {
+			dataT = make([]byte, 4)
+			_, errT := r.ReadAt(dataT[:sizeT], int64(orderT.Uint32(e[8:12])))
+			if errT != nil {
+				return 0
+			}
+		}
+
+		switch sizeT {
+		case 1:
+			return int(dataT[0])
+		case 2:
+			return int(orderT.Uint16(dataT))
+		}
+
+		return int(orderT.Uint32(dataT))
+	}
+
+	photometricT := -1
+	extraT := -1
+	info.BitDepth = 1
+
+	for i := 0; i+12 <= len(entriesT); i += 12 {
+		e := entriesT[i : i+12]
+
+		switch orderT.Uint16(e[:2]) {
+		case 0x0100:
+			info.Width = valueT(e)
+		case 0x0101:
+			info.Height = valueT(e)
+		case 0x0102:
+			info.BitDepth = valueT(e)
+		case 0x0106:
+			photometricT = valueT(e)
+		case 0x0112:
+			info.Orientation = valueT(e)
+		case 0x0152:
+			extraT = valueT(e)
+		}
+	}
+
+	wideT := info.BitDepth == 16
+	info.HasAlpha = extraT == 1 || extraT == 2
+
+	// the same as the color models produced by golang.org/x/image/tiff
+	switch photometricT {
+	case 0, 1:
+		info.ColorModel = wideModelName("gray", wideT)
+	case 2:
+		switch {
+		case extraT == 2:
+			info.ColorModel = wideModelName("nrgba", wideT)
+		default:
+			info.ColorModel = wideModelName("rgba", wideT)
+		}
+	case 3:
+		info.ColorModel = "paletted"
+	case 5:
+		info.ColorModel = "cmyk"
+	default:
+		return fmt.Errorf("probe: unsupported TIFF photometric interpretation %v", photometricT)
+	}
+
+	if info.Orientation < 0 || info.Orientation > 8 {
+		info.Orientation = 0
+	}
+
+	return nil
+}
+
+// readerAtFrom returns a ReaderAt starting at the current position of the reader, nil if not supported
+func readerAtFrom(r io.Reader) io.ReaderAt {
+	ra, ok := r.(io.ReaderAt)
+	if !ok {
+		return nil
+	}
+
+	seekerT, ok := r.(io.Seeker)
+	if !ok {
+		return nil
+	}
+
+	offsetT, errT := seekerT.Seek(0, io.SeekCurrent)
+	if errT != nil {
+		return nil
+	}
+
+	return io.NewSectionReader(ra, offsetT, 1<<62)
+}
+
+func probeReader(r io.Reader) (*ImageInfo, error) {
+	raT := readerAtFrom(r)
+	br := bufio.NewReader(r)
+
+	formatT, errT := sniffFormat(br)
+	if errT != nil {
+		return nil, errT
+	}
+
+	info := &ImageInfo{Format: formatT, FrameCount: 1}
+
+	switch formatT {
+	case "png":
+		errT = probePNG(br, info)
+	case "jpeg":
+		errT = probeJPEG(br, info)
+	case "gif":
+		errT = probeGIF(br, info)
+	case "webp":
+		errT = probeWebP(br, info)
+	case "tiff":
+		if raT == nil {
+			raT, errT = asReaderAt(br)
+			if errT != nil {
+				return nil, errT
+			}
+		}
+
+		errT = probeTIFF(raT, info)
+	case "qoi":
+		var h *QOIHeader
+
+		h, errT = readQOIHeader(br)
+		if errT == nil {
+			info.Width, info.Height = int(h.Width), int(h.Height)
+			info.ColorModel, info.BitDepth, info.HasAlpha = "nrgba", 8, h.Channels == 4
+		}
+	default:
+		f := lookupFormat(formatT)
+		if f == nil || f.DecodeConfig == nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, formatT)
+		}
+
+		cfgT, errT := f.DecodeConfig(br)
+		if errT != nil {
+			return nil, errT
+		}
+
+		info.Width, info.Height = cfgT.Width, cfgT.Height
+		info.setColorModel(cfgT.ColorModel)
+	}
+
+	if errT != nil {
+		return nil, errT
+	}
+
+	return info, nil
+}
+
+// ProbeReader reads the image information from the headers without decoding the pixels
+func (p *ImageTK) ProbeReader(readerA io.Reader) (*ImageInfo, error) {
+	return probeReader(readerA)
+}
+
+// Probe reads the image information of the file from the headers without decoding the pixels
+func (p *ImageTK) Probe(fileNameA string) (*ImageInfo, error) {
+	fileT, errT := os.Open(fileNameA)
+	if errT != nil {
+		return nil, errT
+	}
+	defer fileT.Close()
+
+	return probeReader(fileT)
+}
@@ -0,0 +1,101 @@
+package imagetk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"runtime"
+	"testing"
+)
+
+// probePNGWithEXIF returns a PNG header followed by an eXIf chunk, declaring the length if the data is nil
+func probePNGWithEXIF(exifA []byte, lengthA uint32) []byte {
+	var b bytes.Buffer
+
+	b.WriteString(pngSignature)
+	writePNGChunk(&b, "IHDR", []byte{0, 0, 0, 4, 0, 0, 0, 3, 8, 6, 0, 0, 0})
+
+	if exifA != nil {
+		writePNGChunk(&b, "eXIf", exifA)
+		writePNGChunk(&b, "IDAT", nil)
+	} else {
+		binary.Write(&b, binary.BigEndian, lengthA)
+		b.WriteString("eXIf")
+	}
+
+	return b.Bytes()
+}
+
+// probeWebPWithEXIF returns an extended WebP header followed by an EXIF chunk, declaring the length if the data is nil
+func probeWebPWithEXIF(exifA []byte, lengthA uint32) []byte {
+	var b bytes.Buffer
+
+	b.WriteString("RIFF\x00\x00\x00\x00WEBP")
+	b.WriteString("VP8X\x0a\x00\x00\x00")
+	b.Write([]byte{0x08, 0, 0, 0, 3, 0, 0, 2, 0, 0})
+
+	if exifA != nil {
+		b.WriteString("EXIF")
+		binary.Write(&b, binary.LittleEndian, uint32(len(exifA)))
+		b.Write(exifA)
+		if len(exifA)&1 != 0 {
+			b.WriteByte(0)
+		}
+		b.WriteString("VP8L\x05\x00\x00\x00\x2f\x03\xc0\x00\x00\x00")
+	} else {
+		b.WriteString("EXIF")
+		binary.Write(&b, binary.LittleEndian, lengthA)
+	}
+
+	return b.Bytes()
+}
+
+func TestProbeEXIF(t *testing.T) {
+	p := NewImageTK()
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"png", probePNGWithEXIF(exifWithOrientation(6), 0)},
+		{"webp", probeWebPWithEXIF(exifWithOrientation(6), 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := p.ProbeReader(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if info.Orientation != 6 {
+				t.Errorf("orientation %v, want 6", info.Orientation)
+			}
+		})
+	}
+}
+
+func TestProbeSkipsLargeEXIF(t *testing.T) {
+	p := NewImageTK()
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"png", probePNGWithEXIF(nil, 1<<29)},
+		{"webp", probeWebPWithEXIF(nil, 1<<29)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before, after runtime.MemStats
+
+			runtime.ReadMemStats(&before)
+			p.ProbeReader(bytes.NewReader(tt.data))
+			runtime.ReadMemStats(&after)
+
+			if allocT := after.TotalAlloc - before.TotalAlloc; allocT >= maxEXIFLength {
+				t.Errorf("allocated %v bytes for a truncated EXIF chunk", allocT)
+			}
+		})
+	}
+}
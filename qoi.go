@@ -0,0 +1,288 @@
+package imagetk
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+)
+
+// QOI(Quite OK Image) codec, see https://qoiformat.org/qoi-specification.pdf
+
+const (
+	qoiMagic      = "qoif"
+	qoiHeaderSize = 14
+
+	qoiOpIndex = 0x00
+	qoiOpDiff  = 0x40
+	qoiOpLuma  = 0x80
+	qoiOpRun   = 0xc0
+	qoiOpRGB   = 0xfe
+	qoiOpRGBA  = 0xff
+	qoiMask2   = 0xc0
+
+	// QOISRGB means sRGB color channels with linear alpha
+	QOISRGB = 0
+	// QOILinear means all channels are linear
+	QOILinear = 1
+)
+
+var qoiPadding = []byte{0, 0, 0, 0, 0, 0, 0, 1}
+
+var errQOIFormat = errors.New("qoi: invalid format")
+
+// QOIHeader is the header of a QOI image
+type QOIHeader struct {
+	Width  uint32
+	Height uint32
+	// Channels is 3 for RGB and 4 for RGBA
+	Channels uint8
+	// Colorspace is QOISRGB or QOILinear
+	Colorspace uint8
+}
+
+func qoiHash(c color.NRGBA) int {
+	return (int(c.R)*3 + int(c.G)*5 + int(c.B)*7 + int(c.A)*11) % 64
+}
+
+func readQOIHeader(r io.Reader) (*QOIHeader, error) {
+	bufT := make([]byte, qoiHeaderSize)
+
+	_, errT := io.ReadFull(r, bufT)
+	if errT != nil {
+		return nil, errT
+	}
+
+	if string(bufT[:4]) != qoiMagic {
+		return nil, errQOIFormat
+	}
+
+	h := &QOIHeader{
+		Width:      binary.BigEndian.Uint32(bufT[4:8]),
+		Height:     binary.BigEndian.Uint32(bufT[8:12]),
+		Channels:   bufT[12],
+		Colorspace: bufT[13],
+	}
+
+	if h.Width < 1 || h.Height < 1 || (h.Channels != 3 && h.Channels != 4) || h.Colorspace > 1 {
+		return nil, errQOIFormat
+	}
+
+	if uint64(h.Width)*uint64(h.Height) > 1<<31 {
+		return nil, fmt.Errorf("qoi: image too large (%vx%v)", h.Width, h.Height)
+	}
+
+	return h, nil
+}
+
+func decodeQOIConfig(r io.Reader) (image.Config, error) {
+	h, errT := readQOIHeader(r)
+	if errT != nil {
+		return image.Config{}, errT
+	}
+
+	return image.Config{ColorModel: color.NRGBAModel, Width: int(h.Width), Height: int(h.Height)}, nil
+}
+
+func decodeQOI(r io.Reader) (image.Image, error) {
+	imgT, _, errT := decodeQOIWithHeader(r)
+
+	return imgT, errT
+}
+
+func decodeQOIWithHeader(r io.Reader) (*image.NRGBA, *QOIHeader, error) {
+	br := bufio.NewReader(r)
+
+	h, errT := readQOIHeader(br)
+	if errT != nil {
+		return nil, nil, errT
+	}
+
+	imgT := image.NewNRGBA(image.Rect(0, 0, int(h.Width), int(h.Height)))
+
+	var indexT [64]color.NRGBA
+	pxT := color.NRGBA{0, 0, 0, 255}
+	runT := 0
+
+	for i := 0; i < len(imgT.Pix); i += 4 {
+		if runT > 0 {
+			runT--
+		} else {
+			b1, errT := br.ReadByte()
+			if errT != nil {
+				return nil, nil, io.ErrUnexpectedEOF
+			}
+
+			switch {
+			case b1 == qoiOpRGB:
+				_, errT = io.ReadFull(br, imgT.Pix[i:i+3])
+				pxT.R, pxT.G, pxT.B = imgT.Pix[i], imgT.Pix[i+1], imgT.Pix[i+2]
+			case b1 == qoiOpRGBA:
+				_, errT = io.ReadFull(br, imgT.Pix[i:i+4])
+				pxT = color.NRGBA{imgT.Pix[i], imgT.Pix[i+1], imgT.Pix[i+2], imgT.Pix[i+3]}
+			case b1&qoiMask2 == qoiOpIndex:
+				pxT = indexT[b1]
+			case b1&qoiMask2 == qoiOpDiff:
+				pxT.R += (b1>>4)&0x03 - 2
+				pxT.G += (b1>>2)&0x03 - 2
+				pxT.B += b1&0x03 - 2
+			case b1&qoiMask2 == qoiOpLuma:
+				var b2 byte
+				b2, errT = br.ReadByte()
+				vg := b1&0x3f - 32
+				pxT.R += vg - 8 + (b2>>4)&0x0f
+				pxT.G += vg
+				pxT.B += vg - 8 + b2&0x0f
+			default:
+				runT = int(b1 & 0x3f)
+			}
+
+			if errT != nil {
+				return nil, nil, io.ErrUnexpectedEOF
+			}
+
+			indexT[qoiHash(pxT)] = pxT
+		}
+
+		imgT.Pix[i+0] = pxT.R
+		imgT.Pix[i+1] = pxT.G
+		imgT.Pix[i+2] = pxT.B
+		imgT.Pix[i+3] = pxT.A
+	}
+
+	return imgT, h, nil
+}
+
+func encodeQOI(w io.Writer, imageA image.Image, optsA *EncodeOptions) error {
+	boundsT := imageA.Bounds()
+
+	h := &QOIHeader{Width: uint32(boundsT.Dx()), Height: uint32(boundsT.Dy()), Channels: 4, Colorspace: QOISRGB}
+
+	if optsA != nil {
+		if optsA.QOIChannels != 0 {
+			h.Channels = uint8(optsA.QOIChannels)
+		} else if isOpaque(imageA) {
+			h.Channels = 3
+		}
+
+		if optsA.QOILinear {
+			h.Colorspace = QOILinear
+		}
+	} else if isOpaque(imageA) {
+		h.Channels = 3
+	}
+
+	if h.Channels != 3 && h.Channels != 4 {
+		return fmt.Errorf("qoi: invalid number of channels %v", h.Channels)
+	}
+
+	if h.Width < 1 || h.Height < 1 {
+		return errQOIFormat
+	}
+
+	imgT, ok := imageA.(*image.NRGBA)
+	if !ok {
+		imgT = image.NewNRGBA(image.Rect(0, 0, boundsT.Dx(), boundsT.Dy()))
+		draw.Draw(imgT, imgT.Bounds(), imageA, boundsT.Min, draw.Src)
+	}
+
+	bw := bufio.NewWriter(w)
+
+	headerT := make([]byte, qoiHeaderSize)
+	copy(headerT, qoiMagic)
+	binary.BigEndian.PutUint32(headerT[4:8], h.Width)
+	binary.BigEndian.PutUint32(headerT[8:12], h.Height)
+	headerT[12] = h.Channels
+	headerT[13] = h.Colorspace
+
+	bw.Write(headerT)
+
+	var indexT [64]color.NRGBA
+	prevT := color.NRGBA{0, 0, 0, 255}
+	runT := 0
+	widthT := boundsT.Dx()
+	heightT := boundsT.Dy()
+
+	for y := 0; y < heightT; y++ {
+		rowT := imgT.Pix[y*imgT.Stride : y*imgT.Stride+widthT*4]
+
+		for x := 0; x < widthT; x++ {
+			pxT := color.NRGBA{rowT[x*4], rowT[x*4+1], rowT[x*4+2], rowT[x*4+3]}
+			if h.Channels == 3 {
+				pxT.A = 255
+			}
+
+			if pxT == prevT {
+				runT++
+				if runT == 62 || (y == heightT-1 && x == widthT-1) {
+					bw.WriteByte(qoiOpRun | byte(runT-1))
+					runT = 0
+				}
+				continue
+			}
+
+			if runT > 0 {
+				bw.WriteByte(qoiOpRun | byte(runT-1))
+				runT = 0
+			}
+
+			hashT := qoiHash(pxT)
+
+			switch {
+			case indexT[hashT] == pxT:
+				bw.WriteByte(qoiOpIndex | byte(hashT))
+			case pxT.A == prevT.A:
+				vr := int8(pxT.R - prevT.R)
+				vg := int8(pxT.G - prevT.G)
+				vb := int8(pxT.B - prevT.B)
+				vgr := vr - vg
+				vgb := vb - vg
+
+				switch {
+				case vr > -3 && vr < 2 && vg > -3 && vg < 2 && vb > -3 && vb < 2:
+					bw.WriteByte(qoiOpDiff | byte(vr+2)<<4 | byte(vg+2)<<2 | byte(vb+2))
+				case vgr > -9 && vgr < 8 && vg > -33 && vg < 32 && vgb > -9 && vgb < 8:
+					bw.WriteByte(qoiOpLuma | byte(vg+32))
+					bw.WriteByte(byte(vgr+8)<<4 | byte(vgb+8))
+				default:
+					bw.Write([]byte{qoiOpRGB, pxT.R, pxT.G, pxT.B})
+				}
+			default:
+				bw.Write([]byte{qoiOpRGBA, pxT.R, pxT.G, pxT.B, pxT.A})
+			}
+
+			indexT[hashT] = pxT
+			prevT = pxT
+		}
+	}
+
+	bw.Write(qoiPadding)
+
+	return bw.Flush()
+}
+
+// ReadQOIHeader reads the header of a QOI image, including the channels and the colorspace flag
+func (p *ImageTK) ReadQOIHeader(readerA io.Reader) (*QOIHeader, error) {
+	return readQOIHeader(readerA)
+}
+
+// DecodeQOI decodes a QOI image from the stream, returns the image and the header
+func (p *ImageTK) DecodeQOI(readerA io.Reader) (*image.NRGBA, *QOIHeader, error) {
+	return decodeQOIWithHeader(readerA)
+}
+
+// EncodeQOI encodes the image as QOI to the stream, optsA.QOIChannels and optsA.QOILinear are used if not nil
+func (p *ImageTK) EncodeQOI(writerA io.Writer, imageA image.Image, optsA *EncodeOptions) error {
+	return encodeQOI(writerA, imageA, optsA)
+}
+
+func init() {
+	RegisterFormat("qoi", qoiMagic, decodeQOI, decodeQOIConfig)
+	image.RegisterFormat("qoi", qoiMagic, decodeQOI, decodeQOIConfig)
+
+	RegisterEncoder("qoi", encodeQOI)
+}
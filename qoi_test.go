@@ -0,0 +1,111 @@
+package imagetk
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func TestQOIRoundTrip(t *testing.T) {
+	p := NewImageTK()
+
+	// a gradient for the diff and luma chunks
+	gradient := image.NewNRGBA(image.Rect(0, 0, 64, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 64; x++ {
+			gradient.SetNRGBA(x, y, color.NRGBA{uint8(x), uint8(x + y), uint8(3 * x), 255})
+		}
+	}
+
+	// long runs over the 62 pixel limit and colors seen before for the index chunk
+	runs := image.NewNRGBA(image.Rect(0, 0, 100, 5))
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 100; x++ {
+			runs.SetNRGBA(x, y, color.NRGBA{uint8(y * 40), 0, 0, 255})
+			if x%7 == 0 {
+				runs.SetNRGBA(x, y, color.NRGBA{0, 200, 0, 128})
+			}
+		}
+	}
+
+	// random colors and alpha for the rgb and rgba chunks
+	rnd := rand.New(rand.NewSource(3))
+	noise := image.NewNRGBA(image.Rect(0, 0, 31, 17))
+	rnd.Read(noise.Pix)
+
+	opaque := image.NewRGBA(image.Rect(0, 0, 31, 17))
+	rnd.Read(opaque.Pix)
+	for i := 3; i < len(opaque.Pix); i += 4 {
+		opaque.Pix[i] = 255
+	}
+
+	tests := []struct {
+		name     string
+		img      image.Image
+		opts     *EncodeOptions
+		channels uint8
+		linear   bool
+	}{
+		{"gradient", gradient, nil, 3, false},
+		{"runs", runs, nil, 4, false},
+		{"noise", noise, nil, 4, false},
+		{"opaque", opaque, nil, 3, false},
+		{"opaque rgba", opaque, &EncodeOptions{QOIChannels: 4}, 4, false},
+		{"linear", gradient, &EncodeOptions{QOILinear: true}, 3, true},
+		{"sub-image", noise.SubImage(image.Rect(5, 3, 20, 11)), nil, 4, false},
+		{"one pixel", image.NewNRGBA(image.Rect(0, 0, 1, 1)), nil, 4, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := p.EncodeQOI(&b, tt.img, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+
+			got, h, err := p.DecodeQOI(bytes.NewReader(b.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if h.Channels != tt.channels || (h.Colorspace == QOILinear) != tt.linear {
+				t.Errorf("header %+v, want %v channels and linear %v", h, tt.channels, tt.linear)
+			}
+
+			sameImage(t, got, tt.img)
+
+			img, formatT, err := p.DecodeBytes(b.Bytes())
+			if err != nil || formatT != "qoi" {
+				t.Fatalf("DecodeBytes: %v %v", formatT, err)
+			}
+
+			sameImage(t, img, tt.img)
+		})
+	}
+}
+
+func TestQOIErrors(t *testing.T) {
+	p := NewImageTK()
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+
+	if err := p.EncodeQOI(&bytes.Buffer{}, img, &EncodeOptions{QOIChannels: 2}); err == nil {
+		t.Error("no error for 2 channels")
+	}
+
+	if err := p.EncodeQOI(&bytes.Buffer{}, image.NewNRGBA(image.Rect(0, 0, 0, 4)), nil); err == nil {
+		t.Error("no error for an empty image")
+	}
+
+	var b bytes.Buffer
+	if err := p.EncodeQOI(&b, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{4, 13, b.Len() - 9} {
+		if _, _, err := p.DecodeQOI(bytes.NewReader(b.Bytes()[:n])); err == nil {
+			t.Errorf("no error for %v bytes", n)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package imagetk
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// MedianCutQuantizer is a draw.Quantizer producing palettes by the median cut algorithm,
+// usually much better than palette.Plan9 used by default in the GIF encoder.
+// Pixels with alpha less than 128 are ignored.
+type MedianCutQuantizer struct {
+	// MaxSamples limits the number of pixels sampled, 0 means 65536
+	MaxSamples int
+}
+
+// Quantize implements draw.Quantizer, appends at most cap(p)-len(p) colors to p
+func (q MedianCutQuantizer) Quantize(p color.Palette, m image.Image) color.Palette {
+	return append(p, q.quantizeColors(q.sample(nil, m), cap(p)-len(p))...)
+}
+
+func (q MedianCutQuantizer) maxSamples() int {
+	if q.MaxSamples > 0 {
+		return q.MaxSamples
+	}
+
+	return 65536
+}
+
+// sample appends the sampled opaque colors of the image to the list
+func (q MedianCutQuantizer) sample(listA [][3]uint8, m image.Image) [][3]uint8 {
+	boundsT := m.Bounds()
+
+	stepT := 1
+	for (boundsT.Dx()/stepT)*(boundsT.Dy()/stepT) > q.maxSamples() {
+		stepT++
+	}
+
+	for y := boundsT.Min.Y; y < boundsT.Max.Y; y += stepT {
+		for x := boundsT.Min.X; x < boundsT.Max.X; x += stepT {
+			c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+			if c.A < 128 {
+				continue
+			}
+
+			listA = append(listA, [3]uint8{c.R, c.G, c.B})
+		}
+	}
+
+	return listA
+}
+
+type colorBox struct {
+	colors [][3]uint8
+	// the channel with the widest range and the range
+	channel int
+	spread  int
+}
+
+func newColorBox(colorsA [][3]uint8) *colorBox {
+	b := &colorBox{colors: colorsA}
+
+	var minT, maxT [3]int
+	for c := 0; c < 3; c++ {
+		minT[c], maxT[c] = 255, 0
+	}
+
+	for _, v := range colorsA {
+		for c := 0; c < 3; c++ {
+			if int(v[c]) < minT[c] {
+				minT[c] = int(v[c])
+			}
+			if int(v[c]) > maxT[c] {
+				maxT[c] = int(v[c])
+			}
+		}
+	}
+
+	b.spread = -1
+	for c := 0; c < 3; c++ {
+		if maxT[c]-minT[c] > b.spread {
+			b.channel, b.spread = c, maxT[c]-minT[c]
+		}
+	}
+
+	return b
+}
+
+func (b *colorBox) average() color.RGBA {
+	var sumT [3]int
+
+	for _, v := range b.colors {
+		for c := 0; c < 3; c++ {
+			sumT[c] += int(v[c])
+		}
+	}
+
+	n := len(b.colors)
+
+	return color.RGBA{uint8((sumT[0] + n/2) / n), uint8((sumT[1] + n/2) / n), uint8((sumT[2] + n/2) / n), 255}
+}
+
+// quantizeColors reduces the colors to at most nA colors
+func (q MedianCutQuantizer) quantizeColors(colorsA [][3]uint8, nA int) color.Palette {
+	if nA < 1 || len(colorsA) < 1 {
+		return nil
+	}
+
+	boxesT := []*colorBox{newColorBox(colorsA)}
+
+	for len(boxesT) < nA {
+		// split the box with the widest range weighted by the number of colors
+		bestT := -1
+		bestScoreT := 0
+
+		for i, v := range boxesT {
+			if len(v.colors) < 2 || v.spread < 1 {
+				continue
+			}
+
+			scoreT := v.spread * len(v.colors)
+			if scoreT > bestScoreT {
+				bestT, bestScoreT = i, scoreT
+			}
+		}
+
+		if bestT < 0 {
+			break
+		}
+
+		boxT := boxesT[bestT]
+		channelT := boxT.channel
+
+		sort.Slice(boxT.colors, func(i, j int) bool {
+			return boxT.colors[i][channelT] < boxT.colors[j][channelT]
+		})
+
+		midT := len(boxT.colors) / 2
+
+		boxesT[bestT] = newColorBox(boxT.colors[:midT])
+		boxesT = append(boxesT, newColorBox(boxT.colors[midT:]))
+	}
+
+	paletteT := make(color.Palette, 0, len(boxesT))
+	for _, v := range boxesT {
+		paletteT = append(paletteT, v.average())
+	}
+
+	return paletteT
+}
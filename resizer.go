@@ -0,0 +1,838 @@
+package imagetk
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// ErrSizeMismatch is returned when the image size does not match the size the Resizer is configured with
+var ErrSizeMismatch = errors.New("image size mismatch")
+
+// resizeWeights holds the filter coefficients of one resize pass
+type resizeWeights struct {
+	filterLength  int
+	offset        []int
+	coeffs8       []int16
+	coeffs16      []int32
+	coeffsNearest []bool
+}
+
+// GammaMode selects how the samples are treated before filtering
+type GammaMode int
+
+// GammaMode constants
+const (
+	// GammaNone filters the encoded samples as they are
+	GammaNone GammaMode = iota
+	// GammaSRGB decodes the sRGB samples to linear light before filtering and encodes the result back,
+	// keeping the brightness of fine high-contrast detail. The filtering is done in 16-bit precision.
+	GammaSRGB
+)
+
+// EdgeMode selects how the pixels outside of the image are sampled by the filter
+type EdgeMode int
+
+// EdgeMode constants
+const (
+	// EdgeClamp repeats the edge pixels
+	EdgeClamp EdgeMode = iota
+	// EdgeReflect mirrors the image at its edges
+	EdgeReflect
+	// EdgeWrap repeats the image as a tile
+	EdgeWrap
+)
+
+// ResizeOptions holds the options of resizing
+type ResizeOptions struct {
+	// Interpolation is the interpolation function, note that the zero value is NearestNeighbor,
+	// DefaultResizeOptions returns Lanczos3
+	Interpolation InterpolationFunction
+	// Blur scales the filter support, values <1 will sharpen the image and values >1 will blur it, 0 means 1
+	Blur float64
+	// Workers is the number of goroutines filtering the image, 0 means the number of CPUs
+	Workers int
+	// Gamma selects how the samples are treated before filtering
+	Gamma GammaMode
+	// Edge selects how the pixels outside of the image are sampled
+	Edge EdgeMode
+	// Kernel is a custom filter used instead of Interpolation if not nil
+	Kernel *Kernel
+	// Prereduce first reduces the image by area averaging to a power of two fraction of its size, keeping it
+	// at least 3 times larger than the destination, and then finishes with the filter.
+	// It is much faster for large reduction ratios and looks the same, NearestNeighbor ignores it.
+	Prereduce bool
+}
+
+// DefaultResizeOptions returns the options used when none are given
+func DefaultResizeOptions() ResizeOptions {
+	return ResizeOptions{Interpolation: Lanczos3, Blur: 1}
+}
+
+// getResizeOptions merges the optional arguments(InterpolationFunction, Kernel, ResizeOptions or *ResizeOptions) into the default options,
+// later arguments override earlier ones and other types are ignored
+func getResizeOptions(optsA []interface{}) ResizeOptions {
+	optsT := DefaultResizeOptions()
+
+	for _, v := range optsA {
+		switch nv := v.(type) {
+		case InterpolationFunction:
+			optsT.Interpolation = nv
+			optsT.Kernel = nil
+		case Kernel:
+			optsT.Kernel = &nv
+		case *Kernel:
+			optsT.Kernel = nv
+		case ResizeOptions:
+			optsT = nv
+		case *ResizeOptions:
+			if nv != nil {
+				optsT = *nv
+			}
+		}
+	}
+
+	if optsT.Blur <= 0 {
+		optsT.Blur = 1
+	}
+
+	if optsT.Workers <= 0 {
+		optsT.Workers = runtime.NumCPU()
+	}
+
+	return optsT
+}
+
+// Resizer resizes images of a fixed source size to a fixed destination size.
+// The filter weights are computed on first use and shared by all later calls,
+// a Resizer is safe for concurrent use.
+type Resizer struct {
+	srcWidth  int
+	srcHeight int
+	dstWidth  int
+	dstHeight int
+	interp    InterpolationFunction
+	nearest   bool
+	area      bool
+	taps      int
+	kernel    func(float64) float64
+	blur      float64
+	workers   int
+	gamma     GammaMode
+	edge      EdgeMode
+	scaleX    float64
+	scaleY    float64
+
+	// pixels padded before and after the source for the edge mode, 0 for EdgeClamp
+	padX [2]int
+	padY [2]int
+
+	// reduces the source before the filter for Prereduce, nil if not needed
+	reducer *Resizer
+	// how far the area weights are moved, see prereduceShift
+	areaShift [2]float64
+
+	once8  sync.Once
+	once16 sync.Once
+
+	x8  resizeWeights
+	y8  resizeWeights
+	x16 resizeWeights
+	y16 resizeWeights
+}
+
+// NewResizer creates a Resizer from the source size to the destination size.
+// If one of the destination width and height is 0, it is calculated to keep the aspect ratio.
+// Optional arguments could be an InterpolationFunction(Lanczos3 by default), a Kernel and ResizeOptions or *ResizeOptions.
+func NewResizer(srcWidthA, srcHeightA, dstWidthA, dstHeightA int, optsA ...interface{}) *Resizer {
+	optsT := getResizeOptions(optsA)
+
+	r := &Resizer{srcWidth: srcWidthA, srcHeight: srcHeightA, interp: optsT.Interpolation, blur: optsT.Blur,
+		workers: optsT.Workers, gamma: optsT.Gamma, edge: optsT.Edge}
+
+	if optsT.Kernel != nil && optsT.Kernel.Func != nil {
+		r.taps, r.kernel = optsT.Kernel.taps(), optsT.Kernel.Func
+	} else {
+		r.taps, r.kernel = r.interp.kernel()
+		r.nearest = r.interp == NearestNeighbor
+		r.area = r.interp == Area
+	}
+
+	widthT, heightT := uint(dstWidthA), uint(dstHeightA)
+	if dstWidthA < 0 {
+		widthT = 0
+	}
+	if dstHeightA < 0 {
+		heightT = 0
+	}
+
+	r.scaleX, r.scaleY = calcFactors(widthT, heightT, float64(srcWidthA), float64(srcHeightA))
+
+	if widthT == 0 {
+		widthT = uint(0.7 + float64(srcWidthA)/r.scaleX)
+	}
+	if heightT == 0 {
+		heightT = uint(0.7 + float64(srcHeightA)/r.scaleY)
+	}
+
+	r.dstWidth, r.dstHeight = int(widthT), int(heightT)
+
+	// the size of the image the filter is applied to
+	filterWidthT, filterHeightT := srcWidthA, srcHeightA
+
+	if optsT.Prereduce && !r.nearest {
+		filterWidthT, filterHeightT = prereduceSize(srcWidthA, r.dstWidth), prereduceSize(srcHeightA, r.dstHeight)
+
+		if filterWidthT != srcWidthA || filterHeightT != srcHeightA {
+			r.reducer = NewResizer(srcWidthA, srcHeightA, filterWidthT, filterHeightT,
+				ResizeOptions{Interpolation: Area, Workers: r.workers, Gamma: r.gamma})
+			// Area covers the exact source pixels like the reducer, only the other filters need the reduced pixels moved
+			if !r.area {
+				r.reducer.areaShift = [2]float64{prereduceShift(srcWidthA, filterWidthT), prereduceShift(srcHeightA, filterHeightT)}
+			}
+
+			// keep the scale of the direct filter, it could differ from the size ratio when the aspect ratio is kept
+			r.scaleX *= float64(filterWidthT) / float64(srcWidthA)
+			r.scaleY *= float64(filterHeightT) / float64(srcHeightA)
+		}
+	}
+
+	if r.edge != EdgeClamp {
+		r.padX = r.edgePadding(r.dstWidth, filterWidthT, r.scaleX, 0)
+		r.padY = r.edgePadding(r.dstHeight, filterHeightT, r.scaleY, 0)
+	}
+
+	return r
+}
+
+// NewResizer creates a Resizer, see the package function NewResizer
+func (p *ImageTK) NewResizer(srcWidthA, srcHeightA, dstWidthA, dstHeightA int, optsA ...interface{}) *Resizer {
+	return NewResizer(srcWidthA, srcHeightA, dstWidthA, dstHeightA, optsA...)
+}
+
+// SrcSize returns the source size of the Resizer
+func (r *Resizer) SrcSize() (int, int) {
+	return r.srcWidth, r.srcHeight
+}
+
+// DstSize returns the destination size of the Resizer
+func (r *Resizer) DstSize() (int, int) {
+	return r.dstWidth, r.dstHeight
+}
+
+// edgePadding returns how far the filter reaches before and after the source, the same way as createWeights8 places it
+func (r *Resizer) edgePadding(dyA, srcLenA int, scaleA, shiftA float64) [2]int {
+	var padT [2]int
+
+	if dyA < 1 {
+		return padT
+	}
+
+	filterLength := r.taps * int(math.Max(math.Ceil(r.blur*scaleA), 1))
+
+	first := int(scaleA*0.5) - filterLength/2 + 1
+	last := int(scaleA*(float64(dyA-1)+0.5)) - filterLength/2 + 1 + filterLength
+
+	// the area weights start at the covered pixels, see areaCoverage
+	if r.area && scaleA > 1 {
+		first = int(math.Floor(shiftA))
+		last = int(math.Floor(scaleA*float64(dyA-1)+shiftA)) + int(math.Ceil(scaleA)) + 1
+	}
+
+	if first < 0 {
+		padT[0] = -first
+	}
+
+	if last > srcLenA {
+		padT[1] = last - srcLenA
+	}
+
+	return padT
+}
+
+func (r *Resizer) makeWeights(dyA int, scaleA, shiftA float64, wideA bool, padA [2]int) resizeWeights {
+	var w resizeWeights
+
+	switch {
+	case r.nearest:
+		w.coeffsNearest, w.offset, w.filterLength = createWeightsNearest(dyA, r.taps, r.blur, scaleA)
+	case r.area && scaleA > 1 && wideA:
+		w.coeffs16, w.offset, w.filterLength = createWeightsArea16(dyA, scaleA, shiftA)
+	case r.area && scaleA > 1:
+		w.coeffs8, w.offset, w.filterLength = createWeightsArea8(dyA, scaleA, shiftA)
+	case wideA:
+		w.coeffs16, w.offset, w.filterLength = createWeights16(dyA, r.taps, r.blur, scaleA, r.kernel)
+	default:
+		w.coeffs8, w.offset, w.filterLength = createWeights8(dyA, r.taps, r.blur, scaleA, r.kernel)
+	}
+
+	// the source is padded, so the filter starts later
+	if padA[0] > 0 {
+		for i := range w.offset {
+			w.offset[i] += padA[0]
+		}
+	}
+
+	return w
+}
+
+// weights returns the cached weights of the horizontal and the vertical pass, wideA for 16-bit precision
+func (r *Resizer) weights(wideA bool) (*resizeWeights, *resizeWeights) {
+	if wideA {
+		r.once16.Do(func() {
+			r.x16 = r.makeWeights(r.dstWidth, r.scaleX, r.areaShift[0], true, r.padX)
+			r.y16 = r.makeWeights(r.dstHeight, r.scaleY, r.areaShift[1], true, r.padY)
+		})
+
+		return &r.x16, &r.y16
+	}
+
+	r.once8.Do(func() {
+		r.x8 = r.makeWeights(r.dstWidth, r.scaleX, r.areaShift[0], false, r.padX)
+		r.y8 = r.makeWeights(r.dstHeight, r.scaleY, r.areaShift[1], false, r.padY)
+	})
+
+	return &r.x8, &r.y8
+}
+
+// runRows splits the rows [0, h) into ranges processed by the workers of the Resizer
+func (r *Resizer) runRows(hA int, fnA func(y0, y1 int)) {
+	wg := sync.WaitGroup{}
+
+	wg.Add(r.workers)
+	for i := 0; i < r.workers; i++ {
+		y0, y1 := i*hA/r.workers, (i+1)*hA/r.workers
+		go func() {
+			defer wg.Done()
+			fnA(y0, y1)
+		}()
+	}
+	wg.Wait()
+}
+
+// runParallel splits the image into horizontal slices processed by the workers of the Resizer
+func (r *Resizer) runParallel(imgA imageWithSubImage, fnA func(image.Image)) {
+	wg := sync.WaitGroup{}
+
+	wg.Add(r.workers)
+	for i := 0; i < r.workers; i++ {
+		slice := makeSlice(imgA, i, r.workers)
+		go func() {
+			defer wg.Done()
+			fnA(slice)
+		}()
+	}
+	wg.Wait()
+}
+
+// edgeIndex maps the index outside of [0, n) according to the edge mode
+func edgeIndex(i, n int, edgeA EdgeMode) int {
+	if i >= 0 && i < n {
+		return i
+	}
+
+	switch edgeA {
+	case EdgeReflect:
+		i %= 2 * n
+		if i < 0 {
+			i += 2 * n
+		}
+
+		if i >= n {
+			i = 2*n - 1 - i
+		}
+
+		return i
+	case EdgeWrap:
+		i %= n
+		if i < 0 {
+			i += n
+		}
+
+		return i
+	}
+
+	if i < 0 {
+		return 0
+	}
+
+	return n - 1
+}
+
+// padPix copies the pixels into the larger buffer, filling the padding according to the edge mode
+func (r *Resizer) padPix(dstA []uint8, dstStrideA int, srcA []uint8, srcStrideA, bppA, wA, hA int) {
+	dstW := wA + r.padX[0] + r.padX[1]
+	dstH := hA + r.padY[0] + r.padY[1]
+
+	for y := 0; y < dstH; y++ {
+		rowT := srcA[edgeIndex(y-r.padY[0], hA, r.edge)*srcStrideA:]
+		outT := dstA[y*dstStrideA:]
+
+		copy(outT[r.padX[0]*bppA:], rowT[:wA*bppA])
+
+		for x := 0; x < r.padX[0]; x++ {
+			sx := edgeIndex(x-r.padX[0], wA, r.edge)
+			copy(outT[x*bppA:(x+1)*bppA], rowT[sx*bppA:])
+		}
+
+		for x := r.padX[0] + wA; x < dstW; x++ {
+			sx := edgeIndex(x-r.padX[0], wA, r.edge)
+			copy(outT[x*bppA:(x+1)*bppA], rowT[sx*bppA:])
+		}
+	}
+}
+
+// padImage returns the image padded for the edge mode, images other than the fast path types are converted to RGBA64
+func (r *Resizer) padImage(img image.Image, scratchA *ResizeScratch) image.Image {
+	boundsT := img.Bounds()
+	w, h := boundsT.Dx(), boundsT.Dy()
+	pw, ph := w+r.padX[0]+r.padX[1], h+r.padY[0]+r.padY[1]
+
+	switch nv := img.(type) {
+	case *image.RGBA:
+		outT := scratchA.rgba(scratchPadded, pw, ph)
+		r.padPix(outT.Pix, outT.Stride, nv.Pix, nv.Stride, 4, w, h)
+		return outT
+	case *image.RGBA64:
+		outT := scratchA.rgba64(scratchPadded, pw, ph)
+		r.padPix(outT.Pix, outT.Stride, nv.Pix, nv.Stride, 8, w, h)
+		return outT
+	case *image.Gray:
+		outT := scratchA.gray(scratchPadded, pw, ph)
+		r.padPix(outT.Pix, outT.Stride, nv.Pix, nv.Stride, 1, w, h)
+		return outT
+	case *image.Gray16:
+		outT := scratchA.gray16(scratchPadded, pw, ph)
+		r.padPix(outT.Pix, outT.Stride, nv.Pix, nv.Stride, 2, w, h)
+		return outT
+	case *image.YCbCr:
+		outT := image.NewYCbCr(image.Rect(0, 0, pw, ph), nv.SubsampleRatio)
+		for y := 0; y < ph; y++ {
+			sy := boundsT.Min.Y + edgeIndex(y-r.padY[0], h, r.edge)
+			for x := 0; x < pw; x++ {
+				sx := boundsT.Min.X + edgeIndex(x-r.padX[0], w, r.edge)
+				outT.Y[outT.YOffset(x, y)] = nv.Y[nv.YOffset(sx, sy)]
+				ci := outT.COffset(x, y)
+				sci := nv.COffset(sx, sy)
+				outT.Cb[ci] = nv.Cb[sci]
+				outT.Cr[ci] = nv.Cr[sci]
+			}
+		}
+		return outT
+	}
+
+	rgba64T := scratchA.rgba64(scratchSource, w, h)
+	draw.Draw(rgba64T, rgba64T.Rect, img, boundsT.Min, draw.Src)
+
+	outT := scratchA.rgba64(scratchPadded, pw, ph)
+	r.padPix(outT.Pix, outT.Stride, rgba64T.Pix, rgba64T.Stride, 8, w, h)
+
+	return outT
+}
+
+// ResizeScratch holds the intermediate images of resizing, passing the same ResizeScratch to
+// consecutive calls reuses its buffers instead of allocating them each time.
+// A ResizeScratch must not be used by concurrent calls, use a sync.Pool of *ResizeScratch for that.
+type ResizeScratch struct {
+	bufs [6][]uint8
+	// reduced holds the buffers of Prereduce, the reduced image is read while bufs are written
+	reduced *ResizeScratch
+}
+
+// buffers of ResizeScratch
+const (
+	scratchTemp = iota
+	scratchResult
+	scratchSource
+	scratchPadded
+	scratchLinear
+	scratchOutput
+)
+
+// buffer returns the buffer with the length, s could be nil to allocate a new one
+func (s *ResizeScratch) buffer(indexA, lenA int) []uint8 {
+	if s == nil {
+		return make([]uint8, lenA)
+	}
+
+	if cap(s.bufs[indexA]) < lenA {
+		s.bufs[indexA] = make([]uint8, lenA)
+	}
+
+	return s.bufs[indexA][:lenA]
+}
+
+// reducer returns the scratch of Prereduce, s could be nil to allocate new buffers
+func (s *ResizeScratch) reducer() *ResizeScratch {
+	if s == nil {
+		return nil
+	}
+
+	if s.reduced == nil {
+		s.reduced = &ResizeScratch{}
+	}
+
+	return s.reduced
+}
+
+// the pixels of the images are not cleared since the resize functions overwrite all of them
+
+func (s *ResizeScratch) rgba(indexA, w, h int) *image.RGBA {
+	return &image.RGBA{Pix: s.buffer(indexA, 4*w*h), Stride: 4 * w, Rect: image.Rect(0, 0, w, h)}
+}
+
+func (s *ResizeScratch) rgba64(indexA, w, h int) *image.RGBA64 {
+	return &image.RGBA64{Pix: s.buffer(indexA, 8*w*h), Stride: 8 * w, Rect: image.Rect(0, 0, w, h)}
+}
+
+func (s *ResizeScratch) nrgba(indexA, w, h int) *image.NRGBA {
+	return &image.NRGBA{Pix: s.buffer(indexA, 4*w*h), Stride: 4 * w, Rect: image.Rect(0, 0, w, h)}
+}
+
+func (s *ResizeScratch) nrgba64(indexA, w, h int) *image.NRGBA64 {
+	return &image.NRGBA64{Pix: s.buffer(indexA, 8*w*h), Stride: 8 * w, Rect: image.Rect(0, 0, w, h)}
+}
+
+func (s *ResizeScratch) gray(indexA, w, h int) *image.Gray {
+	return &image.Gray{Pix: s.buffer(indexA, w*h), Stride: w, Rect: image.Rect(0, 0, w, h)}
+}
+
+func (s *ResizeScratch) gray16(indexA, w, h int) *image.Gray16 {
+	return &image.Gray16{Pix: s.buffer(indexA, 2*w*h), Stride: 2 * w, Rect: image.Rect(0, 0, w, h)}
+}
+
+func (s *ResizeScratch) ycc(indexA, w, h int, ratioA image.YCbCrSubsampleRatio) *ycc {
+	return &ycc{Pix: s.buffer(indexA, 3*w*h), Stride: 3 * w, Rect: image.Rect(0, 0, w, h), SubsampleRatio: ratioA}
+}
+
+// getScratch returns the scratch in the optional arguments(*ResizeScratch or *sync.Pool),
+// and the pool to put it back to
+func getScratch(scratchA []interface{}) (*ResizeScratch, *sync.Pool) {
+	for _, v := range scratchA {
+		switch nv := v.(type) {
+		case *ResizeScratch:
+			return nv, nil
+		case *sync.Pool:
+			if nv == nil {
+				continue
+			}
+
+			scratchT, _ := nv.Get().(*ResizeScratch)
+			if scratchT == nil {
+				scratchT = &ResizeScratch{}
+			}
+
+			return scratchT, nv
+		}
+	}
+
+	return nil, nil
+}
+
+// rebaseImage returns an image sharing the pixels of the image with the bounds moved to (0,0),
+// nil if the image type is not supported
+func rebaseImage(imageA image.Image) image.Image {
+	if imageA == nil {
+		return nil
+	}
+
+	boundsT := imageA.Bounds()
+	rectT := image.Rect(0, 0, boundsT.Dx(), boundsT.Dy())
+
+	if boundsT.Min == (image.Point{}) {
+		return imageA
+	}
+
+	switch nv := imageA.(type) {
+	case *image.RGBA:
+		return &image.RGBA{Pix: nv.Pix, Stride: nv.Stride, Rect: rectT}
+	case *image.NRGBA:
+		return &image.NRGBA{Pix: nv.Pix, Stride: nv.Stride, Rect: rectT}
+	case *image.RGBA64:
+		return &image.RGBA64{Pix: nv.Pix, Stride: nv.Stride, Rect: rectT}
+	case *image.NRGBA64:
+		return &image.NRGBA64{Pix: nv.Pix, Stride: nv.Stride, Rect: rectT}
+	case *image.Gray:
+		return &image.Gray{Pix: nv.Pix, Stride: nv.Stride, Rect: rectT}
+	case *image.Gray16:
+		return &image.Gray16{Pix: nv.Pix, Stride: nv.Stride, Rect: rectT}
+	}
+
+	return nil
+}
+
+// Resize resizes the source image into the destination image, both must have the sizes the Resizer is configured with.
+// The result is written directly if dst has the type the source is resized to(such as *image.RGBA for *image.RGBA),
+// otherwise it is drawn onto dst.
+// The optional argument could be a *ResizeScratch or a *sync.Pool of *ResizeScratch for the intermediate images.
+func (r *Resizer) Resize(dstA draw.Image, srcA image.Image, scratchA ...interface{}) error {
+	srcSizeT := srcA.Bounds().Size()
+	if srcSizeT.X != r.srcWidth || srcSizeT.Y != r.srcHeight {
+		return fmt.Errorf("%w: source %vx%v, expected %vx%v", ErrSizeMismatch, srcSizeT.X, srcSizeT.Y, r.srcWidth, r.srcHeight)
+	}
+
+	dstSizeT := dstA.Bounds().Size()
+	if dstSizeT.X != r.dstWidth || dstSizeT.Y != r.dstHeight {
+		return fmt.Errorf("%w: destination %vx%v, expected %vx%v", ErrSizeMismatch, dstSizeT.X, dstSizeT.Y, r.dstWidth, r.dstHeight)
+	}
+
+	scratchT, poolT := getScratch(scratchA)
+	if poolT != nil {
+		defer poolT.Put(scratchT)
+	}
+
+	var outT draw.Image
+	if v, ok := rebaseImage(dstA).(draw.Image); ok {
+		outT = v
+	}
+
+	resultT := r.resize(srcA, outT, scratchT)
+
+	if resultT != image.Image(outT) {
+		draw.Draw(dstA, dstA.Bounds(), resultT, image.Point{}, draw.Src)
+	}
+
+	return nil
+}
+
+// ResizeImage resizes the source image to a new image
+func (r *Resizer) ResizeImage(srcA image.Image) (image.Image, error) {
+	srcSizeT := srcA.Bounds().Size()
+	if srcSizeT.X != r.srcWidth || srcSizeT.Y != r.srcHeight {
+		return nil, fmt.Errorf("%w: source %vx%v, expected %vx%v", ErrSizeMismatch, srcSizeT.X, srcSizeT.Y, r.srcWidth, r.srcHeight)
+	}
+
+	return r.resize(srcA, nil, nil), nil
+}
+
+// ResizeInto resizes the source image into the preallocated destination image,
+// optional arguments could be the scratch arguments of Resizer.Resize and the options of NewResizer(overriding interpA)
+func (p *ImageTK) ResizeInto(dstA draw.Image, srcA image.Image, interpA InterpolationFunction, scratchA ...interface{}) error {
+	srcBoundsT := srcA.Bounds()
+	dstBoundsT := dstA.Bounds()
+
+	if dstBoundsT.Empty() {
+		return nil
+	}
+
+	optsT := append([]interface{}{interpA}, scratchA...)
+
+	return NewResizer(srcBoundsT.Dx(), srcBoundsT.Dy(), dstBoundsT.Dx(), dstBoundsT.Dy(), optsT...).Resize(dstA, srcA, scratchA...)
+}
+
+// resize resizes the image, the result is written to outA if it has the matching type and starts at (0,0).
+// Otherwise the result is a new image, or shares the buffer of scratchA if it is not nil.
+func (r *Resizer) resize(img image.Image, outA draw.Image, scratchA *ResizeScratch) image.Image {
+	if r.reducer != nil {
+		img = r.reducer.resize(img, nil, scratchA.reducer())
+	}
+
+	if r.gamma == GammaSRGB {
+		return r.resizeLinear(img, outA, scratchA)
+	}
+
+	switch img.(type) {
+	case *image.NRGBA, *image.NRGBA64:
+		return r.resizeNRGBA(img, outA, scratchA)
+	}
+
+	return r.resizeEncoded(img, outA, scratchA)
+}
+
+// resizeEncoded resizes the samples of the image as they are, see resize for the result
+func (r *Resizer) resizeEncoded(img image.Image, outA draw.Image, scratchA *ResizeScratch) image.Image {
+	width, height := r.dstWidth, r.dstHeight
+	scaleX, scaleY := r.scaleX, r.scaleY
+	nearestT := r.nearest
+
+	if r.edge != EdgeClamp {
+		img = r.padImage(img, scratchA)
+	}
+
+	// Generic access to image.Image is slow in tight loops.
+	// The optimal access has to be determined from the concrete image type.
+	switch input := img.(type) {
+	case *image.RGBA:
+		// 8-bit precision
+		temp := scratchA.rgba(scratchTemp, input.Bounds().Dy(), width)
+		result, ok := outA.(*image.RGBA)
+		if !ok {
+			result = scratchA.rgba(scratchResult, width, height)
+		}
+		wx, wy := r.weights(false)
+
+		// horizontal filter, results in transposed temporary image
+		r.runParallel(temp, func(slice image.Image) {
+			if nearestT {
+				nearestRGBA(input, slice.(*image.RGBA), scaleX, wx.coeffsNearest, wx.offset, wx.filterLength)
+			} else {
+				resizeRGBA(input, slice.(*image.RGBA), scaleX, wx.coeffs8, wx.offset, wx.filterLength)
+			}
+		})
+
+		// horizontal filter on transposed image, result is not transposed
+		r.runParallel(result, func(slice image.Image) {
+			if nearestT {
+				nearestRGBA(temp, slice.(*image.RGBA), scaleY, wy.coeffsNearest, wy.offset, wy.filterLength)
+			} else {
+				resizeRGBA(temp, slice.(*image.RGBA), scaleY, wy.coeffs8, wy.offset, wy.filterLength)
+			}
+		})
+
+		return result
+	case *image.YCbCr:
+		// 8-bit precision
+		// accessing the YCbCr arrays in a tight loop is slow.
+		// converting the image to ycc increases performance by 2x.
+		temp := scratchA.ycc(scratchTemp, input.Bounds().Dy(), width, input.SubsampleRatio)
+		result := scratchA.ycc(scratchResult, width, height, input.SubsampleRatio)
+
+		wx, wy := r.weights(false)
+
+		var in *ycc
+		if scratchA != nil {
+			in = imageYCbCrToYCC(input, scratchA.bufs[scratchSource])
+			scratchA.bufs[scratchSource] = in.Pix
+		} else {
+			in = imageYCbCrToYCC(input, nil)
+		}
+
+		r.runParallel(temp, func(slice image.Image) {
+			if nearestT {
+				nearestYCbCr(in, slice.(*ycc), scaleX, wx.coeffsNearest, wx.offset, wx.filterLength)
+			} else {
+				resizeYCbCr(in, slice.(*ycc), scaleX, wx.coeffs8, wx.offset, wx.filterLength)
+			}
+		})
+
+		r.runParallel(result, func(slice image.Image) {
+			if nearestT {
+				nearestYCbCr(temp, slice.(*ycc), scaleY, wy.coeffsNearest, wy.offset, wy.filterLength)
+			} else {
+				resizeYCbCr(temp, slice.(*ycc), scaleY, wy.coeffs8, wy.offset, wy.filterLength)
+			}
+		})
+
+		return result.YCbCr()
+	case *image.RGBA64:
+		// 16-bit precision
+		temp := scratchA.rgba64(scratchTemp, input.Bounds().Dy(), width)
+		result, ok := outA.(*image.RGBA64)
+		if !ok {
+			result = scratchA.rgba64(scratchResult, width, height)
+		}
+
+		wx, wy := r.weights(true)
+
+		// horizontal filter, results in transposed temporary image
+		r.runParallel(temp, func(slice image.Image) {
+			if nearestT {
+				nearestRGBA64(input, slice.(*image.RGBA64), scaleX, wx.coeffsNearest, wx.offset, wx.filterLength)
+			} else {
+				resizeRGBA64(input, slice.(*image.RGBA64), scaleX, wx.coeffs16, wx.offset, wx.filterLength)
+			}
+		})
+
+		// horizontal filter on transposed image, result is not transposed
+		r.runParallel(result, func(slice image.Image) {
+			if nearestT {
+				nearestRGBA64(temp, slice.(*image.RGBA64), scaleY, wy.coeffsNearest, wy.offset, wy.filterLength)
+			} else {
+				resizeRGBA64(temp, slice.(*image.RGBA64), scaleY, wy.coeffs16, wy.offset, wy.filterLength)
+			}
+		})
+
+		return result
+	case *image.Gray:
+		// 8-bit precision
+		temp := scratchA.gray(scratchTemp, input.Bounds().Dy(), width)
+		result, ok := outA.(*image.Gray)
+		if !ok {
+			result = scratchA.gray(scratchResult, width, height)
+		}
+
+		wx, wy := r.weights(false)
+
+		// horizontal filter, results in transposed temporary image
+		r.runParallel(temp, func(slice image.Image) {
+			if nearestT {
+				nearestGray(input, slice.(*image.Gray), scaleX, wx.coeffsNearest, wx.offset, wx.filterLength)
+			} else {
+				resizeGray(input, slice.(*image.Gray), scaleX, wx.coeffs8, wx.offset, wx.filterLength)
+			}
+		})
+
+		// horizontal filter on transposed image, result is not transposed
+		r.runParallel(result, func(slice image.Image) {
+			if nearestT {
+				nearestGray(temp, slice.(*image.Gray), scaleY, wy.coeffsNearest, wy.offset, wy.filterLength)
+			} else {
+				resizeGray(temp, slice.(*image.Gray), scaleY, wy.coeffs8, wy.offset, wy.filterLength)
+			}
+		})
+
+		return result
+	case *image.Gray16:
+		// 16-bit precision
+		temp := scratchA.gray16(scratchTemp, input.Bounds().Dy(), width)
+		result, ok := outA.(*image.Gray16)
+		if !ok {
+			result = scratchA.gray16(scratchResult, width, height)
+		}
+
+		wx, wy := r.weights(true)
+
+		// horizontal filter, results in transposed temporary image
+		r.runParallel(temp, func(slice image.Image) {
+			if nearestT {
+				nearestGray16(input, slice.(*image.Gray16), scaleX, wx.coeffsNearest, wx.offset, wx.filterLength)
+			} else {
+				resizeGray16(input, slice.(*image.Gray16), scaleX, wx.coeffs16, wx.offset, wx.filterLength)
+			}
+		})
+
+		// horizontal filter on transposed image, result is not transposed
+		r.runParallel(result, func(slice image.Image) {
+			if nearestT {
+				nearestGray16(temp, slice.(*image.Gray16), scaleY, wy.coeffsNearest, wy.offset, wy.filterLength)
+			} else {
+				resizeGray16(temp, slice.(*image.Gray16), scaleY, wy.coeffs16, wy.offset, wy.filterLength)
+			}
+		})
+
+		return result
+	default:
+		// 16-bit precision
+		temp := scratchA.rgba64(scratchTemp, img.Bounds().Dy(), width)
+		result, ok := outA.(*image.RGBA64)
+		if !ok {
+			result = scratchA.rgba64(scratchResult, width, height)
+		}
+
+		wx, wy := r.weights(true)
+
+		// horizontal filter, results in transposed temporary image
+		r.runParallel(temp, func(slice image.Image) {
+			if nearestT {
+				nearestGeneric(img, slice.(*image.RGBA64), scaleX, wx.coeffsNearest, wx.offset, wx.filterLength)
+			} else {
+				resizeGeneric(img, slice.(*image.RGBA64), scaleX, wx.coeffs16, wx.offset, wx.filterLength)
+			}
+		})
+
+		// horizontal filter on transposed image, result is not transposed
+		r.runParallel(result, func(slice image.Image) {
+			if nearestT {
+				nearestRGBA64(temp, slice.(*image.RGBA64), scaleY, wy.coeffsNearest, wy.offset, wy.filterLength)
+			} else {
+				resizeRGBA64(temp, slice.(*image.RGBA64), scaleY, wy.coeffs16, wy.offset, wy.filterLength)
+			}
+		})
+
+		return result
+	}
+}
@@ -0,0 +1,338 @@
+package imagetk
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// noiseRGBA returns an opaque image of random colors
+func noiseRGBA(w, h int, seedA int64) *image.RGBA {
+	rT := rand.New(rand.NewSource(seedA))
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	rT.Read(img.Pix)
+
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+
+	return img
+}
+
+func TestResizerMatchesResizeImage(t *testing.T) {
+	p := NewImageTK()
+
+	tests := []struct {
+		name         string
+		srcW, srcH   int
+		dstW, dstH   int
+		interp       InterpolationFunction
+		wantW, wantH int
+	}{
+		{"down lanczos", 40, 30, 16, 12, Lanczos3, 16, 12},
+		{"up bicubic", 9, 7, 20, 15, Bicubic, 20, 15},
+		{"keep aspect", 40, 30, 20, 0, MitchellNetravali, 20, 15},
+		{"nearest", 33, 17, 10, 9, NearestNeighbor, 10, 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewResizer(tt.srcW, tt.srcH, tt.dstW, tt.dstH, tt.interp)
+
+			if w, h := r.SrcSize(); w != tt.srcW || h != tt.srcH {
+				t.Errorf("source size %vx%v", w, h)
+			}
+
+			if w, h := r.DstSize(); w != tt.wantW || h != tt.wantH {
+				t.Fatalf("destination size %vx%v, want %vx%v", w, h, tt.wantW, tt.wantH)
+			}
+
+			// the cached weights give the same result for every source
+			for seed := int64(1); seed <= 3; seed++ {
+				src := noiseRGBA(tt.srcW, tt.srcH, seed)
+				want := p.ResizeImage(tt.dstW, tt.dstH, src, tt.interp)
+
+				got, err := r.ResizeImage(src)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				sameImage(t, got, want)
+
+				dst := image.NewRGBA(image.Rect(0, 0, tt.wantW, tt.wantH))
+				if err := r.Resize(dst, src); err != nil {
+					t.Fatal(err)
+				}
+
+				sameImage(t, dst, want)
+			}
+		})
+	}
+}
+
+func TestResizerConcurrent(t *testing.T) {
+	p := NewImageTK()
+	r := NewResizer(48, 36, 20, 15, Lanczos3)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(seedA int64) {
+			defer wg.Done()
+
+			src := noiseRGBA(48, 36, seedA)
+
+			dst := image.NewRGBA(image.Rect(0, 0, 20, 15))
+			if err := r.Resize(dst, src); err != nil {
+				t.Error(err)
+				return
+			}
+
+			sameImage(t, dst, p.ResizeImage(20, 15, src, Lanczos3))
+		}(int64(i))
+	}
+
+	wg.Wait()
+}
+
+func TestResizerSizeMismatch(t *testing.T) {
+	r := NewResizer(10, 8, 5, 4, Bilinear)
+
+	if _, err := r.ResizeImage(image.NewRGBA(image.Rect(0, 0, 8, 10))); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("got %v, want ErrSizeMismatch", err)
+	}
+
+	tests := []struct {
+		name     string
+		src, dst image.Rectangle
+	}{
+		{"source", image.Rect(0, 0, 10, 9), image.Rect(0, 0, 5, 4)},
+		{"destination", image.Rect(0, 0, 10, 8), image.Rect(0, 0, 4, 5)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.Resize(image.NewRGBA(tt.dst), image.NewRGBA(tt.src))
+			if !errors.Is(err, ErrSizeMismatch) {
+				t.Errorf("got %v, want ErrSizeMismatch", err)
+			}
+		})
+	}
+
+	// the size matters, not the position
+	src := noiseRGBA(12, 10, 7).SubImage(image.Rect(2, 2, 12, 10))
+	dst := image.NewRGBA(image.Rect(3, 3, 8, 7))
+
+	if err := r.Resize(dst, src); err != nil {
+		t.Fatal(err)
+	}
+
+	sameImage(t, dst, NewImageTK().ResizeImage(5, 4, src, Bilinear))
+}
+
+// noiseImages returns the noise image in each type with a fast path
+func noiseImages(w, h int, seedA int64) map[string]image.Image {
+	rgbaT := noiseRGBA(w, h, seedA)
+
+	imagesT := map[string]image.Image{"rgba": rgbaT}
+
+	for name, v := range map[string]draw.Image{
+		"rgba64": image.NewRGBA64(rgbaT.Rect),
+		"gray":   image.NewGray(rgbaT.Rect),
+		"gray16": image.NewGray16(rgbaT.Rect),
+	} {
+		draw.Draw(v, v.Bounds(), rgbaT, image.Point{}, draw.Src)
+		imagesT[name] = v
+	}
+
+	yccT := image.NewYCbCr(rgbaT.Rect, image.YCbCrSubsampleRatio444)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := rgbaT.RGBAAt(x, y)
+			yy, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+			yccT.Y[yccT.YOffset(x, y)], yccT.Cb[yccT.COffset(x, y)], yccT.Cr[yccT.COffset(x, y)] = yy, cb, cr
+		}
+	}
+
+	imagesT["ycbcr"] = yccT
+
+	return imagesT
+}
+
+// newLike returns a new image of the same type as img if it is a draw.Image, otherwise an RGBA image
+func newLike(img image.Image, w, h int) draw.Image {
+	rectT := image.Rect(0, 0, w, h)
+
+	switch img.(type) {
+	case *image.RGBA64:
+		return image.NewRGBA64(rectT)
+	case *image.Gray:
+		return image.NewGray(rectT)
+	case *image.Gray16:
+		return image.NewGray16(rectT)
+	}
+
+	return image.NewRGBA(rectT)
+}
+
+func TestResizeIntoScratch(t *testing.T) {
+	p := NewImageTK()
+
+	// larger and smaller sizes one after the other, the buffers left from earlier calls must not show through
+	sizes := []image.Point{{31, 23}, {7, 5}, {40, 33}, {12, 30}, {5, 4}}
+
+	scratches := []struct {
+		name    string
+		scratch interface{}
+	}{
+		{"none", nil},
+		{"scratch", &ResizeScratch{}},
+		{"pool", &sync.Pool{}},
+	}
+
+	for _, sc := range scratches {
+		for _, name := range []string{"rgba", "rgba64", "gray", "gray16", "ycbcr"} {
+			t.Run(sc.name+" "+name, func(t *testing.T) {
+				for i, v := range sizes {
+					// another source each time as well
+					src := noiseImages(24+i, 18-i, int64(i))[name]
+					dst := newLike(src, v.X, v.Y)
+
+					// the YCbCr result is converted to RGBA like ResizeInto does
+					want := newLike(src, v.X, v.Y)
+					draw.Draw(want, want.Bounds(), p.ResizeImage(v.X, v.Y, src, Lanczos3), image.Point{}, draw.Src)
+
+					var err error
+					if sc.scratch == nil {
+						err = p.ResizeInto(dst, src, Lanczos3)
+					} else {
+						err = p.ResizeInto(dst, src, Lanczos3, sc.scratch)
+					}
+
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					sameImage(t, dst, want)
+				}
+			})
+		}
+	}
+}
+
+func TestResizeIntoDrawsOtherTypes(t *testing.T) {
+	p := NewImageTK()
+	src := noiseRGBA(30, 20, 3)
+	want := p.ResizeImage(12, 8, src, Bilinear)
+
+	// not written directly, the result is drawn onto it
+	dst := image.NewNRGBA(image.Rect(4, 4, 16, 12))
+	if err := p.ResizeInto(dst, src, Bilinear, &ResizeScratch{}); err != nil {
+		t.Fatal(err)
+	}
+
+	sameImage(t, dst, want)
+
+	// an empty destination is left alone
+	if err := p.ResizeInto(image.NewRGBA(image.Rectangle{}), src, Bilinear); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResizeScratchSizeMismatch(t *testing.T) {
+	r := NewResizer(20, 10, 10, 5, Lanczos3)
+	scratchT := &ResizeScratch{}
+
+	if err := r.Resize(image.NewRGBA(image.Rect(0, 0, 10, 5)), noiseRGBA(20, 10, 1), scratchT); err != nil {
+		t.Fatal(err)
+	}
+
+	err := r.Resize(image.NewRGBA(image.Rect(0, 0, 10, 6)), noiseRGBA(20, 10, 1), scratchT)
+	if !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("got %v, want ErrSizeMismatch", err)
+	}
+
+	err = r.Resize(image.NewRGBA(image.Rect(0, 0, 10, 5)), noiseRGBA(21, 10, 1), &sync.Pool{})
+	if !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("got %v, want ErrSizeMismatch", err)
+	}
+}
+
+func TestResizeOptionsBlurPerCall(t *testing.T) {
+	p := NewImageTK()
+	src := noiseRGBA(40, 30, 9)
+
+	blurs := []float64{0.5, 1, 2}
+	want := make([]image.Image, len(blurs))
+
+	for i, v := range blurs {
+		want[i] = p.ResizeImage(20, 15, src, ResizeOptions{Interpolation: Lanczos3, Blur: v})
+	}
+
+	// 0 and the default options mean a blur of 1
+	sameImage(t, p.ResizeImage(20, 15, src, ResizeOptions{Interpolation: Lanczos3}), want[1])
+	sameImage(t, p.ResizeImage(20, 15, src), want[1])
+
+	for i := 0; i < len(blurs)-1; i++ {
+		if equalPixels(want[i], want[i+1]) {
+			t.Errorf("blur %v and %v give the same image", blurs[i], blurs[i+1])
+		}
+	}
+
+	// concurrent calls with different options do not affect each other
+	var wg sync.WaitGroup
+
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+
+		go func(indexA int) {
+			defer wg.Done()
+
+			optsT := ResizeOptions{Interpolation: Lanczos3, Blur: blurs[indexA%len(blurs)], Workers: 1 + indexA%3}
+
+			got := p.ResizeImage(20, 15, src, &optsT)
+			if !equalPixels(got, want[indexA%len(blurs)]) {
+				t.Errorf("call %v with blur %v gives another image", indexA, optsT.Blur)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	// Thumbnail and EnlargeImage pass the options on
+	sameImage(t, p.Thumbnail(20, 20, src, ResizeOptions{Interpolation: Lanczos3, Blur: 2}), want[2])
+
+	enlarged, err := p.EnlargeImage(noiseRGBA(10, 8, 2), 3, ResizeOptions{Interpolation: Bicubic, Blur: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if enlarged.Bounds().Size() != image.Pt(30, 24) {
+		t.Errorf("enlarged to %v", enlarged.Bounds().Size())
+	}
+}
+
+// equalPixels reports whether the images have the same size and pixels
+func equalPixels(a, b image.Image) bool {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Size() != bb.Size() {
+		return false
+	}
+
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			if color.NRGBA64Model.Convert(a.At(ab.Min.X+x, ab.Min.Y+y)) != color.NRGBA64Model.Convert(b.At(bb.Min.X+x, bb.Min.Y+y)) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,177 @@
+package imagetk
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrFileExists is returned when saving with NoOverwrite and the target file already exists
+var ErrFileExists = errors.New("file already exists")
+
+// SaveOptions controls how the image files are written
+type SaveOptions struct {
+	// Perm is the permission of the saved file, 0 means keeping the permission of the existing file or 0644 for new files
+	Perm os.FileMode
+	// NoOverwrite makes the save fail with ErrFileExists if the target file already exists
+	NoOverwrite bool
+}
+
+// saveArgs holds the optional arguments of SaveImageAs, SaveAnimation and StreamResizeFile
+type saveArgs struct {
+	format     string
+	encodeOpts *EncodeOptions
+	saveOpts   *SaveOptions
+}
+
+// getSaveArgs parses the optional arguments of the save functions, which could be a format string such as "png", ".jpg",
+// *EncodeOptions/EncodeOptions, *SaveOptions/SaveOptions and *ImageMeta/ImageMeta. If no format is given, the format is
+// chosen from the file extension, and falls back to PNG only if the extension is not a known format.
+func getSaveArgs(filePathA string, optsA []interface{}) saveArgs {
+	var argsT saveArgs
+	var metaT *ImageMeta
+
+	for _, v := range optsA {
+		switch nv := v.(type) {
+		case string:
+			argsT.format = normalizeFormat(nv)
+		case *EncodeOptions:
+			argsT.encodeOpts = nv
+		case EncodeOptions:
+			argsT.encodeOpts = &nv
+		case *SaveOptions:
+			argsT.saveOpts = nv
+		case SaveOptions:
+			argsT.saveOpts = &nv
+		case *ImageMeta:
+			metaT = nv
+		case ImageMeta:
+			metaT = &nv
+		}
+	}
+
+	if argsT.format == "" {
+		argsT.format = normalizeFormat(filepath.Ext(filePathA))
+
+		if argsT.format == "" || !isKnownFormat(argsT.format) {
+			argsT.format = "png"
+		}
+	}
+
+	argsT.encodeOpts = metaT.encodeOptions(argsT.encodeOpts)
+
+	return argsT
+}
+
+// writeFileAtomic writes the file through a temp file in the same directory,
+// the temp file is fsynced and then renamed(or linked for NoOverwrite) into place,
+// so the target file is either the old one or the complete new one.
+func writeFileAtomic(filePathA string, optsA *SaveOptions, writeA func(io.Writer) error) (errR error) {
+	if optsA == nil {
+		optsA = &SaveOptions{}
+	}
+
+	permT := optsA.Perm
+
+	infoT, errT := os.Stat(filePathA)
+	if errT == nil {
+		if optsA.NoOverwrite {
+			return fmt.Errorf("%w: %v", ErrFileExists, filePathA)
+		}
+
+		if permT == 0 {
+			permT = infoT.Mode().Perm()
+		}
+	} else if !errors.Is(errT, os.ErrNotExist) {
+		return fmt.Errorf("failed to stat file %v: %w", filePathA, errT)
+	}
+
+	if permT == 0 {
+		permT = 0644
+	}
+
+	dirT, baseT := filepath.Split(filePathA)
+	if dirT == "" {
+		dirT = "."
+	}
+
+	tmpFileT, errT := os.CreateTemp(dirT, "."+baseT+".tmp*")
+	if errT != nil {
+		return fmt.Errorf("failed to create temp file: %w", errT)
+	}
+
+	tmpPathT := tmpFileT.Name()
+
+	defer func() {
+		if errR != nil {
+			tmpFileT.Close()
+			os.Remove(tmpPathT)
+		}
+	}()
+
+	bufT := bufio.NewWriter(tmpFileT)
+
+	errT = writeA(bufT)
+	if errT != nil {
+		return fmt.Errorf("failed to encode image: %w", errT)
+	}
+
+	errT = bufT.Flush()
+	if errT != nil {
+		return fmt.Errorf("failed to write file: %w", errT)
+	}
+
+	errT = tmpFileT.Chmod(permT)
+	if errT != nil {
+		return fmt.Errorf("failed to set file permission: %w", errT)
+	}
+
+	errT = tmpFileT.Sync()
+	if errT != nil {
+		return fmt.Errorf("failed to sync file: %w", errT)
+	}
+
+	errT = tmpFileT.Close()
+	if errT != nil {
+		return fmt.Errorf("failed to close file: %w", errT)
+	}
+
+	if optsA.NoOverwrite {
+		// link fails if the target exists, which makes the check and the creation atomic
+		errT = os.Link(tmpPathT, filePathA)
+		if errT != nil {
+			if errors.Is(errT, os.ErrExist) {
+				return fmt.Errorf("%w: %v", ErrFileExists, filePathA)
+			}
+
+			// hard links not supported by the file system, fall back to a non-atomic check
+			if _, errT = os.Lstat(filePathA); errT == nil {
+				return fmt.Errorf("%w: %v", ErrFileExists, filePathA)
+			}
+
+			errT = os.Rename(tmpPathT, filePathA)
+			if errT != nil {
+				return fmt.Errorf("failed to rename temp file: %w", errT)
+			}
+		} else {
+			os.Remove(tmpPathT)
+		}
+	} else {
+		errT = os.Rename(tmpPathT, filePathA)
+		if errT != nil {
+			return fmt.Errorf("failed to rename temp file: %w", errT)
+		}
+	}
+
+	// make the rename durable, not supported on all platforms so errors are ignored
+	dirFileT, errT := os.Open(dirT)
+	if errT == nil {
+		dirFileT.Sync()
+		dirFileT.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package imagetk
+
+import (
+	"errors"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// dirEntries returns the names of the files in the directory
+func dirEntries(t *testing.T, dirA string) []string {
+	t.Helper()
+
+	entriesT, err := os.ReadDir(dirA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var namesT []string
+	for _, v := range entriesT {
+		namesT = append(namesT, v.Name())
+	}
+
+	return namesT
+}
+
+// writeString returns a write function of writeFileAtomic writing the string
+func writeString(s string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
+
+func TestWriteFileAtomicFailedWrite(t *testing.T) {
+	dirT := t.TempDir()
+	pathT := filepath.Join(dirT, "a.png")
+
+	errWriteT := errors.New("write failed")
+	failT := func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return errWriteT
+	}
+
+	// a new file is not created
+	if err := writeFileAtomic(pathT, nil, failT); !errors.Is(err, errWriteT) {
+		t.Fatalf("got %v, want the write error", err)
+	}
+
+	if namesT := dirEntries(t, dirT); len(namesT) != 0 {
+		t.Fatalf("files left: %v", namesT)
+	}
+
+	// an existing file is kept as it was
+	if err := os.WriteFile(pathT, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(pathT, nil, failT); !errors.Is(err, errWriteT) {
+		t.Fatalf("got %v, want the write error", err)
+	}
+
+	dataT, err := os.ReadFile(pathT)
+	if err != nil || string(dataT) != "old" {
+		t.Errorf("got %q, %v", dataT, err)
+	}
+
+	if namesT := dirEntries(t, dirT); len(namesT) != 1 {
+		t.Errorf("files left: %v", namesT)
+	}
+}
+
+func TestWriteFileAtomicPerm(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no Unix permissions")
+	}
+
+	dirT := t.TempDir()
+
+	tests := []struct {
+		name     string
+		existing os.FileMode
+		perm     os.FileMode
+		want     os.FileMode
+	}{
+		{"new default", 0, 0, 0644},
+		{"new with perm", 0, 0600, 0600},
+		{"existing kept", 0640, 0, 0640},
+		{"existing replaced", 0640, 0604, 0604},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pathT := filepath.Join(dirT, string(rune('a'+i)))
+
+			if tt.existing != 0 {
+				if err := os.WriteFile(pathT, []byte("old"), tt.existing); err != nil {
+					t.Fatal(err)
+				}
+
+				// not affected by the umask
+				if err := os.Chmod(pathT, tt.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := writeFileAtomic(pathT, &SaveOptions{Perm: tt.perm}, writeString("new")); err != nil {
+				t.Fatal(err)
+			}
+
+			infoT, err := os.Stat(pathT)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if infoT.Mode().Perm() != tt.want {
+				t.Errorf("mode %v, want %v", infoT.Mode().Perm(), tt.want)
+			}
+
+			if dataT, _ := os.ReadFile(pathT); string(dataT) != "new" {
+				t.Errorf("content %q", dataT)
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomicNoOverwrite(t *testing.T) {
+	dirT := t.TempDir()
+	pathT := filepath.Join(dirT, "a.png")
+
+	if err := writeFileAtomic(pathT, &SaveOptions{NoOverwrite: true}, writeString("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := writeFileAtomic(pathT, &SaveOptions{NoOverwrite: true}, writeString("second"))
+	if !errors.Is(err, ErrFileExists) {
+		t.Errorf("got %v, want ErrFileExists", err)
+	}
+
+	if dataT, _ := os.ReadFile(pathT); string(dataT) != "first" {
+		t.Errorf("content %q", dataT)
+	}
+
+	if namesT := dirEntries(t, dirT); len(namesT) != 1 {
+		t.Errorf("files left: %v", namesT)
+	}
+
+	if err := writeFileAtomic(pathT, nil, writeString("third")); err != nil {
+		t.Fatal(err)
+	}
+
+	if dataT, _ := os.ReadFile(pathT); string(dataT) != "third" {
+		t.Errorf("content %q", dataT)
+	}
+}
+
+func TestSaveImageAsEncodeError(t *testing.T) {
+	p := NewImageTK()
+	dirT := t.TempDir()
+
+	errEncodeT := errors.New("encode failed")
+	RegisterEncoder("testfail", func(w io.Writer, img image.Image, optsA *EncodeOptions) error {
+		io.WriteString(w, "partial")
+		return errEncodeT
+	})
+
+	pathT := filepath.Join(dirT, "a.png")
+
+	err := p.SaveImageAs(image.NewGray(image.Rect(0, 0, 2, 2)), pathT, "testfail")
+	if !errors.Is(err, errEncodeT) {
+		t.Errorf("got %v, want the encode error", err)
+	}
+
+	if namesT := dirEntries(t, dirT); len(namesT) != 0 {
+		t.Errorf("files left: %v", namesT)
+	}
+
+	// the save options are passed through
+	err = p.SaveImageAs(image.NewGray(image.Rect(0, 0, 2, 2)), pathT, SaveOptions{NoOverwrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.SaveImageAs(image.NewGray(image.Rect(0, 0, 2, 2)), pathT, &SaveOptions{NoOverwrite: true})
+	if !errors.Is(err, ErrFileExists) {
+		t.Errorf("got %v, want ErrFileExists", err)
+	}
+}
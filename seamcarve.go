@@ -0,0 +1,364 @@
+package imagetk
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// energies of the masked pixels, large enough that the seams go around or through them
+const (
+	seamProtectEnergy = 1e6
+	seamRemoveEnergy  = -1e6
+)
+
+// SeamCarveOptions holds the options of SeamCarve
+type SeamCarveOptions struct {
+	// Protect marks the pixels the seams should avoid, nil means none
+	Protect image.Image
+	// Remove marks the pixels to be removed before the image is carved to the size, nil means none
+	Remove image.Image
+}
+
+// getSeamCarveOptions returns the last SeamCarveOptions or *SeamCarveOptions in the optional arguments
+func getSeamCarveOptions(optsA []interface{}) SeamCarveOptions {
+	var optsT SeamCarveOptions
+
+	for _, v := range optsA {
+		switch nv := v.(type) {
+		case SeamCarveOptions:
+			optsT = nv
+		case *SeamCarveOptions:
+			if nv != nil {
+				optsT = *nv
+			}
+		}
+	}
+
+	return optsT
+}
+
+// seamCarver holds the image being carved, the seams always run from top to bottom and the image is
+// transposed to carve the height
+type seamCarver struct {
+	w, h int
+	// premultiplied RGBA, 4*w bytes per row
+	pix []uint8
+	// 1 for protected, -1 for removed pixels
+	mask []int8
+	// the column of each pixel before the seams were removed, used to find where to insert
+	idx []int
+
+	energy []float64
+	cost   []float64
+}
+
+// newSeamCarver copies the image and its masks, the masks are aligned to the top left of the image and
+// their pixels brighter than half gray are marked
+func newSeamCarver(img image.Image, optsA SeamCarveOptions) *seamCarver {
+	boundsT := img.Bounds()
+	w, h := boundsT.Dx(), boundsT.Dy()
+
+	rgbaT := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgbaT, rgbaT.Rect, img, boundsT.Min, draw.Src)
+
+	c := &seamCarver{w: w, h: h, pix: rgbaT.Pix, mask: make([]int8, w*h)}
+
+	markT := func(maskA image.Image, v int8) {
+		if maskA == nil {
+			return
+		}
+
+		mb := maskA.Bounds()
+		for y := 0; y < minInt(h, mb.Dy()); y++ {
+			for x := 0; x < minInt(w, mb.Dx()); x++ {
+				if color.Gray16Model.Convert(maskA.At(mb.Min.X+x, mb.Min.Y+y)).(color.Gray16).Y >= 0x8000 {
+					c.mask[y*w+x] = v
+				}
+			}
+		}
+	}
+
+	markT(optsA.Protect, 1)
+	markT(optsA.Remove, -1)
+
+	return c
+}
+
+// clone returns a copy of the carver with the columns of the pixels reset
+func (c *seamCarver) clone() *seamCarver {
+	n := &seamCarver{w: c.w, h: c.h, pix: append([]uint8(nil), c.pix...), mask: append([]int8(nil), c.mask...), idx: make([]int, c.w*c.h)}
+
+	for i := range n.idx {
+		n.idx[i] = i % c.w
+	}
+
+	return n
+}
+
+// computeEnergy computes the gradient magnitude of every pixel plus the energy of its mask
+func (c *seamCarver) computeEnergy() {
+	w, h := c.w, c.h
+
+	if cap(c.energy) < w*h {
+		c.energy = make([]float64, w*h)
+	}
+	c.energy = c.energy[:w*h]
+
+	for y := 0; y < h; y++ {
+		up, down := maxInt(y-1, 0)*w, minInt(y+1, h-1)*w
+		for x := 0; x < w; x++ {
+			left, right := y*w+maxInt(x-1, 0), y*w+minInt(x+1, w-1)
+
+			var sum float64
+			for i := 0; i < 3; i++ {
+				dx := float64(c.pix[4*right+i]) - float64(c.pix[4*left+i])
+				dy := float64(c.pix[4*(down+x)+i]) - float64(c.pix[4*(up+x)+i])
+				sum += dx*dx + dy*dy
+			}
+
+			e := math.Sqrt(sum)
+			switch c.mask[y*w+x] {
+			case 1:
+				e += seamProtectEnergy
+			case -1:
+				e += seamRemoveEnergy
+			}
+
+			c.energy[y*w+x] = e
+		}
+	}
+}
+
+// findSeam returns the column of the lowest energy seam in every row
+func (c *seamCarver) findSeam() []int {
+	w, h := c.w, c.h
+
+	c.computeEnergy()
+
+	if cap(c.cost) < w*h {
+		c.cost = make([]float64, w*h)
+	}
+	c.cost = c.cost[:w*h]
+
+	copy(c.cost[:w], c.energy[:w])
+	for y := 1; y < h; y++ {
+		prevT := c.cost[(y-1)*w : y*w]
+		for x := 0; x < w; x++ {
+			m := prevT[x]
+			if x > 0 && prevT[x-1] < m {
+				m = prevT[x-1]
+			}
+			if x < w-1 && prevT[x+1] < m {
+				m = prevT[x+1]
+			}
+
+			c.cost[y*w+x] = c.energy[y*w+x] + m
+		}
+	}
+
+	seamT := make([]int, h)
+
+	lastT := c.cost[(h-1)*w:]
+	for x := 1; x < w; x++ {
+		if lastT[x] < lastT[seamT[h-1]] {
+			seamT[h-1] = x
+		}
+	}
+
+	for y := h - 2; y >= 0; y-- {
+		rowT := c.cost[y*w : (y+1)*w]
+		x := seamT[y+1]
+		bestT := x
+		if x > 0 && rowT[x-1] < rowT[bestT] {
+			bestT = x - 1
+		}
+		if x < w-1 && rowT[x+1] < rowT[bestT] {
+			bestT = x + 1
+		}
+
+		seamT[y] = bestT
+	}
+
+	return seamT
+}
+
+// removeSeam removes the pixel of the seam from every row
+func (c *seamCarver) removeSeam(seamA []int) {
+	w, h := c.w, c.h
+
+	// the rows only move towards the start, so they can be compacted in place
+	for y := 0; y < h; y++ {
+		s, d, x := y*w, y*(w-1), seamA[y]
+
+		copy(c.pix[4*d:], c.pix[4*s:4*(s+x)])
+		copy(c.pix[4*(d+x):], c.pix[4*(s+x+1):4*(s+w)])
+
+		copy(c.mask[d:], c.mask[s:s+x])
+		copy(c.mask[d+x:], c.mask[s+x+1:s+w])
+
+		if c.idx != nil {
+			copy(c.idx[d:], c.idx[s:s+x])
+			copy(c.idx[d+x:], c.idx[s+x+1:s+w])
+		}
+	}
+
+	c.w--
+	c.pix = c.pix[:4*c.w*h]
+	c.mask = c.mask[:c.w*h]
+	if c.idx != nil {
+		c.idx = c.idx[:c.w*h]
+	}
+}
+
+// insertSeams widens the image by the count of seams, duplicating the seams a copy would remove first.
+// At most half of the width is inserted at once, so the same seam is not stretched over and over.
+func (c *seamCarver) insertSeams(countA int) {
+	for countA > 0 {
+		n := minInt(countA, maxInt(1, c.w/2))
+
+		copyT := c.clone()
+		marksT := make([]bool, c.w*c.h)
+		for i := 0; i < n && copyT.w > 0; i++ {
+			seamT := copyT.findSeam()
+			for y, x := range seamT {
+				marksT[y*c.w+copyT.idx[y*copyT.w+x]] = true
+			}
+
+			copyT.removeSeam(seamT)
+		}
+
+		nw := c.w + n
+		pixT := make([]uint8, 4*nw*c.h)
+		maskT := make([]int8, nw*c.h)
+
+		for y := 0; y < c.h; y++ {
+			d := y * nw
+			for x := 0; x < c.w; x++ {
+				s := y*c.w + x
+
+				copy(pixT[4*d:4*d+4], c.pix[4*s:4*s+4])
+				maskT[d] = c.mask[s]
+				d++
+
+				if !marksT[s] {
+					continue
+				}
+
+				// the new pixel is the average of the seam and its right neighbour
+				o := y*c.w + minInt(x+1, c.w-1)
+				if x == c.w-1 {
+					o = y*c.w + maxInt(x-1, 0)
+				}
+				for i := 0; i < 4; i++ {
+					pixT[4*d+i] = uint8((uint32(c.pix[4*s+i]) + uint32(c.pix[4*o+i]) + 1) / 2)
+				}
+				maskT[d] = c.mask[s]
+				d++
+			}
+		}
+
+		c.w, c.pix, c.mask = nw, pixT, maskT
+		countA -= n
+	}
+}
+
+// carveWidth removes or inserts seams until the image is of the width
+func (c *seamCarver) carveWidth(widthA int) {
+	for c.w > widthA {
+		c.removeSeam(c.findSeam())
+	}
+
+	if c.w < widthA {
+		c.insertSeams(widthA - c.w)
+	}
+}
+
+// transpose swaps the rows and columns of the image
+func (c *seamCarver) transpose() {
+	w, h := c.w, c.h
+
+	pixT := make([]uint8, len(c.pix))
+	maskT := make([]int8, len(c.mask))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			copy(pixT[4*(x*h+y):4*(x*h+y)+4], c.pix[4*(y*w+x):4*(y*w+x)+4])
+			maskT[x*h+y] = c.mask[y*w+x]
+		}
+	}
+
+	c.w, c.h, c.pix, c.mask = h, w, pixT, maskT
+}
+
+// removeMarked removes seams until no pixel marked for removal is left, carving the width if the marked area
+// is narrower than it is high and the height otherwise
+func (c *seamCarver) removeMarked() {
+	rectT := image.Rectangle{}
+	for i, v := range c.mask {
+		if v < 0 {
+			rectT = rectT.Union(image.Rect(i%c.w, i/c.w, i%c.w+1, i/c.w+1))
+		}
+	}
+
+	if rectT.Empty() {
+		return
+	}
+
+	transposedT := rectT.Dy() < rectT.Dx()
+	if transposedT {
+		c.transpose()
+	}
+
+	for c.w > 1 && c.hasMarked() {
+		c.removeSeam(c.findSeam())
+	}
+
+	if transposedT {
+		c.transpose()
+	}
+}
+
+// hasMarked reports whether any pixel is marked for removal
+func (c *seamCarver) hasMarked() bool {
+	for _, v := range c.mask {
+		if v < 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SeamCarve resizes the image to the size by removing or inserting the seams of the lowest energy (gradient magnitude),
+// so the content is kept instead of distorted. 0 for the width or height means the size after the removal.
+// Optional SeamCarveOptions or *SeamCarveOptions give the masks of the pixels to protect or remove.
+func (p *ImageTK) SeamCarve(img image.Image, widthA, heightA int, optsA ...interface{}) image.Image {
+	boundsT := img.Bounds()
+	if boundsT.Empty() {
+		return img
+	}
+
+	c := newSeamCarver(img, getSeamCarveOptions(optsA))
+
+	c.removeMarked()
+
+	if widthA <= 0 {
+		widthA = c.w
+	}
+
+	if heightA <= 0 {
+		heightA = c.h
+	}
+
+	c.carveWidth(widthA)
+
+	if c.h != heightA {
+		c.transpose()
+		c.carveWidth(heightA)
+		c.transpose()
+	}
+
+	return &image.RGBA{Pix: c.pix, Stride: 4 * c.w, Rect: image.Rect(0, 0, c.w, c.h)}
+}
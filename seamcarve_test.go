@@ -0,0 +1,220 @@
+package imagetk
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// findBlock returns where the block of the source is found unchanged in the image, false if not found
+func findBlock(img *image.RGBA, src *image.RGBA, blockA image.Rectangle) (image.Point, bool) {
+	b := img.Bounds()
+
+	for y := b.Min.Y; y+blockA.Dy() <= b.Max.Y; y++ {
+		for x := b.Min.X; x+blockA.Dx() <= b.Max.X; x++ {
+			if sameBlock(img, image.Pt(x, y), src, blockA) {
+				return image.Pt(x, y), true
+			}
+		}
+	}
+
+	return image.Point{}, false
+}
+
+func sameBlock(img *image.RGBA, posA image.Point, src *image.RGBA, blockA image.Rectangle) bool {
+	for y := 0; y < blockA.Dy(); y++ {
+		for x := 0; x < blockA.Dx(); x++ {
+			if img.RGBAAt(posA.X+x, posA.Y+y) != src.RGBAAt(blockA.Min.X+x, blockA.Min.Y+y) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// maskOf returns a mask of the image size with the rectangle marked
+func maskOf(w, h int, rectA image.Rectangle) *image.Gray {
+	maskT := image.NewGray(image.Rect(0, 0, w, h))
+
+	for y := rectA.Min.Y; y < rectA.Max.Y; y++ {
+		for x := rectA.Min.X; x < rectA.Max.X; x++ {
+			maskT.SetGray(x, y, color.Gray{255})
+		}
+	}
+
+	return maskT
+}
+
+func TestSeamCarveSize(t *testing.T) {
+	p := NewImageTK()
+	src := noiseRGBA(40, 30, 1)
+
+	tests := []struct {
+		name string
+		w, h int
+	}{
+		{"narrower", 28, 30},
+		{"wider", 55, 30},
+		{"lower", 40, 21},
+		{"higher", 40, 44},
+		{"narrower and higher", 25, 40},
+		{"wider and lower", 70, 18},
+		{"more than doubled", 90, 65},
+		{"same", 40, 30},
+		{"keep height", 33, 0},
+		{"keep width", 0, 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantT := image.Pt(tt.w, tt.h)
+			if wantT.X == 0 {
+				wantT.X = 40
+			}
+			if wantT.Y == 0 {
+				wantT.Y = 30
+			}
+
+			got := p.SeamCarve(src, tt.w, tt.h)
+			if got.Bounds() != (image.Rectangle{Max: wantT}) {
+				t.Fatalf("bounds %v, want size %v", got.Bounds(), wantT)
+			}
+		})
+	}
+
+	// an offset sub-image is carved from its own pixels
+	subT := src.SubImage(image.Rect(10, 5, 40, 30))
+	if got := p.SeamCarve(subT, 20, 20); got.Bounds() != image.Rect(0, 0, 20, 20) {
+		t.Errorf("sub-image carved to %v", got.Bounds())
+	}
+
+	if got := p.SeamCarve(subT, 30, 25); !equalPixels(got, subT) {
+		t.Error("sub-image changed without carving")
+	}
+}
+
+func TestSeamCarveProtect(t *testing.T) {
+	p := NewImageTK()
+	src := noiseRGBA(40, 30, 2)
+	blockT := image.Rect(12, 8, 24, 20)
+
+	optsT := SeamCarveOptions{Protect: maskOf(40, 30, blockT)}
+
+	for _, size := range []image.Point{{26, 30}, {40, 19}, {27, 20}, {60, 45}} {
+		got := p.SeamCarve(src, size.X, size.Y, optsT).(*image.RGBA)
+
+		if got.Bounds().Size() != size {
+			t.Fatalf("%v: size %v", size, got.Bounds().Size())
+		}
+
+		if _, ok := findBlock(got, src, blockT); !ok {
+			t.Errorf("%v: the protected block is changed", size)
+		}
+	}
+
+	// without protection the noise in the block is carved as well
+	if _, ok := findBlock(p.SeamCarve(src, 27, 20).(*image.RGBA), src, blockT); ok {
+		t.Error("the block is kept without protection")
+	}
+}
+
+func TestSeamCarveRemove(t *testing.T) {
+	p := NewImageTK()
+
+	tests := []struct {
+		name  string
+		rect  image.Rectangle
+		wantW int
+		wantH int
+	}{
+		// narrower than high, the width is carved
+		{"tall", image.Rect(10, 4, 16, 26), 34, 30},
+		// wider than high, the height is carved
+		{"wide", image.Rect(5, 12, 35, 16), 40, 26},
+	}
+
+	magentaT := color.RGBA{255, 0, 255, 255}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := noiseRGBA(40, 30, 3)
+
+			// a color found nowhere else
+			for i := range src.Pix {
+				if i%4 == 1 {
+					src.Pix[i] |= 1
+				}
+			}
+
+			for y := tt.rect.Min.Y; y < tt.rect.Max.Y; y++ {
+				for x := tt.rect.Min.X; x < tt.rect.Max.X; x++ {
+					src.SetRGBA(x, y, magentaT)
+				}
+			}
+
+			optsT := &SeamCarveOptions{Remove: maskOf(40, 30, tt.rect)}
+
+			for _, size := range []image.Point{{0, 0}, {40, 30}, {30, 24}} {
+				got := p.SeamCarve(src, size.X, size.Y, optsT).(*image.RGBA)
+
+				wantT := size
+				if size == (image.Point{}) {
+					wantT = image.Pt(tt.wantW, tt.wantH)
+				}
+
+				if got.Bounds().Size() != wantT {
+					t.Fatalf("%v: size %v, want %v", size, got.Bounds().Size(), wantT)
+				}
+
+				for y := 0; y < wantT.Y; y++ {
+					for x := 0; x < wantT.X; x++ {
+						if got.RGBAAt(x, y) == magentaT {
+							t.Fatalf("%v: the removed pixel (%v,%v) is left", size, x, y)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSeamCarveThin(t *testing.T) {
+	p := NewImageTK()
+
+	tests := []struct {
+		name string
+		src  image.Point
+		dst  image.Point
+	}{
+		{"column shorter", image.Pt(1, 20), image.Pt(1, 8)},
+		{"column higher", image.Pt(1, 20), image.Pt(1, 50)},
+		{"column wider", image.Pt(1, 20), image.Pt(4, 20)},
+		{"row narrower", image.Pt(20, 1), image.Pt(8, 1)},
+		{"row wider", image.Pt(20, 1), image.Pt(50, 1)},
+		{"row higher", image.Pt(20, 1), image.Pt(20, 3)},
+		{"pixel", image.Pt(1, 1), image.Pt(3, 2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := noiseRGBA(tt.src.X, tt.src.Y, 4)
+
+			if got := p.SeamCarve(src, tt.dst.X, tt.dst.Y); got.Bounds().Size() != tt.dst {
+				t.Errorf("size %v, want %v", got.Bounds().Size(), tt.dst)
+			}
+
+			// removing the only column or row stops at one pixel
+			all := image.Rectangle{Max: tt.src}
+			got := p.SeamCarve(src, 0, 0, SeamCarveOptions{Remove: maskOf(tt.src.X, tt.src.Y, all)})
+
+			if s := got.Bounds().Size(); s.X < 1 || s.Y < 1 || s.X > tt.src.X || s.Y > tt.src.Y {
+				t.Errorf("removed to %v", s)
+			}
+		})
+	}
+
+	if got := p.SeamCarve(image.NewRGBA(image.Rect(3, 3, 3, 3)), 5, 5); !got.Bounds().Empty() {
+		t.Errorf("empty image carved to %v", got.Bounds())
+	}
+}
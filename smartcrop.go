@@ -0,0 +1,267 @@
+package imagetk
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// weights of the smart crop features and the size of the analysis image
+const (
+	smartCropEdgeWeight       = 0.2
+	smartCropSaturationWeight = 0.3
+	smartCropSkinWeight       = 1.8
+	smartCropAnalysisSize     = 256
+	smartCropSteps            = 32
+)
+
+// cropAnalysis holds the feature maps of the downscaled image and the integral image of their combined score
+type cropAnalysis struct {
+	w, h int
+	// analysis pixels per source pixel
+	scale float64
+
+	edge       []float64
+	saturation []float64
+	skin       []float64
+
+	// (w+1)*(h+1) summed-area table of the score
+	integral []float64
+}
+
+// analyzeCrop computes the feature maps of the image
+func analyzeCrop(img image.Image) *cropAnalysis {
+	boundsT := img.Bounds()
+	w, h := boundsT.Dx(), boundsT.Dy()
+
+	a := &cropAnalysis{w: w, h: h, scale: 1}
+
+	if m := maxInt(w, h); m > smartCropAnalysisSize {
+		a.scale = float64(smartCropAnalysisSize) / float64(m)
+		a.w, a.h = scaleSize(w, a.scale, smartCropAnalysisSize), scaleSize(h, a.scale, smartCropAnalysisSize)
+		img = NewResizer(w, h, a.w, a.h, Box).resize(img, nil, nil)
+	}
+
+	rgbaT := image.NewRGBA(image.Rect(0, 0, a.w, a.h))
+	draw.Draw(rgbaT, rgbaT.Rect, img, img.Bounds().Min, draw.Src)
+
+	n := a.w * a.h
+	lumT := make([]float64, n)
+	a.edge = make([]float64, n)
+	a.saturation = make([]float64, n)
+	a.skin = make([]float64, n)
+
+	for y := 0; y < a.h; y++ {
+		for x := 0; x < a.w; x++ {
+			s := rgbaT.Pix[y*rgbaT.Stride+4*x:]
+			r, g, b := float64(s[0])/255, float64(s[1])/255, float64(s[2])/255
+			i := y*a.w + x
+
+			lumT[i] = 0.2126*r + 0.7152*g + 0.0722*b
+			a.saturation[i] = saturationScore(r, g, b)
+			a.skin[i] = skinScore(r, g, b)
+		}
+	}
+
+	lumAt := func(x, y int) float64 {
+		return lumT[edgeIndex(y, a.h, EdgeClamp)*a.w+edgeIndex(x, a.w, EdgeClamp)]
+	}
+
+	scoreT := make([]float64, n)
+	for y := 0; y < a.h; y++ {
+		for x := 0; x < a.w; x++ {
+			i := y*a.w + x
+
+			a.edge[i] = math.Min(math.Abs(4*lumT[i]-lumAt(x-1, y)-lumAt(x+1, y)-lumAt(x, y-1)-lumAt(x, y+1)), 1)
+
+			scoreT[i] = a.edge[i]*smartCropEdgeWeight + a.saturation[i]*smartCropSaturationWeight + a.skin[i]*smartCropSkinWeight
+		}
+	}
+
+	a.integral = make([]float64, (a.w+1)*(a.h+1))
+	for y := 0; y < a.h; y++ {
+		var rowSum float64
+		for x := 0; x < a.w; x++ {
+			rowSum += scoreT[y*a.w+x]
+			a.integral[(y+1)*(a.w+1)+x+1] = a.integral[y*(a.w+1)+x+1] + rowSum
+		}
+	}
+
+	return a
+}
+
+// saturationScore is the HSL saturation of colors neither too dark nor too bright, 0 for dull colors
+func saturationScore(r, g, b float64) float64 {
+	mx, mn := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	l := (mx + mn) / 2
+
+	if mx == mn || l < 0.05 || l > 0.9 {
+		return 0
+	}
+
+	var s float64
+	if l > 0.5 {
+		s = (mx - mn) / (2 - mx - mn)
+	} else {
+		s = (mx - mn) / (mx + mn)
+	}
+
+	if s < 0.4 {
+		return 0
+	}
+
+	return (s - 0.4) / 0.6
+}
+
+// skinScore is how close the color direction is to a typical skin tone
+func skinScore(r, g, b float64) float64 {
+	mag := math.Sqrt(r*r + g*g + b*b)
+	l := (math.Max(r, math.Max(g, b)) + math.Min(r, math.Min(g, b))) / 2
+
+	if mag == 0 || l < 0.2 || l > 0.85 {
+		return 0
+	}
+
+	rd, gd, bd := r/mag-0.78, g/mag-0.57, b/mag-0.44
+
+	s := 1 - math.Sqrt(rd*rd+gd*gd+bd*bd)
+	if s < 0.8 {
+		return 0
+	}
+
+	return (s - 0.8) / 0.2
+}
+
+// sum returns the score of the rectangle in analysis pixels
+func (a *cropAnalysis) sum(x0, y0, x1, y1 int) float64 {
+	s := a.w + 1
+
+	return a.integral[y1*s+x1] - a.integral[y0*s+x1] - a.integral[y1*s+x0] + a.integral[y0*s+x0]
+}
+
+// bestWindow returns the origin in source pixels of the best window of the size in source pixels
+func (a *cropAnalysis) bestWindow(srcWidthA, srcHeightA, cwA, chA int) image.Point {
+	aw := minInt(a.w, maxInt(1, int(math.Round(float64(cwA)*a.scale))))
+	ah := minInt(a.h, maxInt(1, int(math.Round(float64(chA)*a.scale))))
+
+	freeX, freeY := a.w-aw, a.h-ah
+	stepX, stepY := maxInt(1, freeX/smartCropSteps), maxInt(1, freeY/smartCropSteps)
+
+	// the inner part counts twice, so the content is kept away from the edges of the crop
+	mx, my := aw/5, ah/5
+
+	bestT := image.Point{freeX / 2, freeY / 2}
+	bestScore := math.Inf(-1)
+
+	for y := 0; y <= freeY; y = nextWindowPos(y, stepY, freeY) {
+		for x := 0; x <= freeX; x = nextWindowPos(x, stepX, freeX) {
+			s := a.sum(x, y, x+aw, y+ah) + a.sum(x+mx, y+my, x+aw-mx, y+ah-my)
+
+			// prefer the center slightly when the scores are equal
+			dx, dy := float64(x-freeX/2), float64(y-freeY/2)
+			s -= 1e-9 * (dx*dx + dy*dy)
+
+			if s > bestScore {
+				bestScore, bestT = s, image.Point{x, y}
+			}
+		}
+	}
+
+	// the free range of the analysis image is mapped onto the free range of the source, so the ends stay the ends
+	// although the window size is rounded in analysis pixels
+	return image.Point{mapWindowPos(bestT.X, freeX, srcWidthA-cwA), mapWindowPos(bestT.Y, freeY, srcHeightA-chA)}
+}
+
+// mapWindowPos maps the position in [0, freeA] of the analysis image onto [0, srcFreeA] of the source
+func mapWindowPos(posA, freeA, srcFreeA int) int {
+	if freeA < 1 || srcFreeA < 1 {
+		return maxInt(0, srcFreeA) / 2
+	}
+
+	return int(math.Round(float64(posA) * float64(srcFreeA) / float64(freeA)))
+}
+
+// nextWindowPos returns the next position of the window search, the last step is shortened to end at freeA
+// so a subject flush against the right or bottom edge is not cut off
+func nextWindowPos(posA, stepA, freeA int) int {
+	if posA < freeA && posA+stepA > freeA {
+		return freeA
+	}
+
+	return posA + stepA
+}
+
+// smartCropRect returns the largest rectangle with the aspect ratio of the size, and the analysis used
+func smartCropRect(img image.Image, widthA, heightA int) (image.Rectangle, *cropAnalysis) {
+	boundsT := img.Bounds()
+	w, h := boundsT.Dx(), boundsT.Dy()
+
+	if w < 1 || h < 1 || widthA < 1 || heightA < 1 {
+		return boundsT, nil
+	}
+
+	scaleT := math.Min(float64(w)/float64(widthA), float64(h)/float64(heightA))
+	cw, ch := scaleSize(widthA, scaleT, w), scaleSize(heightA, scaleT, h)
+
+	a := analyzeCrop(img)
+	originT := a.bestWindow(w, h, cw, ch).Add(boundsT.Min)
+
+	return image.Rect(originT.X, originT.Y, originT.X+cw, originT.Y+ch), a
+}
+
+// SmartCrop returns the largest crop rectangle of the image with the aspect ratio of the size, placed where the edges,
+// saturated colors and skin tones are. The rectangle is in the coordinates of the image.
+func (p *ImageTK) SmartCrop(img image.Image, widthA, heightA int) image.Rectangle {
+	rectT, _ := smartCropRect(img, widthA, heightA)
+
+	return rectT
+}
+
+// SmartCropDebug returns the same rectangle as SmartCrop and an image of the scores over the dimmed image,
+// edges in green, saturation in blue and skin in red, with the crop outlined in yellow
+func (p *ImageTK) SmartCropDebug(img image.Image, widthA, heightA int) (image.Rectangle, image.Image) {
+	rectT, a := smartCropRect(img, widthA, heightA)
+
+	boundsT := img.Bounds()
+	outT := image.NewRGBA(image.Rect(0, 0, boundsT.Dx(), boundsT.Dy()))
+	draw.Draw(outT, outT.Rect, img, boundsT.Min, draw.Src)
+
+	if a == nil {
+		return rectT, outT
+	}
+
+	for y := 0; y < outT.Rect.Dy(); y++ {
+		ay := minInt(a.h-1, int(float64(y)*a.scale))
+		for x := 0; x < outT.Rect.Dx(); x++ {
+			ax := minInt(a.w-1, int(float64(x)*a.scale))
+			i := ay*a.w + ax
+
+			o := outT.Pix[y*outT.Stride+4*x:]
+			o[0] = uint8(math.Min(255, float64(o[0])/2+a.skin[i]*127))
+			o[1] = uint8(math.Min(255, float64(o[1])/2+a.edge[i]*127))
+			o[2] = uint8(math.Min(255, float64(o[2])/2+a.saturation[i]*127))
+		}
+	}
+
+	outlineT := rectT.Sub(boundsT.Min)
+	yellowT := color.RGBA{255, 255, 0, 255}
+	for x := outlineT.Min.X; x < outlineT.Max.X; x++ {
+		outT.SetRGBA(x, outlineT.Min.Y, yellowT)
+		outT.SetRGBA(x, outlineT.Max.Y-1, yellowT)
+	}
+	for y := outlineT.Min.Y; y < outlineT.Max.Y; y++ {
+		outT.SetRGBA(outlineT.Min.X, y, yellowT)
+		outT.SetRGBA(outlineT.Max.X-1, y, yellowT)
+	}
+
+	return rectT, outT
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,87 @@
+package imagetk
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// subjectImage returns a gray image with a saturated red subject in the rectangle
+func subjectImage(w, h int, subjectA image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Rect, image.NewUniform(color.RGBA{128, 128, 128, 255}), image.Point{}, draw.Src)
+	draw.Draw(img, subjectA, image.NewUniform(color.RGBA{230, 20, 20, 255}), image.Point{}, draw.Src)
+
+	return img
+}
+
+func TestSmartCropKeepsSubject(t *testing.T) {
+	p := NewImageTK()
+
+	// the free space of the analysis image is not a multiple of the search step, the last position must be tried
+	tests := []struct {
+		name    string
+		w, h    int
+		subject image.Rectangle
+	}{
+		{"left", 1000, 300, image.Rect(0, 140, 12, 160)},
+		{"right", 1000, 300, image.Rect(988, 140, 1000, 160)},
+		{"top", 300, 1000, image.Rect(140, 0, 160, 12)},
+		{"bottom", 300, 1000, image.Rect(140, 988, 160, 1000)},
+		{"center", 1000, 300, image.Rect(600, 140, 620, 160)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := subjectImage(tt.w, tt.h, tt.subject)
+
+			got := p.SmartCrop(img, 100, 100)
+			if got.Dx() != 300 || got.Dy() != 300 || !got.In(img.Rect) {
+				t.Fatalf("crop %v", got)
+			}
+
+			if !tt.subject.In(got) {
+				t.Errorf("crop %v cuts the subject %v", got, tt.subject)
+			}
+
+			debugRectT, debugT := p.SmartCropDebug(img, 100, 100)
+			if debugRectT != got || debugT.Bounds() != image.Rect(0, 0, tt.w, tt.h) {
+				t.Errorf("debug crop %v of %v", debugRectT, debugT.Bounds())
+			}
+		})
+	}
+}
+
+func TestSmartCropOffsetImage(t *testing.T) {
+	p := NewImageTK()
+
+	img := subjectImage(1000, 300, image.Rect(988, 140, 1000, 160)).SubImage(image.Rect(200, 0, 1000, 300))
+
+	got := p.SmartCrop(img, 1, 1)
+	if got != image.Rect(700, 0, 1000, 300) {
+		t.Errorf("crop %v", got)
+	}
+}
+
+func TestThumbnailSmartCrop(t *testing.T) {
+	p := NewImageTK()
+	img := subjectImage(1000, 300, image.Rect(988, 140, 1000, 160))
+
+	got := p.Thumbnail(100, 100, img, ThumbnailOptions{Mode: ThumbnailCover, Gravity: CENTER, SmartCrop: true})
+	if got.Bounds() != image.Rect(0, 0, 100, 100) {
+		t.Fatalf("bounds %v", got.Bounds())
+	}
+
+	// the subject is 4 thumbnail pixels wide at the right edge
+	r, g, _, _ := got.At(98, 50).RGBA()
+	if r>>8 < 200 || g>>8 > 60 {
+		t.Errorf("pixel at the right edge is %v", got.At(98, 50))
+	}
+
+	// without SmartCrop the center is kept
+	got = p.Thumbnail(100, 100, img, ThumbnailOptions{Mode: ThumbnailCover, Gravity: CENTER})
+	if r, _, _, _ := got.At(98, 50).RGBA(); r>>8 != 128 {
+		t.Errorf("pixel at the right edge is %v", got.At(98, 50))
+	}
+}
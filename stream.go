@@ -0,0 +1,280 @@
+package imagetk
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+)
+
+// ErrStreamOptions is returned by ResizeStream for a Resizer using an edge mode other than EdgeClamp or Prereduce
+var ErrStreamOptions = errors.New("edge mode and Prereduce are not supported by streaming")
+
+// streamStripRows is the number of rows read from a RowSource or written to a RowSink at once
+const streamStripRows = 16
+
+// RowSource reads an image from top to bottom in strips, such as the rows of a file being decoded
+type RowSource interface {
+	// Size returns the width and height of the image
+	Size() (int, int)
+	// ReadRows fills the strip with the next rows as premultiplied 16-bit RGBA, the strip is as wide as the image
+	// and its bounds are the rows to read
+	ReadRows(stripA *image.RGBA64) error
+}
+
+// RowSink writes an image from top to bottom in strips, such as the rows of a file being encoded
+type RowSink interface {
+	// Start is called with the size of the image before any rows are written
+	Start(widthA, heightA int) error
+	// WriteRows writes the next rows in the strip, its bounds are the rows of the image
+	WriteRows(stripA *image.RGBA64) error
+	// Finish is called after the last row is written
+	Finish() error
+}
+
+// wideRowSource is implemented by the sources knowing whether the image has 16-bit samples
+type wideRowSource interface {
+	wide() bool
+}
+
+// ResizeStream resizes the rows of the source into the sink, holding only a strip of the source and
+// the rows covered by the vertical filter, so the images need not fit in memory.
+// The source must have the source size the Resizer is configured with, and the Resizer must not use
+// an edge mode other than EdgeClamp or Prereduce, since the rows are neither padded nor reduced.
+func (r *Resizer) ResizeStream(dstA RowSink, srcA RowSource) error {
+	sw, sh := srcA.Size()
+	if sw != r.srcWidth || sh != r.srcHeight {
+		return fmt.Errorf("%w: source %vx%v, expected %vx%v", ErrSizeMismatch, sw, sh, r.srcWidth, r.srcHeight)
+	}
+
+	if r.reducer != nil || r.padX != [2]int{} || r.padY != [2]int{} {
+		return ErrStreamOptions
+	}
+
+	dw, dh := r.dstWidth, r.dstHeight
+
+	errT := dstA.Start(dw, dh)
+	if errT != nil {
+		return errT
+	}
+
+	if dw < 1 || dh < 1 || sw < 1 || sh < 1 {
+		return dstA.Finish()
+	}
+
+	if r.gamma == GammaSRGB {
+		srgbTablesOnceG.Do(initSRGBTables)
+	}
+
+	wx, wy := r.weights(true)
+	flT := wy.filterLength
+
+	stripT := image.NewRGBA64(image.Rect(0, 0, sw, streamStripRows))
+	tempT := image.NewRGBA64(image.Rect(0, 0, streamStripRows, dw))
+	outT := image.NewRGBA64(image.Rect(0, 0, dw, streamStripRows))
+
+	// the horizontally filtered rows [winLo, winLo+winN) covered by the vertical filter, transposed like the
+	// temporary image of resizeEncoded so the vertical pass is the same as the horizontal one
+	winCap := flT + streamStripRows
+	winT := image.NewRGBA64(image.Rect(0, 0, winCap, dw))
+	winLo, winN := 0, 0
+
+	// the offsets of the vertical filter relative to the window
+	offsetT := make([]int, dh)
+
+	// the rows the output row needs, at least one
+	needRows := func(y int) int {
+		return maxInt(1, minInt(wy.offset[y]+flT, sh))
+	}
+
+	readT, yNext := 0, 0
+
+	for yNext < dh {
+		if winLo+winN < needRows(yNext) {
+			// drop the rows above the filter of the next output row
+			dropT := minInt(maxInt(minInt(wy.offset[yNext], sh-1), 0)-winLo, winN)
+			if dropT > 0 {
+				for j := 0; j < dw; j++ {
+					rowT := winT.Pix[j*winT.Stride:]
+					copy(rowT[:8*(winN-dropT)], rowT[8*dropT:8*winN])
+				}
+
+				winLo, winN = winLo+dropT, winN-dropT
+			}
+
+			n := minInt(streamStripRows, sh-readT)
+
+			inT := &image.RGBA64{Pix: stripT.Pix[:n*stripT.Stride], Stride: stripT.Stride, Rect: image.Rect(0, readT, sw, readT+n)}
+			errT = srcA.ReadRows(inT)
+			if errT != nil {
+				return errT
+			}
+
+			readT += n
+
+			if r.gamma == GammaSRGB {
+				r.rgbaToLinear(inT, inT)
+			}
+
+			// horizontal filter, results in transposed temporary image
+			hT := tempT.SubImage(image.Rect(0, 0, n, dw)).(*image.RGBA64)
+			r.runParallel(hT, func(slice image.Image) {
+				if r.nearest {
+					nearestRGBA64(inT, slice.(*image.RGBA64), r.scaleX, wx.coeffsNearest, wx.offset, wx.filterLength)
+				} else {
+					resizeRGBA64(inT, slice.(*image.RGBA64), r.scaleX, wx.coeffs16, wx.offset, wx.filterLength)
+				}
+			})
+
+			for j := 0; j < dw; j++ {
+				copy(winT.Pix[j*winT.Stride+8*winN:], hT.Pix[j*hT.Stride:j*hT.Stride+8*n])
+			}
+
+			winN += n
+
+			continue
+		}
+
+		y1 := yNext
+		for y1 < dh && y1-yNext < streamStripRows && needRows(y1) <= winLo+winN {
+			offsetT[y1] = wy.offset[y1] - winLo
+			y1++
+		}
+
+		// horizontal filter on transposed window, result is not transposed,
+		// the bounds of the result are the output rows so the weights are indexed by them
+		vT := &image.RGBA64{Pix: outT.Pix[:(y1-yNext)*outT.Stride], Stride: outT.Stride, Rect: image.Rect(0, yNext, dw, y1)}
+		inT := &image.RGBA64{Pix: winT.Pix, Stride: winT.Stride, Rect: image.Rect(0, 0, winN, dw)}
+		r.runParallel(vT, func(slice image.Image) {
+			if r.nearest {
+				nearestRGBA64(inT, slice.(*image.RGBA64), r.scaleY, wy.coeffsNearest, offsetT, flT)
+			} else {
+				resizeRGBA64(inT, slice.(*image.RGBA64), r.scaleY, wy.coeffs16, offsetT, flT)
+			}
+		})
+
+		if r.gamma == GammaSRGB {
+			r.linearToRGBA(vT, vT)
+		}
+
+		errT = dstA.WriteRows(vT)
+		if errT != nil {
+			return errT
+		}
+
+		yNext = y1
+	}
+
+	return dstA.Finish()
+}
+
+// StreamResize resizes the rows of the source into the sink without holding the images in memory,
+// 0 for width or height keeps the aspect ratio. Optional arguments are the same as ResizeImage,
+// except that EdgeClamp is always used and Prereduce is turned off, Area is the fastest for large reductions.
+func (p *ImageTK) StreamResize(dstA RowSink, srcA RowSource, widthA, heightA int, optsA ...interface{}) error {
+	sw, sh := srcA.Size()
+
+	optsT := getResizeOptions(optsA)
+	optsT.Edge, optsT.Prereduce = EdgeClamp, false
+
+	return NewResizer(sw, sh, widthA, heightA, optsT).ResizeStream(dstA, srcA)
+}
+
+// openRowSource returns the PNG or netpbm row source of the stream
+func openRowSource(r io.Reader) (RowSource, error) {
+	br := bufio.NewReader(r)
+
+	magicT, errT := br.Peek(2)
+	if errT != nil {
+		return nil, errT
+	}
+
+	switch {
+	case magicT[0] == pngSignature[0] && magicT[1] == pngSignature[1]:
+		return newPNGRowSource(br)
+	case magicT[0] == 'P' && magicT[1] >= '1' && magicT[1] <= '7':
+		return newNetpbmRowSource(br)
+	}
+
+	return nil, fmt.Errorf("%w: only PNG and netpbm could be streamed", ErrUnsupportedFormat)
+}
+
+// StreamResizeFile resizes the PNG or netpbm(PBM/PGM/PPM/PAM) file row by row into the PNG or netpbm file,
+// so huge images are resized with bounded memory. The output format is chosen the same way as SaveImageAs,
+// 16-bit sources result in 16-bit output. 0 for width or height keeps the aspect ratio.
+// Optional arguments could be the options of StreamResize and SaveImageAs.
+func (p *ImageTK) StreamResizeFile(dstPathA, srcPathA string, widthA, heightA int, optsA ...interface{}) error {
+	argsT := getSaveArgs(dstPathA, optsA)
+
+	switch argsT.format {
+	case "png", "pbm", "pgm", "ppm", "pam":
+	default:
+		return fmt.Errorf("%w: only PNG and netpbm could be streamed, not %v", ErrUnsupportedFormat, argsT.format)
+	}
+
+	file, errT := os.Open(srcPathA)
+	if errT != nil {
+		return errT
+	}
+	defer file.Close()
+
+	srcT, errT := openRowSource(file)
+	if errT != nil {
+		return errT
+	}
+
+	wideT := false
+	if v, ok := srcT.(wideRowSource); ok {
+		wideT = v.wide()
+	}
+
+	return writeFileAtomic(dstPathA, argsT.saveOpts, func(w io.Writer) error {
+		var dstT RowSink
+
+		if argsT.format == "png" {
+			dstT = p.NewPNGRowSink(w, wideT, argsT.encodeOpts)
+		} else {
+			dstT, errT = p.NewNetpbmRowSink(w, argsT.format, wideT, argsT.encodeOpts)
+			if errT != nil {
+				return errT
+			}
+		}
+
+		return p.StreamResize(dstT, srcT, widthA, heightA, optsA...)
+	})
+}
+
+// putPremultipliedRow writes the 16-bit non-premultiplied RGBA samples to the row of an RGBA64 image
+func putPremultipliedRow(dstA []uint8, rowA []uint16) {
+	for i := 0; i+3 < len(rowA); i += 4 {
+		a := uint32(rowA[i+3])
+		for c := 0; c < 3; c++ {
+			v := uint32(rowA[i+c]) * a / 0xffff
+			dstA[2*(i+c)+0] = uint8(v >> 8)
+			dstA[2*(i+c)+1] = uint8(v)
+		}
+		dstA[2*i+6] = uint8(a >> 8)
+		dstA[2*i+7] = uint8(a)
+	}
+}
+
+// getUnpremultipliedRow reads the row of an RGBA64 image as 16-bit non-premultiplied RGBA samples
+func getUnpremultipliedRow(rowA []uint16, srcA []uint8) {
+	for i := 0; i+3 < len(rowA); i += 4 {
+		a := uint32(srcA[2*i+6])<<8 | uint32(srcA[2*i+7])
+		rowA[i+3] = uint16(a)
+
+		if a == 0 {
+			rowA[i+0], rowA[i+1], rowA[i+2] = 0, 0, 0
+			continue
+		}
+
+		for c := 0; c < 3; c++ {
+			// filter overshoot could leave the color above the alpha
+			v := minUint32(uint32(srcA[2*(i+c)])<<8|uint32(srcA[2*(i+c)+1]), a)
+			rowA[i+c] = uint16((v*0xffff + a/2) / a)
+		}
+	}
+}
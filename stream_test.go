@@ -0,0 +1,280 @@
+package imagetk
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+// memRowSource reads the rows of an RGBA64 image
+type memRowSource struct {
+	img *image.RGBA64
+	y   int
+}
+
+func (s *memRowSource) Size() (int, int) {
+	return s.img.Rect.Dx(), s.img.Rect.Dy()
+}
+
+func (s *memRowSource) ReadRows(stripA *image.RGBA64) error {
+	for y := 0; y < stripA.Rect.Dy(); y++ {
+		copy(stripA.Pix[y*stripA.Stride:y*stripA.Stride+8*s.img.Rect.Dx()], s.img.Pix[s.y*s.img.Stride:])
+		s.y++
+	}
+
+	return nil
+}
+
+// memRowSink collects the rows into an RGBA64 image
+type memRowSink struct {
+	img *image.RGBA64
+}
+
+func (s *memRowSink) Start(widthA, heightA int) error {
+	s.img = image.NewRGBA64(image.Rect(0, 0, widthA, heightA))
+	return nil
+}
+
+func (s *memRowSink) WriteRows(stripA *image.RGBA64) error {
+	for y := stripA.Rect.Min.Y; y < stripA.Rect.Max.Y; y++ {
+		copy(s.img.Pix[y*s.img.Stride:(y+1)*s.img.Stride], stripA.Pix[(y-stripA.Rect.Min.Y)*stripA.Stride:])
+	}
+
+	return nil
+}
+
+func (s *memRowSink) Finish() error {
+	return nil
+}
+
+// randomRGBA64 returns a premultiplied image of random colors and alpha
+func randomRGBA64(w, h int, seedA int64) *image.RGBA64 {
+	rnd := rand.New(rand.NewSource(seedA))
+	img := image.NewRGBA64(image.Rect(0, 0, w, h))
+
+	for i := 0; i < len(img.Pix); i += 8 {
+		a := uint16(rnd.Intn(0x10000))
+		for c := 0; c < 3; c++ {
+			v := uint16(rnd.Intn(int(a) + 1))
+			img.Pix[i+2*c], img.Pix[i+2*c+1] = uint8(v>>8), uint8(v)
+		}
+		img.Pix[i+6], img.Pix[i+7] = uint8(a>>8), uint8(a)
+	}
+
+	return img
+}
+
+func TestStreamResizeMatchesResizeImage(t *testing.T) {
+	p := NewImageTK()
+	src := randomRGBA64(151, 97, 1)
+
+	tests := []struct {
+		name   string
+		w, h   int
+		opts   interface{}
+		direct interface{}
+	}{
+		{"lanczos3 down", 60, 40, Lanczos3, nil},
+		{"lanczos3 up", 400, 300, Lanczos3, nil},
+		{"bilinear aspect", 37, 0, Bilinear, nil},
+		{"nearest", 50, 200, NearestNeighbor, nil},
+		{"area", 20, 13, Area, nil},
+		{"mitchell tall", 10, 300, MitchellNetravali, nil},
+		{"one pixel", 1, 1, Lanczos2, nil},
+		{"srgb", 60, 40, ResizeOptions{Interpolation: Lanczos3, Gamma: GammaSRGB}, nil},
+		{"kernel", 70, 50, Kernel{Support: 1, Func: linear}, nil},
+		// the options streaming does not support are replaced by the direct filter with EdgeClamp
+		{"reflect", 60, 40, ResizeOptions{Interpolation: Lanczos3, Edge: EdgeReflect}, Lanczos3},
+		{"wrap", 60, 40, ResizeOptions{Interpolation: Lanczos3, Edge: EdgeWrap}, Lanczos3},
+		{"prereduce", 10, 6, ResizeOptions{Interpolation: Lanczos3, Prereduce: true}, Lanczos3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directT := tt.direct
+			if directT == nil {
+				directT = tt.opts
+			}
+
+			want := p.ResizeImage(tt.w, tt.h, src, directT).(*image.RGBA64)
+
+			sinkT := &memRowSink{}
+			err := p.StreamResize(sinkT, &memRowSource{img: src}, tt.w, tt.h, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sinkT.img.Rect != want.Rect {
+				t.Fatalf("bounds %v, want %v", sinkT.img.Rect, want.Rect)
+			}
+
+			if !bytes.Equal(sinkT.img.Pix, want.Pix) {
+				t.Errorf("streamed pixels differ from ResizeImage")
+			}
+		})
+	}
+}
+
+func TestResizeStreamRejectsUnsupportedOptions(t *testing.T) {
+	src := randomRGBA64(60, 40, 2)
+
+	for _, opts := range []ResizeOptions{
+		{Interpolation: Lanczos3, Edge: EdgeReflect},
+		{Interpolation: Lanczos3, Prereduce: true},
+	} {
+		r := NewResizer(60, 40, 6, 4, opts)
+
+		err := r.ResizeStream(&memRowSink{}, &memRowSource{img: src})
+		if !errors.Is(err, ErrStreamOptions) {
+			t.Errorf("%+v: got %v, want ErrStreamOptions", opts, err)
+		}
+	}
+
+	err := NewResizer(61, 40, 6, 4).ResizeStream(&memRowSink{}, &memRowSource{img: src})
+	if !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("got %v, want ErrSizeMismatch", err)
+	}
+}
+
+func TestStreamResizePNGAndNetpbm(t *testing.T) {
+	p := NewImageTK()
+
+	img := image.NewNRGBA(image.Rect(0, 0, 120, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 120; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(2 * x), uint8(3 * y), uint8(x ^ y), 255})
+		}
+	}
+
+	want := p.ResizeImage(30, 20, img, Lanczos3)
+
+	var pngT bytes.Buffer
+	if err := png.Encode(&pngT, img); err != nil {
+		t.Fatal(err)
+	}
+
+	var ppmT bytes.Buffer
+	if err := p.EncodeTo(&ppmT, img, "ppm", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		source func([]byte) (RowSource, error)
+		format string
+	}{
+		{"png to png", pngT.Bytes(), func(b []byte) (RowSource, error) { return p.NewPNGRowSource(bytes.NewReader(b)) }, "png"},
+		{"png to pam", pngT.Bytes(), func(b []byte) (RowSource, error) { return p.NewPNGRowSource(bytes.NewReader(b)) }, "pam"},
+		{"ppm to png", ppmT.Bytes(), func(b []byte) (RowSource, error) { return p.NewNetpbmRowSource(bytes.NewReader(b)) }, "png"},
+		{"ppm to ppm", ppmT.Bytes(), func(b []byte) (RowSource, error) { return p.NewNetpbmRowSource(bytes.NewReader(b)) }, "ppm"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcT, err := tt.source(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var outT bytes.Buffer
+
+			var sinkT RowSink
+			if tt.format == "png" {
+				sinkT = p.NewPNGRowSink(&outT, false, nil)
+			} else {
+				sinkT, err = p.NewNetpbmRowSink(&outT, tt.format, false, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = p.StreamResize(sinkT, srcT, 30, 20, Lanczos3)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, _, err := p.DecodeFrom(&outT)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Bounds() != want.Bounds() {
+				t.Fatalf("bounds %v, want %v", got.Bounds(), want.Bounds())
+			}
+
+			for y := 0; y < 20; y++ {
+				for x := 0; x < 30; x++ {
+					g := color.NRGBAModel.Convert(got.At(x, y)).(color.NRGBA)
+					w := color.NRGBAModel.Convert(want.At(x, y)).(color.NRGBA)
+					if absInt(int(g.R)-int(w.R)) > 1 || absInt(int(g.G)-int(w.G)) > 1 || absInt(int(g.B)-int(w.B)) > 1 || g.A != w.A {
+						t.Fatalf("pixel (%v,%v) is %v, want %v", x, y, g, w)
+					}
+				}
+			}
+		})
+	}
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
+
+func TestStreamResizeFileFormat(t *testing.T) {
+	p := NewImageTK()
+	dirT := t.TempDir()
+
+	srcPathT := filepath.Join(dirT, "src.png")
+	if err := p.SaveImageAs(randomRGBA64(40, 30, 3), srcPathT); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file string
+		opts []interface{}
+		want string
+	}{
+		{"a.png", nil, "png"},
+		{"b.pgm", nil, "pgm"},
+		{"c.png", []interface{}{"ppm"}, "ppm"},
+		{"d.xyz", nil, "png"},
+		{"e.webp", nil, ""},
+		{"f.jpg", nil, ""},
+		{"g.png", []interface{}{"jpeg"}, ""},
+	}
+
+	for _, tt := range tests {
+		pathT := filepath.Join(dirT, tt.file)
+
+		err := p.StreamResizeFile(pathT, srcPathT, 20, 0, tt.opts...)
+		if tt.want == "" {
+			if !errors.Is(err, ErrUnsupportedFormat) {
+				t.Errorf("%v: got %v, want ErrUnsupportedFormat", tt.file, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		img, formatT, err := p.LoadImage(pathT)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if formatT != tt.want || img.Bounds().Size() != image.Pt(20, 15) {
+			t.Errorf("%v: got %v of %v", tt.file, formatT, img.Bounds().Size())
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package imagetk
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// ThumbnailMode selects how the image is fitted into the thumbnail box
+type ThumbnailMode int
+
+// ThumbnailMode constants
+const (
+	// ThumbnailContain fits the image inside the box keeping the aspect ratio
+	ThumbnailContain ThumbnailMode = iota
+	// ThumbnailCover fills the box keeping the aspect ratio, the overflow is cropped at the gravity
+	ThumbnailCover
+	// ThumbnailStretch resizes the image to the box ignoring the aspect ratio
+	ThumbnailStretch
+	// ThumbnailPad fits the image inside the box and pads it to the box size with the background
+	ThumbnailPad
+)
+
+// ThumbnailOptions holds the options of Thumbnail
+type ThumbnailOptions struct {
+	// Mode is how the image is fitted into the box
+	Mode ThumbnailMode
+	// Gravity is where the image is anchored for Cover and Pad, one of TOP_LEFT...BOTTOM_RIGHT,
+	// note that the zero value is TOP_LEFT, DefaultThumbnailOptions returns CENTER
+	Gravity int
+	// Background fills the padding of Pad, nil means transparent
+	Background color.Color
+	// AllowUpscale allows images smaller than the box to be enlarged
+	AllowUpscale bool
+	// SmartCrop makes Cover crop where the content is(see SmartCrop) instead of at the gravity
+	SmartCrop bool
+}
+
+// DefaultThumbnailOptions returns the options used when none are given
+func DefaultThumbnailOptions() ThumbnailOptions {
+	return ThumbnailOptions{Mode: ThumbnailContain, Gravity: CENTER}
+}
+
+// getThumbnailOptions returns the last ThumbnailOptions or *ThumbnailOptions in the optional arguments
+func getThumbnailOptions(optsA []interface{}) ThumbnailOptions {
+	optsT := DefaultThumbnailOptions()
+
+	for _, v := range optsA {
+		switch nv := v.(type) {
+		case ThumbnailOptions:
+			optsT = nv
+		case *ThumbnailOptions:
+			if nv != nil {
+				optsT = *nv
+			}
+		}
+	}
+
+	return optsT
+}
+
+// gravityOffset returns the offset of the anchor in the free space
+func gravityOffset(gravityA, freeXA, freeYA int) (int, int) {
+	if gravityA < TOP_LEFT || gravityA > BOTTOM_RIGHT {
+		gravityA = CENTER
+	}
+
+	x, y := 0, 0
+
+	switch gravityA % 3 {
+	case 1:
+		x = freeXA / 2
+	case 2:
+		x = freeXA
+	}
+
+	switch gravityA / 3 {
+	case 1:
+		y = freeYA / 2
+	case 2:
+		y = freeYA
+	}
+
+	return x, y
+}
+
+// scaleSize returns the size scaled and rounded, at least 1 and at most the limit
+func scaleSize(sizeA int, scaleA float64, limitA int) int {
+	v := int(math.Round(float64(sizeA) * scaleA))
+
+	if v > limitA {
+		v = limitA
+	}
+
+	if v < 1 {
+		v = 1
+	}
+
+	return v
+}
+
+// subImageOf returns the part of the image, sharing the pixels if possible
+func subImageOf(imageA image.Image, rectA image.Rectangle) image.Image {
+	if v, ok := imageA.(imageWithSubImage); ok {
+		return v.SubImage(rectA)
+	}
+
+	return cropImage(imageA, rectA)
+}
+
+// thumbnail makes the thumbnail of the box size, 0 for the width or height means the size of the image
+func (p *ImageTK) thumbnail(maxWidthA, maxHeightA int, img image.Image, optsA []interface{}) image.Image {
+	thumbT := getThumbnailOptions(optsA)
+
+	boundsT := img.Bounds()
+	w, h := boundsT.Dx(), boundsT.Dy()
+
+	if w < 1 || h < 1 {
+		return img
+	}
+
+	if maxWidthA <= 0 {
+		maxWidthA = w
+	}
+
+	if maxHeightA <= 0 {
+		maxHeightA = h
+	}
+
+	scaleX, scaleY := float64(maxWidthA)/float64(w), float64(maxHeightA)/float64(h)
+
+	switch thumbT.Mode {
+	case ThumbnailStretch:
+		nw, nh := maxWidthA, maxHeightA
+		if !thumbT.AllowUpscale {
+			nw, nh = minInt(nw, w), minInt(nh, h)
+		}
+
+		if nw == w && nh == h {
+			return img
+		}
+
+		return p.ResizeImage(nw, nh, img, optsA...)
+	case ThumbnailCover:
+		scaleT := math.Max(scaleX, scaleY)
+		if !thumbT.AllowUpscale && scaleT > 1 {
+			scaleT = 1
+		}
+
+		// the part of the image covering the box
+		cw, ch := scaleSize(maxWidthA, 1/scaleT, w), scaleSize(maxHeightA, 1/scaleT, h)
+		ox, oy := gravityOffset(thumbT.Gravity, w-cw, h-ch)
+		if thumbT.SmartCrop && (cw < w || ch < h) {
+			originT := analyzeCrop(img).bestWindow(w, h, cw, ch)
+			ox, oy = originT.X, originT.Y
+		}
+
+		cropT := subImageOf(img, image.Rect(boundsT.Min.X+ox, boundsT.Min.Y+oy, boundsT.Min.X+ox+cw, boundsT.Min.Y+oy+ch))
+
+		nw, nh := scaleSize(cw, scaleT, maxWidthA), scaleSize(ch, scaleT, maxHeightA)
+		if scaleT < 1 || thumbT.AllowUpscale {
+			nw, nh = maxWidthA, maxHeightA
+		}
+
+		// the crop is returned at (0,0) like the resized images
+		if nw == cw && nh == ch {
+			if rebasedT := rebaseImage(cropT); rebasedT != nil {
+				return rebasedT
+			}
+
+			return cropImage(cropT, cropT.Bounds())
+		}
+
+		return p.ResizeImage(nw, nh, cropT, optsA...)
+	}
+
+	// Contain and Pad
+	scaleT := math.Min(scaleX, scaleY)
+	if !thumbT.AllowUpscale && scaleT > 1 {
+		scaleT = 1
+	}
+
+	resultT := img
+
+	nw, nh := scaleSize(w, scaleT, maxWidthA), scaleSize(h, scaleT, maxHeightA)
+	if nw != w || nh != h {
+		resultT = p.ResizeImage(nw, nh, img, optsA...)
+	}
+
+	if thumbT.Mode != ThumbnailPad {
+		return resultT
+	}
+
+	canvasT := image.NewRGBA(image.Rect(0, 0, maxWidthA, maxHeightA))
+	if thumbT.Background != nil {
+		draw.Draw(canvasT, canvasT.Rect, image.NewUniform(thumbT.Background), image.Point{}, draw.Src)
+	}
+
+	ox, oy := gravityOffset(thumbT.Gravity, maxWidthA-nw, maxHeightA-nh)
+	draw.Draw(canvasT, image.Rect(ox, oy, ox+nw, oy+nh), resultT, resultT.Bounds().Min, draw.Over)
+
+	return canvasT
+}
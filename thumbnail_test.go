@@ -0,0 +1,71 @@
+package imagetk
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestThumbnailCoverBounds(t *testing.T) {
+	p := NewImageTK()
+
+	rgba := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	gray := image.NewGray(image.Rect(0, 0, 100, 50))
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, 100, 50), image.YCbCrSubsampleRatio420)
+	paletted := image.NewPaletted(image.Rect(0, 0, 100, 50), color.Palette{color.Black, color.White})
+	for y := 0; y < 50; y++ {
+		for x := 0; x < 100; x++ {
+			rgba.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+			gray.SetGray(x, y, color.Gray{uint8(2 * x)})
+			ycbcr.Y[ycbcr.YOffset(x, y)] = uint8(2 * x)
+			paletted.SetColorIndex(x, y, uint8(x&1))
+		}
+	}
+
+	for i := range ycbcr.Cb {
+		ycbcr.Cb[i], ycbcr.Cr[i] = 128, 128
+	}
+
+	offsetT := rgba.SubImage(image.Rect(10, 5, 90, 45)).(*image.RGBA)
+
+	tests := []struct {
+		name  string
+		img   image.Image
+		w, h  uint
+		wantX int
+	}{
+		{"rgba crop", rgba, 40, 50, 30},
+		{"gray crop", gray, 40, 50, 30},
+		{"ycbcr crop", ycbcr, 40, 50, 30},
+		{"paletted crop", paletted, 40, 50, 30},
+		{"offset crop", offsetT, 40, 40, 30},
+		{"offset whole", offsetT, 80, 40, 10},
+		{"offset resized", offsetT, 20, 10, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.Thumbnail(tt.w, tt.h, tt.img, ThumbnailOptions{Mode: ThumbnailCover, Gravity: CENTER})
+
+			if got.Bounds() != image.Rect(0, 0, int(tt.w), int(tt.h)) {
+				t.Fatalf("bounds %v, want %vx%v at (0,0)", got.Bounds(), tt.w, tt.h)
+			}
+
+			if tt.wantX < 0 {
+				return
+			}
+
+			// the pixels are those of the centered crop, compared in 8 bits as YCbCr is copied into RGBA
+			minT := tt.img.Bounds().Min
+			for y := 0; y < int(tt.h); y++ {
+				for x := 0; x < int(tt.w); x++ {
+					r0, g0, b0, a0 := got.At(x, y).RGBA()
+					r1, g1, b1, a1 := tt.img.At(tt.wantX+x, minT.Y+y).RGBA()
+					if r0>>8 != r1>>8 || g0>>8 != g1>>8 || b0>>8 != b1>>8 || a0>>8 != a1>>8 {
+						t.Fatalf("pixel (%v,%v) differs", x, y)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package imagetk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+// ErrPageIndex is returned when the requested page does not exist in a multi-page image
+var ErrPageIndex = errors.New("page index out of range")
+
+func init() {
+	RegisterFormat("bmp", "BM????\x00\x00\x00\x00", bmp.Decode, bmp.DecodeConfig)
+	RegisterFormat("tiff", "II*\x00", tiff.Decode, tiff.DecodeConfig)
+	RegisterFormat("tiff", "MM\x00*", tiff.Decode, tiff.DecodeConfig)
+	// lossy(VP8), lossless(VP8L) and extended(VP8X, with alpha)
+	RegisterFormat("webp", "RIFF????WEBPVP8", webp.Decode, webp.DecodeConfig)
+
+	RegisterEncoder("bmp", encodeBMP)
+	RegisterEncoder("tiff", encodeTIFF)
+}
+
+func encodeBMP(w io.Writer, imageA image.Image, optsA *EncodeOptions) error {
+	return bmp.Encode(w, imageA)
+}
+
+func encodeTIFF(w io.Writer, imageA image.Image, optsA *EncodeOptions) error {
+	// the encoder steps a full stride past the last row, which is beyond the pixels of sub-images not at the origin
+	if boundsT := imageA.Bounds(); boundsT.Min != (image.Point{}) {
+		imageA = cropImage(imageA, boundsT)
+	}
+
+	if optsA == nil {
+		return tiff.Encode(w, imageA, nil)
+	}
+
+	switch optsA.TIFFCompression {
+	case tiff.Uncompressed, tiff.Deflate:
+	default:
+		return fmt.Errorf("%w: TIFF compression %v could not be written", ErrUnsupportedFormat, optsA.TIFFCompression)
+	}
+
+	return tiff.Encode(w, imageA, &tiff.Options{Compression: optsA.TIFFCompression, Predictor: optsA.TIFFPredictor})
+}
+
+// the maximum number of TIFF pages, guards against IFD chains of broken files
+const maxTIFFPages = 65536
+
+// tiffPageOffsets walks the IFD chain of a TIFF stream and returns the byte order and the offset of each IFD(page)
+func tiffPageOffsets(r io.ReaderAt) (binary.ByteOrder, []uint32, error) {
+	bufT := make([]byte, 8)
+
+	_, errT := r.ReadAt(bufT, 0)
+	if errT != nil {
+		if errT == io.EOF {
+			errT = io.ErrUnexpectedEOF
+		}
+		return nil, nil, errT
+	}
+
+	var orderT binary.ByteOrder
+
+	switch string(bufT[:4]) {
+	case "II*\x00":
+		orderT = binary.LittleEndian
+	case "MM\x00*":
+		orderT = binary.BigEndian
+	default:
+		return nil, nil, tiff.FormatError("malformed header")
+	}
+
+	var offsetsT []uint32
+	visitedT := make(map[uint32]bool)
+
+	offsetT := orderT.Uint32(bufT[4:8])
+
+	for offsetT != 0 && !visitedT[offsetT] {
+		if len(offsetsT) >= maxTIFFPages {
+			return nil, nil, tiff.FormatError("too many pages")
+		}
+
+		visitedT[offsetT] = true
+		offsetsT = append(offsetsT, offsetT)
+
+		_, errT = r.ReadAt(bufT[:2], int64(offsetT))
+		if errT != nil {
+			return nil, nil, tiff.FormatError("invalid IFD offset")
+		}
+
+		nextT := int64(offsetT) + 2 + 12*int64(orderT.Uint16(bufT[:2]))
+
+		_, errT = r.ReadAt(bufT[:4], nextT)
+		if errT != nil {
+			// a truncated chain, keep the pages found
+			break
+		}
+
+		offsetT = orderT.Uint32(bufT[:4])
+	}
+
+	if len(offsetsT) < 1 {
+		return nil, nil, tiff.FormatError("no IFD found")
+	}
+
+	return orderT, offsetsT, nil
+}
+
+// tiffPageReader presents a TIFF stream whose header points to another IFD,
+// so the standard decoder reads that page instead of the first one
+type tiffPageReader struct {
+	r      io.ReaderAt
+	header [8]byte
+}
+
+func (p *tiffPageReader) ReadAt(b []byte, off int64) (int, error) {
+	n, errT := p.r.ReadAt(b, off)
+
+	for i := 0; i < n && off+int64(i) < 8; i++ {
+		b[i] = p.header[off+int64(i)]
+	}
+
+	return n, errT
+}
+
+func newTIFFPageReader(r io.ReaderAt, orderA binary.ByteOrder, offsetA uint32) *io.SectionReader {
+	pageT := &tiffPageReader{r: r}
+
+	if orderA == binary.LittleEndian {
+		copy(pageT.header[:4], "II*\x00")
+	} else {
+		copy(pageT.header[:4], "MM\x00*")
+	}
+
+	orderA.PutUint32(pageT.header[4:8], offsetA)
+
+	return io.NewSectionReader(pageT, 0, math.MaxInt64)
+}
+
+func asReaderAt(r io.Reader) (io.ReaderAt, error) {
+	if ra, ok := r.(io.ReaderAt); ok {
+		return ra, nil
+	}
+
+	dataT, errT := io.ReadAll(r)
+	if errT != nil {
+		return nil, errT
+	}
+
+	return bytes.NewReader(dataT), nil
+}
+
+// GetTIFFPageCount returns the number of pages in a TIFF image
+func (p *ImageTK) GetTIFFPageCount(readerA io.ReaderAt) (int, error) {
+	_, offsetsT, errT := tiffPageOffsets(readerA)
+	if errT != nil {
+		return 0, errT
+	}
+
+	return len(offsetsT), nil
+}
+
+// DecodeTIFFPage decodes the page with the index(starts from 0) of a multi-page TIFF image
+func (p *ImageTK) DecodeTIFFPage(readerA io.ReaderAt, indexA int) (image.Image, error) {
+	orderT, offsetsT, errT := tiffPageOffsets(readerA)
+	if errT != nil {
+		return nil, errT
+	}
+
+	if indexA < 0 || indexA >= len(offsetsT) {
+		return nil, ErrPageIndex
+	}
+
+	return tiff.Decode(newTIFFPageReader(readerA, orderT, offsetsT[indexA]))
+}
+
+// DecodeTIFFPages decodes all pages of a multi-page TIFF image
+func (p *ImageTK) DecodeTIFFPages(readerA io.Reader) ([]image.Image, error) {
+	readerAtT, errT := asReaderAt(readerA)
+	if errT != nil {
+		return nil, errT
+	}
+
+	orderT, offsetsT, errT := tiffPageOffsets(readerAtT)
+	if errT != nil {
+		return nil, errT
+	}
+
+	pagesT := make([]image.Image, 0, len(offsetsT))
+
+	for _, v := range offsetsT {
+		imgT, errT := tiff.Decode(newTIFFPageReader(readerAtT, orderT, v))
+		if errT != nil {
+			return nil, errT
+		}
+
+		pagesT = append(pagesT, imgT)
+	}
+
+	return pagesT, nil
+}
+
+// LoadTIFFPages loads all pages of a multi-page TIFF file
+func (p *ImageTK) LoadTIFFPages(fileNameA string) ([]image.Image, error) {
+	fileT, errT := os.Open(fileNameA)
+	if errT != nil {
+		return nil, errT
+	}
+	defer fileT.Close()
+
+	return p.DecodeTIFFPages(fileT)
+}
@@ -0,0 +1,305 @@
+package imagetk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/tiff"
+)
+
+// buildTIFF writes the gray images as the uncompressed pages of one TIFF stream in the byte order
+func buildTIFF(orderA binary.ByteOrder, pagesA []*image.Gray) []byte {
+	var b bytes.Buffer
+
+	if orderA == binary.LittleEndian {
+		b.WriteString("II*\x00")
+	} else {
+		b.WriteString("MM\x00*")
+	}
+
+	// the offset of the first IFD, patched below
+	binary.Write(&b, orderA, uint32(0))
+	nextPosT := 4
+
+	for _, v := range pagesA {
+		w, h := v.Rect.Dx(), v.Rect.Dy()
+
+		pixelsT := b.Len()
+		for y := 0; y < h; y++ {
+			b.Write(v.Pix[y*v.Stride : y*v.Stride+w])
+		}
+
+		if b.Len()%2 != 0 {
+			b.WriteByte(0)
+		}
+
+		ifdT := uint32(b.Len())
+		orderA.PutUint32(b.Bytes()[nextPosT:], ifdT)
+
+		// tag, type(3 SHORT, 4 LONG), value, sorted by tag
+		entriesT := [][3]uint32{
+			{256, 4, uint32(w)},
+			{257, 4, uint32(h)},
+			{258, 3, 8},
+			{259, 3, 1},
+			{262, 3, 1},
+			{273, 4, uint32(pixelsT)},
+			{277, 3, 1},
+			{278, 4, uint32(h)},
+			{279, 4, uint32(w * h)},
+		}
+
+		binary.Write(&b, orderA, uint16(len(entriesT)))
+		for _, e := range entriesT {
+			binary.Write(&b, orderA, uint16(e[0]))
+			binary.Write(&b, orderA, uint16(e[1]))
+			binary.Write(&b, orderA, uint32(1))
+
+			if e[1] == 3 {
+				binary.Write(&b, orderA, uint16(e[2]))
+				binary.Write(&b, orderA, uint16(0))
+			} else {
+				binary.Write(&b, orderA, e[2])
+			}
+		}
+
+		nextPosT = b.Len()
+		binary.Write(&b, orderA, uint32(0))
+	}
+
+	return b.Bytes()
+}
+
+// testPages returns three gray pages of different sizes and contents
+func testPages() []*image.Gray {
+	var pagesT []*image.Gray
+
+	for i, v := range []image.Point{{5, 3}, {2, 7}, {4, 4}} {
+		g := image.NewGray(image.Rectangle{Max: v})
+		for j := range g.Pix {
+			g.Pix[j] = uint8(j*11 + i*70)
+		}
+
+		pagesT = append(pagesT, g)
+	}
+
+	return pagesT
+}
+
+func TestTIFFPages(t *testing.T) {
+	p := NewImageTK()
+	pagesT := testPages()
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			dataT := buildTIFF(order, pagesT)
+
+			countT, err := p.GetTIFFPageCount(bytes.NewReader(dataT))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if countT != len(pagesT) {
+				t.Fatalf("%v pages, want %v", countT, len(pagesT))
+			}
+
+			for i, v := range pagesT {
+				img, err := p.DecodeTIFFPage(bytes.NewReader(dataT), i)
+				if err != nil {
+					t.Fatalf("page %v: %v", i, err)
+				}
+
+				sameImage(t, img, v)
+			}
+
+			for _, v := range []int{-1, len(pagesT)} {
+				if _, err := p.DecodeTIFFPage(bytes.NewReader(dataT), v); !errors.Is(err, ErrPageIndex) {
+					t.Errorf("page %v: got %v, want ErrPageIndex", v, err)
+				}
+			}
+
+			// a reader without ReadAt is read into memory
+			allT, err := p.DecodeTIFFPages(struct{ io.Reader }{bytes.NewReader(dataT)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(allT) != len(pagesT) {
+				t.Fatalf("%v pages, want %v", len(allT), len(pagesT))
+			}
+
+			for i, v := range allT {
+				sameImage(t, v, pagesT[i])
+			}
+
+			// the plain decoders read the first page
+			img, formatT, err := p.DecodeBytes(dataT)
+			if err != nil || formatT != "tiff" {
+				t.Fatalf("got %v, %v", formatT, err)
+			}
+
+			sameImage(t, img, pagesT[0])
+		})
+	}
+}
+
+func TestLoadTIFFPages(t *testing.T) {
+	p := NewImageTK()
+	pagesT := testPages()
+
+	pathT := filepath.Join(t.TempDir(), "pages.tif")
+	if err := os.WriteFile(pathT, buildTIFF(binary.LittleEndian, pagesT), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	allT, err := p.LoadTIFFPages(pathT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(allT) != len(pagesT) {
+		t.Fatalf("%v pages, want %v", len(allT), len(pagesT))
+	}
+
+	for i, v := range allT {
+		sameImage(t, v, pagesT[i])
+	}
+}
+
+func TestTIFFPagesBroken(t *testing.T) {
+	p := NewImageTK()
+	dataT := buildTIFF(binary.BigEndian, testPages())
+
+	// the last page points back to the first one
+	loopT := append([]byte(nil), dataT...)
+	copy(loopT[len(loopT)-4:], dataT[4:8])
+
+	countT, err := p.GetTIFFPageCount(bytes.NewReader(loopT))
+	if err != nil || countT != 3 {
+		t.Errorf("loop: got %v, %v", countT, err)
+	}
+
+	// the chain is cut within the last IFD, the pages before are kept
+	countT, err = p.GetTIFFPageCount(bytes.NewReader(dataT[:len(dataT)-2]))
+	if err != nil || countT != 3 {
+		t.Errorf("truncated: got %v, %v", countT, err)
+	}
+
+	badOffsetT := append([]byte(nil), dataT...)
+	binary.BigEndian.PutUint32(badOffsetT[4:8], uint32(len(dataT)+100))
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not tiff", []byte("GIF89a\x00\x00\x00\x00")},
+		{"short", []byte("II*")},
+		{"no IFD", []byte("II*\x00\x00\x00\x00\x00")},
+		{"bad offset", badOffsetT},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := p.GetTIFFPageCount(bytes.NewReader(tt.data)); err == nil {
+				t.Error("no error")
+			}
+
+			if _, err := p.DecodeTIFFPage(bytes.NewReader(tt.data), 0); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
+
+func TestBMPTIFFRoundTrip(t *testing.T) {
+	p := NewImageTK()
+
+	opaque := fillPattern(image.NewRGBA(image.Rect(0, 0, 9, 5)), false)
+
+	// the alpha channel of 32-bit BMP is not read back, the BMP header has no alpha mask
+	images := []struct {
+		name  string
+		img   image.Image
+		alpha bool
+	}{
+		{"gray", fillPattern(image.NewGray(image.Rect(0, 0, 9, 5)), false), false},
+		{"rgba", opaque, false},
+		{"nrgba", fillPattern(image.NewNRGBA(image.Rect(0, 0, 9, 5)), false), false},
+		{"nrgba translucent", fillPattern(image.NewNRGBA(image.Rect(0, 0, 9, 5)), true), true},
+		{"nrgba64 translucent", fillPattern(image.NewNRGBA64(image.Rect(0, 0, 9, 5)), true), true},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		opts   *EncodeOptions
+	}{
+		{"bmp", "bmp", nil},
+		{"tiff", "tiff", nil},
+		{"tiff uncompressed", "tiff", &EncodeOptions{TIFFCompression: tiff.Uncompressed}},
+		{"tiff deflate", "tiff", &EncodeOptions{TIFFCompression: tiff.Deflate}},
+		{"tiff deflate predictor", "tiff", &EncodeOptions{TIFFCompression: tiff.Deflate, TIFFPredictor: true}},
+		{"tiff uncompressed predictor", ".tif", &EncodeOptions{TIFFPredictor: true}},
+	}
+
+	for _, tt := range tests {
+		for _, im := range images {
+			if im.alpha && tt.format == "bmp" {
+				continue
+			}
+
+			t.Run(tt.name+" "+im.name, func(t *testing.T) {
+				dataT, err := p.EncodeToBytes(im.img, tt.format, tt.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, formatT, err := p.DecodeBytes(dataT)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if formatT != normalizeFormat(tt.format) {
+					t.Errorf("format %v", formatT)
+				}
+
+				sameImage(t, got, im.img)
+			})
+		}
+	}
+
+	// a sub-image whose pixels start within a larger buffer
+	offsetT := opaque.(*image.RGBA).SubImage(image.Rect(2, 1, 8, 5))
+
+	for _, v := range []string{"bmp", "tiff"} {
+		dataT, err := p.EncodeToBytes(offsetT, v, nil)
+		if err != nil {
+			t.Fatalf("%v: %v", v, err)
+		}
+
+		got, _, err := p.DecodeBytes(dataT)
+		if err != nil {
+			t.Fatalf("%v: %v", v, err)
+		}
+
+		sameImage(t, got, offsetT)
+	}
+}
+
+func TestTIFFUnsupportedCompression(t *testing.T) {
+	p := NewImageTK()
+	img := image.NewGray(image.Rect(0, 0, 4, 4))
+
+	for _, v := range []tiff.CompressionType{tiff.LZW, tiff.CCITTGroup3, tiff.CCITTGroup4} {
+		_, err := p.EncodeToBytes(img, "tiff", &EncodeOptions{TIFFCompression: v, TIFFPredictor: true})
+		if !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("compression %v: got %v, want ErrUnsupportedFormat", v, err)
+		}
+	}
+}